@@ -1,7 +1,90 @@
 package analogdb
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Author is the profile of a single author
+// with aggregate statistics over all their posts
+type Author struct {
+	Name           string           `json:"name"`
+	PostCount      int              `json:"post_count"`
+	TotalScore     int              `json:"total_score"`
+	AverageScore   float64          `json:"average_score"`
+	FirstPostTime  int              `json:"first_post_time"`
+	LastPostTime   int              `json:"last_post_time"`
+	GrayscaleRatio float64          `json:"grayscale_ratio"`
+	SprocketRatio  float64          `json:"sprocket_ratio"`
+	Keywords       []KeywordSummary `json:"keywords"`
+	Colors         []ColorSummary   `json:"colors"`
+}
+
+// AuthorSummary is a single entry when listing authors
+type AuthorSummary struct {
+	Name       string `json:"name"`
+	PostCount  int    `json:"post_count"`
+	TotalScore int    `json:"total_score"`
+}
+
+type AuthorSort int
+
+const (
+	AuthorSortUnknown AuthorSort = iota
+	AuthorSortPosts
+	AuthorSortScore
+)
+
+func (s AuthorSort) String() string {
+	switch s {
+	case AuthorSortPosts:
+		return "posts"
+	case AuthorSortScore:
+		return "score"
+	default:
+		return "unknown"
+	}
+}
+
+func AuthorSortFromString(s string) AuthorSort {
+	switch strings.ToLower(s) {
+	case "posts":
+		return AuthorSortPosts
+	case "score":
+		return AuthorSortScore
+	default:
+		return AuthorSortUnknown
+	}
+}
+
+// AuthorFilter are options used for listing authors
+type AuthorFilter struct {
+	Limit  *int
+	Sort   *AuthorSort
+	Keyset *string
+	Prefix *string
+}
+
+func (filter *AuthorFilter) String() string {
+	out := []string{}
+	if filter.Limit != nil {
+		out = append(out, fmt.Sprintf("limit: %d", *filter.Limit))
+	}
+	if filter.Sort != nil {
+		out = append(out, fmt.Sprintf("sort: %s", filter.Sort))
+	}
+	if filter.Keyset != nil {
+		out = append(out, fmt.Sprintf("keyset: %s", *filter.Keyset))
+	}
+	if filter.Prefix != nil {
+		out = append(out, fmt.Sprintf("prefix: %s", *filter.Prefix))
+	}
+	return strings.Join(out, ", ")
+}
 
 type AuthorService interface {
 	FindAuthors(ctx context.Context) ([]string, error)
+	FindAuthorByName(ctx context.Context, name string) (*Author, error)
+	FindAuthorSummaries(ctx context.Context, filter *AuthorFilter) ([]*AuthorSummary, int, error)
 }
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/config"
+	"github.com/evanofslack/analogdb/imaging"
+	"github.com/evanofslack/analogdb/logger"
+	"github.com/evanofslack/analogdb/metrics"
+	"github.com/evanofslack/analogdb/postgres"
+	"github.com/evanofslack/analogdb/redis"
+	"github.com/evanofslack/analogdb/tracer"
+	"github.com/evanofslack/analogdb/weaviate"
+)
+
+// app holds the connections shared by the operational commands.
+// Connections are opened as a command needs them and closed together.
+type app struct {
+	cfg    *config.Config
+	logger *logger.Logger
+
+	db     *postgres.DB
+	dbVec  *weaviate.DB
+	rdb    *redis.RDB
+	closer []func() error
+
+	// downloads post images, shared by every service of a command
+	images *imaging.Downloader
+}
+
+// newApp loads and validates the config and creates a logger,
+// without opening any connections
+func newApp(cfgPath string) (*app, error) {
+	cfg, err := config.New(cfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse app config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid config %s:\n%w", cfgPath, err)
+	}
+	logger, err := logger.New(cfg.Log.Level, cfg.App.Env, cfg.App.Name)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create logger: %w", err)
+	}
+	return &app{cfg: cfg, logger: logger}, nil
+}
+
+// openDB connects to postgres
+func (a *app) openDB() (*postgres.DB, error) {
+	if a.db != nil {
+		return a.db, nil
+	}
+	db := postgres.NewDB(a.cfg.DB.URL, a.logger.WithSubsystem("database"), false)
+	if err := db.Open(); err != nil {
+		return nil, fmt.Errorf("Failed to startup database: %w", err)
+	}
+	a.db = db
+	a.closer = append(a.closer, db.Close)
+	return db, nil
+}
+
+// openVectorDB connects to weaviate
+func (a *app) openVectorDB() (*weaviate.DB, error) {
+	if a.dbVec != nil {
+		return a.dbVec, nil
+	}
+	tracer, err := tracer.New(a.logger.WithSubsystem("tracer"), a.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize otlp tracing: %w", err)
+	}
+	dbVec := weaviate.NewDB(a.cfg.VectorDB.Host, a.cfg.VectorDB.Scheme, a.logger.WithSubsystem("vector-database"), tracer, weaviate.WithDownloader(a.downloader()))
+	if err := dbVec.Open(); err != nil {
+		return nil, fmt.Errorf("Failed to startup vector database: %w", err)
+	}
+	a.dbVec = dbVec
+	a.closer = append(a.closer, dbVec.Close)
+	return dbVec, nil
+}
+
+// openRedis connects to redis, failing if it can't be reached since
+// a command has no use for the in-memory fallback of a server
+func (a *app) openRedis() (*redis.RDB, error) {
+	if a.rdb != nil {
+		return a.rdb, nil
+	}
+	metrics, err := metrics.New(a.logger.WithSubsystem("metrics"))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize prometheus metrics: %w", err)
+	}
+	rdb, err := redis.NewRDB(a.cfg.Redis.URL, a.logger.WithSubsystem("redis"), metrics, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to startup redis: %w", err)
+	}
+	a.closer = append(a.closer, rdb.Close)
+	if err := rdb.Open(); err != nil {
+		return nil, fmt.Errorf("Failed to connect to redis: %w", err)
+	}
+	a.rdb = rdb
+	return rdb, nil
+}
+
+// postService is the post service used by the server, wrapped with
+// the cache if enabled so changes invalidate the cache of every replica
+func (a *app) postService() (analogdb.PostService, error) {
+	db, err := a.openDB()
+	if err != nil {
+		return nil, err
+	}
+	var postService analogdb.PostService = postgres.NewPostService(db)
+	if a.cfg.App.CacheEnabled && a.cfg.Redis.URL != "" {
+		rdb, err := a.openRedis()
+		if err != nil {
+			return nil, err
+		}
+		postService = redis.NewCachePostService(rdb, postService)
+	}
+	return postService, nil
+}
+
+// similarityService encodes posts found through the post service
+func (a *app) similarityService() (*weaviate.SimilarityService, error) {
+	postService, err := a.postService()
+	if err != nil {
+		return nil, err
+	}
+	dbVec, err := a.openVectorDB()
+	if err != nil {
+		return nil, err
+	}
+	db, err := a.openDB()
+	if err != nil {
+		return nil, err
+	}
+	// lists of posts filtered by whether posts are encoded are cached too
+	var encodingService analogdb.EncodingService = postgres.NewEncodingService(db)
+	if cachePostService, ok := postService.(*redis.PostService); ok {
+		encodingService = redis.NewCacheEncodingService(cachePostService, encodingService)
+	}
+	return weaviate.NewSimilarityService(dbVec, postService, encodingService), nil
+}
+
+// downloader downloads post images within the limits of the config
+func (a *app) downloader() *imaging.Downloader {
+	if a.images == nil {
+		a.images = newDownloader(a.cfg)
+	}
+	return a.images
+}
+
+// newDownloader downloads post images within the limits of the config
+func newDownloader(cfg *config.Config) *imaging.Downloader {
+	return imaging.NewDownloader(
+		imaging.WithDownloadTimeout(cfg.Download.Timeout),
+		imaging.WithMaxDownloadSize(cfg.Download.MaxSizeMB<<20),
+		imaging.WithDownloadRetries(cfg.Download.Retries, cfg.Download.Backoff),
+		imaging.WithDownloadConcurrency(cfg.Download.Concurrency),
+	)
+}
+
+// close every connection opened, latest first
+func (a *app) close() {
+	for i := len(a.closer) - 1; i >= 0; i-- {
+		if err := a.closer[i](); err != nil {
+			a.logger.Error().Err(err).Msg("Failed to close connection")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+const cacheUsage = `usage: analogdb cache <flush|stats> [flags]
+
+  flush  remove every item from redis, items in the local
+         cache of running servers expire within their ttl
+  stats  print the size and hit rate of redis
+`
+
+func runCache(ctx context.Context, args []string) error {
+
+	action, args, err := subcommand(args, cacheUsage)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("cache "+action, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), cacheUsage+"\nflags:\n")
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	flags.Parse(args)
+
+	if action != "flush" && action != "stats" {
+		flags.Usage()
+		return errUsage
+	}
+
+	a, err := newApp(*cfgPath)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	if a.cfg.Redis.URL == "" {
+		return fmt.Errorf("redis url is not configured")
+	}
+	rdb, err := a.openRedis()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "flush":
+		if err := rdb.Flush(ctx); err != nil {
+			return fmt.Errorf("Failed to flush cache: %w", err)
+		}
+		fmt.Println("Flushed cache")
+
+	case "stats":
+		stats, err := rdb.Stats(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed to get cache stats: %w", err)
+		}
+		hitRate := 0.0
+		if total := stats.Hits + stats.Misses; total > 0 {
+			hitRate = float64(stats.Hits) / float64(total) * 100
+		}
+		fmt.Printf("keys:       %d\n", stats.Keys)
+		fmt.Printf("memory:     %s\n", stats.UsedMemory)
+		fmt.Printf("hits:       %d\n", stats.Hits)
+		fmt.Printf("misses:     %d\n", stats.Misses)
+		fmt.Printf("hit rate:   %.1f%%\n", hitRate)
+		fmt.Printf("evictions:  %d\n", stats.Evictions)
+		fmt.Printf("uptime:     %ds\n", stats.Uptime)
+	}
+	return nil
+}
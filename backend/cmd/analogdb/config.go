@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/evanofslack/analogdb/config"
+)
+
+const configUsage = `usage: analogdb config validate [flags]
+
+  validate  check the config file and environment have everything needed to serve
+`
+
+func runConfig(ctx context.Context, args []string) error {
+
+	action, args, err := subcommand(args, configUsage)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("config "+action, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), configUsage+"\nflags:\n")
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	flags.Parse(args)
+
+	if action != "validate" {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := config.New(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("Failed to parse app config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("Invalid config %s:\n%w", *cfgPath, err)
+	}
+	fmt.Printf("Config %s is valid\n", *cfgPath)
+	return nil
+}
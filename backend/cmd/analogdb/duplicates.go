@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/imaging"
+	"github.com/evanofslack/analogdb/postgres"
+)
+
+const duplicatesUsage = `usage: analogdb duplicates <hash|link|list> [flags]
+
+  hash  compute the image hash of every post without one, then link duplicates
+  link  recompute which posts have near identical images
+  list  print clusters of posts with near identical images
+`
+
+func runDuplicates(ctx context.Context, args []string) error {
+
+	action, args, err := subcommand(args, duplicatesUsage)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("duplicates "+action, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), duplicatesUsage+"\nflags:\n")
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	distance := flags.Int("distance", -1, "largest hamming distance between duplicates, defaults to the config")
+	flags.Parse(args)
+
+	a, err := newApp(*cfgPath)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	db, err := a.openDB()
+	if err != nil {
+		return err
+	}
+	duplicateService := postgres.NewDuplicateService(db)
+
+	maxDistance := a.cfg.Duplicates.MaxDistance
+	if *distance >= 0 {
+		maxDistance = *distance
+	}
+
+	switch action {
+	case "hash":
+		postService, err := a.postService()
+		if err != nil {
+			return err
+		}
+		if err := hashPosts(ctx, a.downloader(), postService, duplicateService); err != nil {
+			return err
+		}
+		return linkDuplicates(ctx, duplicateService, maxDistance)
+
+	case "link":
+		return linkDuplicates(ctx, duplicateService, maxDistance)
+
+	case "list":
+		// only pairs linked within the distance used to link are listed
+		filter := &analogdb.DuplicateFilter{MaxDistance: maxDistance}
+		clusters, count, err := duplicateService.FindDuplicateClusters(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, cluster := range clusters {
+			fmt.Printf("distance %d: %v\n", cluster.Distance, cluster.PostIDs)
+		}
+		fmt.Printf("Found %d clusters within distance %d\n", count, maxDistance)
+
+	default:
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// linkDuplicates records every pair of posts within maxDistance as duplicates
+func linkDuplicates(ctx context.Context, duplicateService analogdb.DuplicateService, maxDistance int) error {
+	start := time.Now()
+	pairs, err := duplicateService.LinkDuplicates(ctx, maxDistance)
+	if err != nil {
+		return fmt.Errorf("Failed to link duplicates: %w", err)
+	}
+	fmt.Printf("Linked %d pairs of duplicates within distance %d in %s\n", pairs, maxDistance, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// hashPosts computes the image hash of posts created before hashing,
+// skipping posts whose image can't be downloaded
+func hashPosts(ctx context.Context, downloader *imaging.Downloader, postService analogdb.PostService, duplicateService analogdb.DuplicateService) error {
+
+	ids, err := duplicateService.UnhashedPostIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	hashed := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		post, err := postService.FindPostByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		image, ok := analogdb.FindImage(post.Images, analogdb.ImageLow)
+		if !ok {
+			fmt.Printf("Skipped post %d: no %s image\n", id, analogdb.ImageLow)
+			continue
+		}
+		data, err := downloader.Download(ctx, image.Url)
+		if err != nil {
+			fmt.Printf("Skipped post %d: %s\n", id, err)
+			continue
+		}
+		img, err := imaging.DecodeBytes(data)
+		if err != nil {
+			fmt.Printf("Skipped post %d: %s\n", id, err)
+			continue
+		}
+		if err := duplicateService.SetImageHash(ctx, id, imaging.DHash(img)); err != nil {
+			return err
+		}
+		hashed++
+	}
+	fmt.Printf("Hashed %d of %d posts in %s\n", hashed, len(ids), time.Since(start).Round(time.Second))
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+)
+
+const encodeUsage = `usage: analogdb encode (-ids <id,...> | -all | -outdated) [flags]
+
+Encodes posts into the vector database, replacing any existing encoding.
+With -outdated, only posts that failed to encode, were never encoded, or
+were encoded with an older schema are encoded.
+
+flags:
+`
+
+func runEncode(ctx context.Context, args []string) error {
+
+	flags := flag.NewFlagSet("encode", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), encodeUsage)
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	idList := flags.String("ids", "", "comma separated ids of posts to encode")
+	all := flags.Bool("all", false, "encode every post")
+	outdated := flags.Bool("outdated", false, "encode posts not encoded with the latest schema")
+	batchSize := flags.Int("batch-size", defaultEncodeBatchSize, "number of posts encoded at a time")
+	flags.Parse(args)
+
+	selected := 0
+	for _, set := range []bool{*idList != "", *all, *outdated} {
+		if set {
+			selected++
+		}
+	}
+	if selected != 1 {
+		flags.Usage()
+		return errUsage
+	}
+	if *batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+
+	var ids []int
+	if *idList != "" {
+		for _, s := range strings.Split(*idList, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("invalid post id %s", s)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	a, err := newApp(*cfgPath)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	similarityService, err := a.similarityService()
+	if err != nil {
+		return err
+	}
+
+	if *all {
+		postService, err := a.postService()
+		if err != nil {
+			return err
+		}
+		if ids, err = postService.AllPostIDs(ctx); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	var result *analogdb.EncodeResult
+	if *outdated {
+		result, err = similarityService.EncodeOutdatedPosts(ctx, *batchSize, printEncodeProgress())
+	} else {
+		result, err = similarityService.BatchEncodePosts(ctx, ids, *batchSize, printEncodeProgress())
+	}
+	if result != nil {
+		printEncodeFailures(result.Failed)
+		fmt.Printf("Encoded %d posts in %s\n", len(result.Encoded), time.Since(start).Round(time.Millisecond))
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to encode posts: %w", err)
+	}
+	if len(result.Failed) != 0 {
+		return fmt.Errorf("Failed to encode %d posts", len(result.Failed))
+	}
+	return nil
+}
+
+// how often progress of a long encode is printed
+const encodeProgressInterval = 5 * time.Second
+
+// printEncodeProgress prints how far an encode is every so often
+func printEncodeProgress() analogdb.EncodeProgressFunc {
+	last := time.Now()
+	return func(p analogdb.EncodeProgress) {
+		if time.Since(last) < encodeProgressInterval {
+			return
+		}
+		last = time.Now()
+		fmt.Printf("Encoded %d of %d posts, %d failed\n", p.Encoded, p.Total, p.Failed)
+	}
+}
+
+func printEncodeFailures(failures []analogdb.EncodeFailure) {
+	for _, f := range failures {
+		fmt.Printf("Failed to encode post %d: %s\n", f.PostID, f.Reason)
+	}
+}
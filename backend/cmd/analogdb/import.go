@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/imaging"
+)
+
+const (
+	defaultImportBatchSize = 100
+	defaultEncodeBatchSize = 20
+
+	// max length of a single ndjson record
+	maxImportLineSize = 1024 * 1024
+
+	// number of invalid records and failed posts to print before only counting them
+	maxInvalidReported = 20
+)
+
+const importUsage = `usage: analogdb import [flags] <file>
+
+Imports posts from a file of CreatePost records, one per line as ndjson,
+or one per row as csv, such as an ndjson or csv file from GET /export. Csv columns are named after the json fields of a
+CreatePost; images, colors and keywords columns hold json arrays.
+Pass - as the file to read from stdin.
+
+The image of each post is downloaded, as when a post is created, to hash it
+for finding duplicates, read its metadata, and compute its colors, grayscale
+and sprocket if they are left out.
+
+flags:
+`
+
+// importSummary counts what happened to each record of an import
+type importSummary struct {
+	read    int
+	created int
+	updated int
+	skipped int
+	failed  int
+	invalid int
+	encoded int
+}
+
+func (s importSummary) String() string {
+	return fmt.Sprintf("read %d, created %d, updated %d, skipped %d, failed %d, invalid %d, encoded %d", s.read, s.created, s.updated, s.skipped, s.failed, s.invalid, s.encoded)
+}
+
+func runImport(ctx context.Context, args []string) error {
+
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), importUsage)
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	format := flags.String("format", "", "format of the file, ndjson or csv (default from the file extension)")
+	batchSize := flags.Int("batch-size", defaultImportBatchSize, "number of posts inserted per transaction")
+	update := flags.Bool("update", false, "update posts with a permalink that already exists, instead of skipping them")
+	encode := flags.Bool("encode", false, "encode created posts into the vector database")
+	encodeBatchSize := flags.Int("encode-batch-size", defaultEncodeBatchSize, "number of posts encoded at a time")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a single file to import")
+	}
+	path := flags.Arg(0)
+
+	if *batchSize <= 0 {
+		return errors.New("batch size must be positive")
+	}
+
+	a, err := newApp(*cfgPath)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	// imports go through the cache, so servers don't serve lists without the new posts
+	postService, err := a.postService()
+	if err != nil {
+		return err
+	}
+
+	var similarityService analogdb.SimilarityService
+	if *encode {
+		if similarityService, err = a.similarityService(); err != nil {
+			return err
+		}
+	}
+
+	var file io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file = f
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	var reader postReader
+	switch *format {
+	case "ndjson", "jsonl", "json", "":
+		reader = newNDJSONPostReader(file)
+	case "csv":
+		reader, err = newCSVPostReader(file)
+		if err != nil {
+			return fmt.Errorf("Failed to read csv header: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown format %s, expected ndjson or csv", *format)
+	}
+
+	start := time.Now()
+	summary := importSummary{}
+
+	// insert a batch of posts, then encode the ones created
+	flush := func(batch []*analogdb.CreatePost) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := postService.ImportPosts(ctx, batch, *update)
+		if err != nil {
+			return fmt.Errorf("Failed to import batch of posts: %w", err)
+		}
+		summary.created += len(result.Created)
+		summary.updated += len(result.Updated)
+		summary.skipped += result.Skipped
+		for _, failure := range result.Failed {
+			summary.failed += 1
+			if summary.failed <= maxInvalidReported {
+				fmt.Fprintf(os.Stderr, "failed to import %s: %s\n", failure.Permalink, failure.Reason)
+			}
+		}
+
+		if similarityService != nil && len(result.Created) > 0 {
+			// posts that fail to encode stay imported, for a later reconcile to encode
+			encoded, err := similarityService.BatchEncodePosts(ctx, result.Created, *encodeBatchSize, nil)
+			if err != nil {
+				return fmt.Errorf("Failed to encode imported posts: %w", err)
+			}
+			printEncodeFailures(encoded.Failed)
+			summary.encoded += len(encoded.Encoded)
+		}
+
+		fmt.Printf("Imported %s (%s)\n", summary, time.Since(start).Round(time.Millisecond))
+		return nil
+	}
+
+	downloader := a.downloader()
+
+	// records read but not yet analyzed, with their number to report them
+	pending := make([]*analogdb.CreatePost, 0, *batchSize)
+	records := make([]int, 0, *batchSize)
+
+	// analyze the images of a batch of posts, then insert the valid ones
+	process := func() error {
+		errs := analyzePosts(ctx, downloader, pending)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := make([]*analogdb.CreatePost, 0, len(pending))
+		for i, post := range pending {
+			err := errs[i]
+			if err == nil {
+				err = post.Validate()
+			}
+			if err != nil {
+				summary.invalid += 1
+				if summary.invalid <= maxInvalidReported {
+					fmt.Fprintln(os.Stderr, &invalidRecordError{record: records[i], err: err})
+				}
+				continue
+			}
+			batch = append(batch, post)
+		}
+		pending, records = pending[:0], records[:0]
+		return flush(batch)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		post, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		var invalid *invalidRecordError
+		if errors.As(err, &invalid) {
+			summary.read += 1
+			summary.invalid += 1
+			if summary.invalid <= maxInvalidReported {
+				fmt.Fprintln(os.Stderr, invalid)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		summary.read += 1
+
+		pending = append(pending, post)
+		records = append(records, reader.record())
+		if len(pending) == *batchSize {
+			if err := process(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := process(); err != nil {
+		return err
+	}
+
+	if summary.failed > maxInvalidReported {
+		fmt.Fprintf(os.Stderr, "... and %d more failed posts\n", summary.failed-maxInvalidReported)
+	}
+	if summary.invalid > maxInvalidReported {
+		fmt.Fprintf(os.Stderr, "... and %d more invalid records\n", summary.invalid-maxInvalidReported)
+	}
+	fmt.Printf("Finished import in %s: %s\n", time.Since(start).Round(time.Millisecond), summary)
+	return nil
+}
+
+// analyzePosts analyzes the images of posts as the server does when a post is
+// created, as many at once as the downloader allows. It returns the error of
+// each post missing its colors, grayscale or sprocket that couldn't be
+// analyzed; other posts are only left without a hash or metadata.
+func analyzePosts(ctx context.Context, downloader *imaging.Downloader, posts []*analogdb.CreatePost) []error {
+	errs := make([]error, len(posts))
+	var wg sync.WaitGroup
+	for i, post := range posts {
+		wg.Add(1)
+		go func(i int, post *analogdb.CreatePost) {
+			defer wg.Done()
+			required := imaging.PostNeedsAnalysis(post)
+			if err := downloader.AnalyzePost(ctx, post); err != nil && required {
+				errs[i] = err
+			}
+		}(i, post)
+	}
+	wg.Wait()
+	return errs
+}
+
+// invalidRecordError is a record that could not be read as a post.
+// The record is skipped and the import continues.
+type invalidRecordError struct {
+	record int
+	err    error
+}
+
+func (e *invalidRecordError) Error() string {
+	msg := e.err.Error()
+	if analogdb.ErrorCode(e.err) != "" {
+		msg = analogdb.ErrorMessage(e.err)
+	}
+	return fmt.Sprintf("invalid record %d: %s", e.record, msg)
+}
+
+// postReader reads posts one record at a time until io.EOF
+type postReader interface {
+	next() (*analogdb.CreatePost, error)
+	// number of the record last read, starting at 1
+	record() int
+}
+
+type ndjsonPostReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONPostReader(r io.Reader) *ndjsonPostReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	return &ndjsonPostReader{scanner: scanner}
+}
+
+func (r *ndjsonPostReader) next() (*analogdb.CreatePost, error) {
+	for r.scanner.Scan() {
+		r.line += 1
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var post analogdb.CreatePost
+		if err := json.Unmarshal([]byte(line), &post); err != nil {
+			return nil, &invalidRecordError{record: r.line, err: err}
+		}
+		return &post, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (r *ndjsonPostReader) record() int {
+	return r.line
+}
+
+type csvPostReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	row     int
+}
+
+func newCSVPostReader(r io.Reader) (*csvPostReader, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return &csvPostReader{reader: reader, columns: columns, row: 1}, nil
+}
+
+func (r *csvPostReader) next() (*analogdb.CreatePost, error) {
+	row, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	r.row += 1
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &invalidRecordError{record: r.row, err: err}
+		}
+		return nil, err
+	}
+
+	post, err := r.parse(row)
+	if err != nil {
+		return nil, &invalidRecordError{record: r.row, err: err}
+	}
+	return post, nil
+}
+
+func (r *csvPostReader) record() int {
+	return r.row
+}
+
+// parse a row into a post, reading columns by the names of CreatePost's json fields
+func (r *csvPostReader) parse(row []string) (*analogdb.CreatePost, error) {
+
+	get := func(name string) string {
+		if i, ok := r.columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	getInt := func(name string) (int, error) {
+		if v := get(name); v != "" {
+			return strconv.Atoi(v)
+		}
+		return 0, nil
+	}
+	getBool := func(name string) (bool, error) {
+		if v := get(name); v != "" {
+			return strconv.ParseBool(v)
+		}
+		return false, nil
+	}
+	// unknown when empty, computed from the image before the post is validated
+	getOptionalBool := func(name string) (*bool, error) {
+		v := get(name)
+		if v == "" {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(v)
+		return &b, err
+	}
+	getJSON := func(name string, v any) error {
+		if s := get(name); s != "" {
+			return json.Unmarshal([]byte(s), v)
+		}
+		return nil
+	}
+
+	post := &analogdb.CreatePost{
+		Title:     get("title"),
+		Author:    get("author"),
+		Permalink: get("permalink"),
+	}
+
+	var err error
+	if post.Score, err = getInt("upvotes"); err != nil {
+		return nil, fmt.Errorf("upvotes: %w", err)
+	}
+	if post.Time, err = getInt("unix_time"); err != nil {
+		return nil, fmt.Errorf("unix_time: %w", err)
+	}
+	if post.Nsfw, err = getBool("nsfw"); err != nil {
+		return nil, fmt.Errorf("nsfw: %w", err)
+	}
+	if post.Grayscale, err = getOptionalBool("grayscale"); err != nil {
+		return nil, fmt.Errorf("grayscale: %w", err)
+	}
+	if post.Sprocket, err = getOptionalBool("sprocket"); err != nil {
+		return nil, fmt.Errorf("sprocket: %w", err)
+	}
+	if err := getJSON("images", &post.Images); err != nil {
+		return nil, fmt.Errorf("images: %w", err)
+	}
+	if err := getJSON("colors", &post.Colors); err != nil {
+		return nil, fmt.Errorf("colors: %w", err)
+	}
+	if err := getJSON("keywords", &post.Keywords); err != nil {
+		return nil, fmt.Errorf("keywords: %w", err)
+	}
+	return post, nil
+}
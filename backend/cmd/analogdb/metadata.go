@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/imaging"
+	"github.com/evanofslack/analogdb/postgres"
+)
+
+const metadataUsage = `usage: analogdb metadata [flags]
+
+Read the EXIF, XMP and color profile of every post without metadata.
+`
+
+func runMetadata(ctx context.Context, args []string) error {
+
+	flags := flag.NewFlagSet("metadata", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), metadataUsage+"\nflags:\n")
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	flags.Parse(args)
+
+	a, err := newApp(*cfgPath)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	db, err := a.openDB()
+	if err != nil {
+		return err
+	}
+	postService, err := a.postService()
+	if err != nil {
+		return err
+	}
+	return readMetadata(ctx, a.downloader(), postService, postgres.NewMetadataService(db))
+}
+
+// readMetadata reads the metadata of posts created before it was read,
+// skipping posts whose image can't be downloaded
+func readMetadata(ctx context.Context, downloader *imaging.Downloader, postService analogdb.PostService, metadataService analogdb.MetadataService) error {
+
+	ids, err := metadataService.PostIDsWithoutMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	read := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		post, err := postService.FindPostByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		// smaller renditions are usually stripped of their metadata
+		image, ok := analogdb.FindImage(post.Images, analogdb.ImageRaw)
+		if !ok {
+			if image, ok = analogdb.FindImage(post.Images, analogdb.ImageHigh); !ok {
+				fmt.Printf("Skipped post %d: no %s image\n", id, analogdb.ImageHigh)
+				continue
+			}
+		}
+		data, err := downloader.Download(ctx, image.Url)
+		if err != nil {
+			fmt.Printf("Skipped post %d: %s\n", id, err)
+			continue
+		}
+		if err := metadataService.SetPostMetadata(ctx, id, imaging.ReadMetadata(data)); err != nil {
+			return err
+		}
+		read++
+	}
+	fmt.Printf("Read metadata of %d of %d posts in %s\n", read, len(ids), time.Since(start).Round(time.Second))
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage: analogdb migrate <up|down|status|force> [flags]
+
+  up               apply every pending migration
+  down             revert the latest migrations, one unless -steps is set
+  status           list applied and pending migrations
+  force <version>  set the schema version without running migrations,
+                   to recover from a migration that failed part way
+`
+
+func runMigrate(ctx context.Context, args []string) error {
+
+	action, args, err := subcommand(args, migrateUsage)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("migrate "+action, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage+"\nflags:\n")
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	steps := flags.Int("steps", 1, "number of migrations to revert with down")
+	flags.Parse(args)
+
+	a, err := newApp(*cfgPath)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	db, err := a.openDB()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+
+	case "down":
+		if *steps <= 0 {
+			return fmt.Errorf("steps must be positive")
+		}
+		reverted, err := db.MigrateDown(ctx, *steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migrations\n", reverted)
+
+	case "status":
+		status, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE")
+		for _, m := range status.Applied {
+			state := "applied"
+			if status.Dirty && m.Version == status.Version {
+				state = "dirty"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, state)
+		}
+		for _, m := range status.Pending {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, "pending")
+		}
+		w.Flush()
+
+	case "force":
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return errUsage
+		}
+		version, err := strconv.Atoi(flags.Arg(0))
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %s", flags.Arg(0))
+		}
+		if err := db.ForceMigrationVersion(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("Forced schema to version %d\n", version)
+
+	default:
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
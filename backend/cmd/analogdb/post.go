@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evanofslack/analogdb"
+)
+
+const postUsage = `usage: analogdb post <get|delete|patch> [flags] <id>
+
+  get     print a post as json
+  delete  delete a post and its encoding in the vector database
+  patch   apply a json patch, read from -patch or stdin
+`
+
+func runPost(ctx context.Context, args []string) error {
+
+	action, args, err := subcommand(args, postUsage)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("post "+action, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), postUsage+"\nflags:\n")
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	patchJSON := flags.String("patch", "", "json patch to apply, read from stdin if not set")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+	id, err := strconv.Atoi(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid post id %s", flags.Arg(0))
+	}
+
+	a, err := newApp(*cfgPath)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	postService, err := a.postService()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "get":
+		post, err := postService.FindPostByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(post)
+
+	case "delete":
+		similarityService, err := a.similarityService()
+		if err != nil {
+			return err
+		}
+		if err := postService.DeletePost(ctx, id); err != nil {
+			return err
+		}
+		if err := similarityService.DeletePost(ctx, id); err != nil {
+			return fmt.Errorf("Deleted post %d from database but not vector database: %w", id, err)
+		}
+		fmt.Printf("Deleted post %d\n", id)
+
+	case "patch":
+		var r io.Reader = os.Stdin
+		if *patchJSON != "" {
+			r = strings.NewReader(*patchJSON)
+		}
+		var patch analogdb.PatchPost
+		if err := json.NewDecoder(r).Decode(&patch); err != nil {
+			return fmt.Errorf("Failed to parse patch: %w", err)
+		}
+		if err := postService.PatchPost(ctx, &patch, id); err != nil {
+			return err
+		}
+		fmt.Printf("Patched post %d\n", id)
+
+	default:
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+const reconcileUsage = `usage: analogdb reconcile [flags]
+
+Compares the posts in the database with the vector database, encoding
+posts that are missing, deleting encodings of posts that no longer exist
+and deleting extra encodings of posts encoded more than once.
+
+flags:
+`
+
+func runReconcile(ctx context.Context, args []string) error {
+
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), reconcileUsage)
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	batchSize := flags.Int("batch-size", defaultEncodeBatchSize, "number of posts encoded at a time")
+	dryRun := flags.Bool("dry-run", false, "only report the differences")
+	flags.Parse(args)
+
+	if *batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+
+	a, err := newApp(*cfgPath)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	similarityService, err := a.similarityService()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	result, err := similarityService.Reconcile(ctx, *batchSize, *dryRun)
+	if err != nil {
+		return fmt.Errorf("Failed to reconcile vector database: %w", err)
+	}
+
+	if *dryRun {
+		fmt.Printf("Missing from vector database (%d): %v\n", len(result.Encoded), result.Encoded)
+		fmt.Printf("Deleted from database (%d): %v\n", len(result.Deleted), result.Deleted)
+		fmt.Printf("Duplicated in vector database (%d): %v\n", len(result.Duplicated), result.Duplicated)
+		return nil
+	}
+	printEncodeFailures(result.Failed)
+	fmt.Printf("Encoded %d posts, deleted %d posts and %d duplicate pictures in %s\n", len(result.Encoded), len(result.Deleted), len(result.Duplicated), time.Since(start).Round(time.Millisecond))
+	if len(result.Failed) != 0 {
+		return fmt.Errorf("Failed to encode %d posts", len(result.Failed))
+	}
+	return nil
+}
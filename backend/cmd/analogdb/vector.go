@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+const vectorUsage = `usage: analogdb vector <status|migrate|reindex> [flags]
+
+  status   print the schema version and pending migrations of the vector database
+  migrate  create the picture class or add its missing properties
+  reindex  encode every post into a new picture class and swap it in,
+           applying migrations that change existing pictures
+`
+
+func runVector(ctx context.Context, args []string) error {
+
+	action, args, err := subcommand(args, vectorUsage)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("vector "+action, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), vectorUsage+"\nflags:\n")
+		flags.PrintDefaults()
+	}
+	cfgPath := flags.String("config", defaultConfigPath, "path to config.yml")
+	batchSize := flags.Int("batch-size", defaultEncodeBatchSize, "number of posts encoded at a time by reindex")
+	flags.Parse(args)
+
+	a, err := newApp(*cfgPath)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	dbVec, err := a.openVectorDB()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "status":
+		status, err := dbVec.SchemaStatus(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d\n", status.Version)
+		fmt.Printf("class:   %s\n", status.Class)
+		for _, m := range status.Pending {
+			reindex := ""
+			if m.Reindex {
+				reindex = " (needs reindex)"
+			}
+			fmt.Printf("pending: %d %s%s\n", m.Version, m.Description, reindex)
+		}
+
+	case "migrate":
+		if err := dbVec.Migrate(ctx); err != nil {
+			return err
+		}
+		fmt.Println("Migrated vector database")
+
+	case "reindex":
+		if *batchSize <= 0 {
+			return fmt.Errorf("batch size must be positive")
+		}
+		similarityService, err := a.similarityService()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		result, err := similarityService.Reindex(ctx, *batchSize, printEncodeProgress())
+		if err != nil {
+			return fmt.Errorf("Failed to reindex vector database: %w", err)
+		}
+		printEncodeFailures(result.Failed)
+		fmt.Printf("Reindexed %d posts from %s into %s in %s\n", result.Encoded, result.From, result.To, time.Since(start).Round(time.Second))
+
+	default:
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
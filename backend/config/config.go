@@ -1,22 +1,30 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/evanofslack/analogdb/imaging"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	App      `yaml:"app"`
-	DB       `yaml:"database"`
-	Redis    `yaml:"redis"`
-	VectorDB `yaml:"vector_database"`
-	HTTP     `yaml:"http"`
-	Log      `yaml:"logger"`
-	Auth     `yaml:"auth"`
-	Metrics  `yaml:"metrics"`
-	Tracing  `yaml:"tracing"`
+	App        `yaml:"app"`
+	DB         `yaml:"database"`
+	Redis      `yaml:"redis"`
+	VectorDB   `yaml:"vector_database"`
+	HTTP       `yaml:"http"`
+	Log        `yaml:"logger"`
+	Auth       `yaml:"auth"`
+	Metrics    `yaml:"metrics"`
+	Tracing    `yaml:"tracing"`
+	Image      `yaml:"image"`
+	Duplicates `yaml:"duplicates"`
+	Download   `yaml:"download"`
 }
 
 type App struct {
@@ -24,11 +32,14 @@ type App struct {
 	Version          string `yaml:"version" env:"APP_VERSION"`
 	Env              string `yaml:"env" env:"APP_ENV"`
 	CacheEnabled     bool   `yaml:"cache_enabled" env:"CACHE_ENABLED"`
+	CacheWarmEnabled bool   `yaml:"cache_warm_enabled" env:"CACHE_WARM_ENABLED"`
+	CacheWarmPages   int    `yaml:"cache_warm_pages" env:"CACHE_WARM_PAGES" env-default:"3"`
 	RateLimitEnabled bool   `yaml:"rate_limit_enabled" env:"RATE_LIMIT_ENABLED"`
 }
 
 type DB struct {
-	URL string `yaml:"url" env:"DATABASE_URL"`
+	URL            string `yaml:"url" env:"DATABASE_URL"`
+	MigrateOnStart bool   `yaml:"migrate_on_start" env:"DATABASE_MIGRATE_ON_START"`
 }
 
 type Redis struct {
@@ -66,6 +77,25 @@ type Tracing struct {
 	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT"`
 }
 
+type Image struct {
+	CacheDir    string   `yaml:"cache_dir" env:"IMAGE_CACHE_DIR" env-default:"/tmp/analogdb/images"`
+	CacheSizeMB int64    `yaml:"cache_size_mb" env:"IMAGE_CACHE_SIZE_MB" env-default:"512"`
+	Sizes       []string `yaml:"sizes" env:"IMAGE_SIZES" env-separator:","`
+}
+
+type Duplicates struct {
+	MaxDistance int  `yaml:"max_distance" env:"DUPLICATES_MAX_DISTANCE" env-default:"6"`
+	Reject      bool `yaml:"reject" env:"DUPLICATES_REJECT"`
+}
+
+type Download struct {
+	Timeout     time.Duration `yaml:"timeout" env:"DOWNLOAD_TIMEOUT" env-default:"30s"`
+	MaxSizeMB   int64         `yaml:"max_size_mb" env:"DOWNLOAD_MAX_SIZE_MB" env-default:"64"`
+	Retries     int           `yaml:"retries" env:"DOWNLOAD_RETRIES" env-default:"3"`
+	Backoff     time.Duration `yaml:"backoff" env:"DOWNLOAD_BACKOFF" env-default:"500ms"`
+	Concurrency int           `yaml:"concurrency" env:"DOWNLOAD_CONCURRENCY" env-default:"10"`
+}
+
 func New(path string) (*Config, error) {
 	cfg := &Config{}
 
@@ -80,3 +110,80 @@ func New(path string) (*Config, error) {
 	}
 	return cfg, nil
 }
+
+// Validate checks the config has everything needed to serve,
+// returning every problem found rather than only the first
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if cfg.DB.URL == "" {
+		errs = append(errs, errors.New("database url must be set"))
+	} else if _, err := url.Parse(cfg.DB.URL); err != nil {
+		errs = append(errs, fmt.Errorf("database url is invalid: %w", err))
+	}
+	if cfg.Redis.URL != "" {
+		if _, err := url.Parse(cfg.Redis.URL); err != nil {
+			errs = append(errs, fmt.Errorf("redis url is invalid: %w", err))
+		}
+	}
+	if cfg.VectorDB.Host == "" {
+		errs = append(errs, errors.New("vector database host must be set"))
+	}
+	if s := cfg.VectorDB.Scheme; s != "http" && s != "https" {
+		errs = append(errs, fmt.Errorf("vector database scheme %q must be http or https", s))
+	}
+	if err := validatePort(cfg.HTTP.Port); err != nil {
+		errs = append(errs, fmt.Errorf("http port: %w", err))
+	}
+	if cfg.Metrics.Enabled {
+		if err := validatePort(cfg.Metrics.Port); err != nil {
+			errs = append(errs, fmt.Errorf("metrics port: %w", err))
+		}
+	}
+	if cfg.Tracing.Enabled && cfg.Tracing.Endpoint == "" {
+		errs = append(errs, errors.New("tracing endpoint must be set when tracing is enabled"))
+	}
+	switch cfg.Log.Level {
+	case "debug", "info", "warn", "error", "fatal", "panic":
+	default:
+		errs = append(errs, fmt.Errorf("log level %q must be one of debug, info, warn, error, fatal, panic", cfg.Log.Level))
+	}
+	if (cfg.Auth.Username == "") != (cfg.Auth.Password == "") {
+		errs = append(errs, errors.New("auth username and password must be set together"))
+	}
+	if cfg.App.CacheWarmPages < 0 {
+		errs = append(errs, errors.New("cache warm pages must not be negative"))
+	}
+	if cfg.Image.CacheSizeMB < 0 {
+		errs = append(errs, errors.New("image cache size must not be negative"))
+	}
+	for _, size := range cfg.Image.Sizes {
+		if _, _, err := imaging.ParseSize(size); err != nil {
+			errs = append(errs, fmt.Errorf("image size: %w", err))
+		}
+	}
+	if d := cfg.Duplicates.MaxDistance; d < 0 || d > 64 {
+		errs = append(errs, fmt.Errorf("duplicates max distance %d must be between 0 and 64", d))
+	}
+	if cfg.Download.Timeout <= 0 {
+		errs = append(errs, errors.New("download timeout must be positive"))
+	}
+	if cfg.Download.MaxSizeMB <= 0 {
+		errs = append(errs, errors.New("download max size must be positive"))
+	}
+	if cfg.Download.Retries < 0 || cfg.Download.Backoff < 0 {
+		errs = append(errs, errors.New("download retries and backoff must not be negative"))
+	}
+	if cfg.Download.Concurrency <= 0 {
+		errs = append(errs, errors.New("download concurrency must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("%q is not a valid port", port)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package analogdb
+
+import "context"
+
+// DuplicateCluster is a group of posts whose images look alike,
+// most likely reposts of the same photo under different permalinks
+type DuplicateCluster struct {
+	PostIDs []int `json:"post_ids"`
+	// largest hamming distance between image hashes linking the cluster
+	Distance int `json:"distance"`
+}
+
+// DuplicateFilter are options used for listing duplicate clusters.
+// Clusters are ordered newest first, by the newest post of each cluster,
+// and the keyset is the newest post of the last cluster seen.
+type DuplicateFilter struct {
+	MaxDistance int
+	Limit       *int
+	Keyset      *int
+}
+
+// DuplicateService finds posts by the perceptual hash of their image
+type DuplicateService interface {
+	FindDuplicateClusters(ctx context.Context, filter *DuplicateFilter) ([]DuplicateCluster, int, error)
+	FindNearDuplicates(ctx context.Context, hash uint64, maxDistance int) ([]int, error)
+	LinkDuplicates(ctx context.Context, maxDistance int) (int, error)
+	SetImageHash(ctx context.Context, postID int, hash uint64) error
+	UnhashedPostIDs(ctx context.Context) ([]int, error)
+}
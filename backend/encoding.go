@@ -0,0 +1,34 @@
+package analogdb
+
+import (
+	"context"
+	"time"
+)
+
+// state of the embedding of a post in the vector DB
+const (
+	EncodingPending = "pending"
+	EncodingEncoded = "encoded"
+	EncodingFailed  = "failed"
+)
+
+// Encoding is the state of the embedding of a post
+type Encoding struct {
+	PostID int    `json:"post_id"`
+	Status string `json:"status"`
+	// why the last encode failed
+	Error string `json:"error,omitempty"`
+	// vector DB schema the post was last encoded with
+	SchemaVersion int        `json:"schema_version,omitempty"`
+	EncodedAt     *time.Time `json:"encoded_at,omitempty"`
+}
+
+// EncodingService records which posts have an embedding, so it's known
+// without asking the vector DB
+type EncodingService interface {
+	FindEncodingByID(ctx context.Context, postID int) (*Encoding, error)
+	MarkEncoded(ctx context.Context, postIDs []int, schemaVersion int) error
+	MarkFailed(ctx context.Context, failures []EncodeFailure, schemaVersion int) error
+	// posts not yet encoded with schemaVersion, including failed posts
+	OutdatedPostIDs(ctx context.Context, schemaVersion int) ([]int, error)
+}
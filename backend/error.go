@@ -11,6 +11,7 @@ const (
 	ERRNOTFOUND      = "not_found"
 	ERRUNAVAILABLE   = "service_unavailable"
 	ERRUNAUTHORIZED  = "unauthorized"
+	ERRCONFLICT      = "conflict"
 )
 
 type Error struct {
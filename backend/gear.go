@@ -0,0 +1,232 @@
+package analogdb
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GearSummary represents how many posts were shot on a film stock or camera
+type GearSummary struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type GearService interface {
+	FindFilms(ctx context.Context) (*[]GearSummary, error)
+	FindCameras(ctx context.Context) (*[]GearSummary, error)
+	ExtractGear(ctx context.Context) (*ExtractGearResult, error)
+}
+
+// ExtractGearResult summarizes re-extracting gear from all post titles
+type ExtractGearResult struct {
+	Posts   int `json:"posts"`
+	Films   int `json:"films"`
+	Cameras int `json:"cameras"`
+}
+
+// gearEntry is a single film stock or camera in the catalog,
+// along with all the ways it is commonly written in titles
+type gearEntry struct {
+	Name    string
+	Aliases []string
+}
+
+// filmCatalog is the curated list of film stocks extracted from titles.
+// Families (i.e. Kodak Portra) are only matched if no specific stock is.
+var filmCatalog = []gearEntry{
+	{"Kodak Portra 160", []string{"portra 160", "portra160"}},
+	{"Kodak Portra 400", []string{"portra 400", "portra400"}},
+	{"Kodak Portra 800", []string{"portra 800", "portra800"}},
+	{"Kodak Portra", []string{"portra"}},
+	{"Kodak Ektar 100", []string{"ektar 100", "ektar100", "ektar"}},
+	{"Kodak Gold 200", []string{"kodak gold 200", "gold 200", "gold200"}},
+	{"Kodak Ultramax 400", []string{"ultramax 400", "ultramax400", "ultramax", "ultra max"}},
+	{"Kodak ColorPlus 200", []string{"colorplus 200", "colorplus", "color plus"}},
+	{"Kodak Ektachrome E100", []string{"ektachrome e100", "ektachrome", "e100"}},
+	{"Kodak Tri-X 400", []string{"tri x 400", "trix 400", "tri x", "trix"}},
+	{"Kodak T-Max 100", []string{"t max 100", "tmax 100", "tmax100"}},
+	{"Kodak T-Max 400", []string{"t max 400", "tmax 400", "tmax400"}},
+	{"Kodak T-Max P3200", []string{"t max p3200", "tmax p3200", "tmax 3200", "p3200"}},
+	{"Kodak Vision3 50D", []string{"vision3 50d", "vision 3 50d", "50d"}},
+	{"Kodak Vision3 250D", []string{"vision3 250d", "vision 3 250d", "250d"}},
+	{"Kodak Vision3 500T", []string{"vision3 500t", "vision 3 500t", "500t"}},
+	{"Fujifilm Superia 400", []string{"superia 400", "superia x tra 400", "superia xtra 400", "xtra 400", "x tra 400"}},
+	{"Fujifilm Superia", []string{"superia"}},
+	{"Fujifilm C200", []string{"fuji c200", "fujicolor c200", "c200"}},
+	{"Fujifilm Fujicolor 200", []string{"fujicolor 200", "fuji 200"}},
+	{"Fujifilm Pro 400H", []string{"pro 400h", "pro400h", "400h"}},
+	{"Fujifilm Velvia 50", []string{"velvia 50", "velvia50"}},
+	{"Fujifilm Velvia 100", []string{"velvia 100", "velvia100"}},
+	{"Fujifilm Velvia", []string{"velvia"}},
+	{"Fujifilm Provia 100F", []string{"provia 100f", "provia"}},
+	{"Fujifilm Acros 100", []string{"acros 100", "acros ii", "acros"}},
+	{"Ilford HP5 Plus", []string{"hp5 plus", "hp5", "hp 5"}},
+	{"Ilford FP4 Plus", []string{"fp4 plus", "fp4", "fp 4"}},
+	{"Ilford Delta 100", []string{"delta 100"}},
+	{"Ilford Delta 400", []string{"delta 400"}},
+	{"Ilford Delta 3200", []string{"delta 3200"}},
+	{"Ilford Pan F Plus 50", []string{"pan f plus", "pan f 50", "pan f", "panf"}},
+	{"Ilford XP2 Super", []string{"xp2 super", "xp2"}},
+	{"Ilford SFX 200", []string{"sfx 200", "sfx"}},
+	{"Kentmere 100", []string{"kentmere 100"}},
+	{"Kentmere 400", []string{"kentmere 400"}},
+	{"CineStill 800T", []string{"cinestill 800t", "cinestill 800", "800t"}},
+	{"CineStill 50D", []string{"cinestill 50d"}},
+	{"CineStill 400D", []string{"cinestill 400d", "400d"}},
+	{"CineStill", []string{"cinestill"}},
+	{"Lomography Color Negative 100", []string{"lomography 100", "lomo 100"}},
+	{"Lomography Color Negative 400", []string{"lomography 400", "lomo 400"}},
+	{"Lomography Color Negative 800", []string{"lomography 800", "lomo 800"}},
+	{"LomoChrome Purple", []string{"lomochrome purple", "lomo purple"}},
+	{"Fomapan 100", []string{"fomapan 100", "foma 100"}},
+	{"Fomapan 200", []string{"fomapan 200", "foma 200"}},
+	{"Fomapan 400", []string{"fomapan 400", "foma 400"}},
+	{"Rollei Retro 400S", []string{"retro 400s", "rollei 400s"}},
+	{"Rollei RPX 400", []string{"rpx 400", "rpx400"}},
+}
+
+// cameraCatalog is the curated list of camera bodies extracted from titles.
+// Names that are ambiguous on their own (i.e. F3) require the brand.
+var cameraCatalog = []gearEntry{
+	{"Pentax K1000", []string{"k1000", "k 1000"}},
+	{"Pentax MX", []string{"pentax mx"}},
+	{"Pentax ME Super", []string{"me super"}},
+	{"Pentax Spotmatic", []string{"spotmatic"}},
+	{"Pentax 67", []string{"pentax 67", "pentax 6x7", "p67"}},
+	{"Canon AE-1 Program", []string{"ae 1 program", "ae1 program", "ae1p", "ae 1p"}},
+	{"Canon AE-1", []string{"ae 1", "ae1"}},
+	{"Canon A-1", []string{"canon a 1", "canon a1"}},
+	{"Canon F-1", []string{"canon f 1", "canon f1"}},
+	{"Canon EOS 3", []string{"eos 3"}},
+	{"Canon Canonet QL17", []string{"canonet ql17", "ql17", "canonet"}},
+	{"Nikon F3", []string{"nikon f3"}},
+	{"Nikon FM2", []string{"fm2", "fm 2"}},
+	{"Nikon FM", []string{"nikon fm"}},
+	{"Nikon FE2", []string{"fe2", "fe 2"}},
+	{"Nikon FE", []string{"nikon fe"}},
+	{"Nikon F100", []string{"f100"}},
+	{"Nikon F2", []string{"nikon f2"}},
+	{"Nikon FA", []string{"nikon fa"}},
+	{"Minolta X-700", []string{"x 700", "x700"}},
+	{"Minolta X-570", []string{"x 570", "x570"}},
+	{"Minolta SRT", []string{"srt 101", "srt101", "minolta srt"}},
+	{"Olympus OM-1", []string{"om 1", "om1", "om 1n"}},
+	{"Olympus OM-2", []string{"om 2", "om2", "om 2n"}},
+	{"Olympus Mju II", []string{"mju ii", "mju 2", "mju2", "stylus epic"}},
+	{"Olympus XA", []string{"olympus xa"}},
+	{"Leica M6", []string{"leica m6", "m6"}},
+	{"Leica M3", []string{"leica m3"}},
+	{"Leica MP", []string{"leica mp"}},
+	{"Mamiya 7", []string{"mamiya 7", "mamiya 7ii", "mamiya7"}},
+	{"Mamiya RB67", []string{"rb67", "rb 67"}},
+	{"Mamiya RZ67", []string{"rz67", "rz 67"}},
+	{"Mamiya 645", []string{"mamiya 645", "m645"}},
+	{"Hasselblad 500C/M", []string{"500cm", "500c m", "500 cm", "hasselblad 500"}},
+	{"Hasselblad", []string{"hasselblad", "hassy"}},
+	{"Contax T2", []string{"contax t2"}},
+	{"Contax G2", []string{"contax g2"}},
+	{"Yashica Mat-124G", []string{"yashica mat 124g", "mat 124g", "124g"}},
+	{"Yashica T4", []string{"yashica t4"}},
+	{"Yashica Electro 35", []string{"electro 35"}},
+	{"Rolleiflex", []string{"rolleiflex"}},
+	{"Fujifilm GW690", []string{"gw690", "gw 690"}},
+	{"Fujifilm GA645", []string{"ga645", "ga 645"}},
+	{"Bronica SQ-A", []string{"sq a", "sqa", "bronica sq"}},
+	{"Bronica ETRS", []string{"etrs", "etrsi"}},
+}
+
+// gearMatcher finds catalog entries in normalized text
+type gearMatcher struct {
+	aliases []string
+	names   map[string]string
+}
+
+func newGearMatcher(catalog []gearEntry) *gearMatcher {
+	m := &gearMatcher{names: make(map[string]string)}
+	for _, entry := range catalog {
+		for _, alias := range append([]string{entry.Name}, entry.Aliases...) {
+			alias = normalizeGearText(alias)
+			if _, exists := m.names[alias]; exists {
+				continue
+			}
+			m.names[alias] = entry.Name
+			m.aliases = append(m.aliases, alias)
+		}
+	}
+	// match the longest aliases first so specific entries win over families
+	sort.SliceStable(m.aliases, func(i, j int) bool {
+		return len(m.aliases[i]) > len(m.aliases[j])
+	})
+	return m
+}
+
+// extract returns the names of all entries found in text
+func (m *gearMatcher) extract(text string) []string {
+
+	// pad with spaces so aliases only match whole words
+	text = " " + normalizeGearText(text) + " "
+
+	found := []string{}
+	seen := make(map[string]bool)
+	for _, alias := range m.aliases {
+		padded := " " + alias + " "
+		if !strings.Contains(text, padded) {
+			continue
+		}
+		// blank out the match so shorter aliases can't match it again
+		text = strings.ReplaceAll(text, padded, " | ")
+		if name := m.names[alias]; !seen[name] {
+			seen[name] = true
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// lookup gets the catalog name of a film or camera, or
+// returns the input unchanged if it is not in the catalog
+func (m *gearMatcher) lookup(name string) string {
+	if canonical, ok := m.names[normalizeGearText(name)]; ok {
+		return canonical
+	}
+	return name
+}
+
+var (
+	filmMatcher   = newGearMatcher(filmCatalog)
+	cameraMatcher = newGearMatcher(cameraCatalog)
+)
+
+// ExtractFilms finds all film stocks named in a post title
+func ExtractFilms(title string) []string {
+	return filmMatcher.extract(title)
+}
+
+// ExtractCameras finds all cameras named in a post title
+func ExtractCameras(title string) []string {
+	return cameraMatcher.extract(title)
+}
+
+// LookupFilm resolves an alias like "portra400" to its catalog name
+func LookupFilm(name string) string {
+	return filmMatcher.lookup(name)
+}
+
+// LookupCamera resolves an alias like "ae1" to its catalog name
+func LookupCamera(name string) string {
+	return cameraMatcher.lookup(name)
+}
+
+// normalizeGearText lowercases and replaces punctuation with single spaces,
+// so "Tri-X" and "tri x" are written the same way
+func normalizeGearText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,151 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/evanofslack/analogdb"
+)
+
+const (
+	// images are analyzed at the size of the low rendition
+	analyzeSize = 720
+
+	// channels of a gray pixel differ by no more than compression noise
+	grayscaleTolerance = 12
+	// share of pixels allowed to have color, such as a colored watermark
+	grayscaleMaxColored = 0.01
+
+	// difference from the corner color that ends a border
+	borderThreshold = 50
+)
+
+// Analysis holds the attributes of a post computed from its image
+type Analysis struct {
+	Grayscale bool
+	Sprocket  bool
+	Colors    []analogdb.Color
+}
+
+// Analyze computes if img is grayscale, shows sprocket holes,
+// and the palette of its colors after trimming any border
+func Analyze(img image.Image, colorCount int) Analysis {
+
+	small := thumbnail(img, analyzeSize)
+	return Analysis{
+		Grayscale: IsGrayscale(small),
+		Sprocket:  HasSprocketHoles(small),
+		Colors:    Palette(RemoveBorder(small), colorCount),
+	}
+}
+
+// IsGrayscale reports if nearly every pixel of img has no color
+func IsGrayscale(img image.Image) bool {
+
+	b := img.Bounds()
+	total := b.Dx() * b.Dy()
+	if total == 0 {
+		return false
+	}
+	colored := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			hi := maxUint8(c.R, c.G, c.B)
+			lo := minUint8(c.R, c.G, c.B)
+			if hi-lo > grayscaleTolerance {
+				colored++
+			}
+		}
+	}
+	return float64(colored)/float64(total) <= grayscaleMaxColored
+}
+
+// RemoveBorder crops away a solid border the color of the top left pixel
+func RemoveBorder(img image.Image) image.Image {
+
+	b := img.Bounds()
+	if b.Empty() {
+		return img
+	}
+	bg := color.RGBAModel.Convert(img.At(b.Min.X, b.Min.Y)).(color.RGBA)
+
+	differs := func(x, y int) bool {
+		c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+		return absDiff(c.R, bg.R) > borderThreshold ||
+			absDiff(c.G, bg.G) > borderThreshold ||
+			absDiff(c.B, bg.B) > borderThreshold
+	}
+
+	crop := image.Rectangle{Min: b.Max, Max: b.Min}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !differs(x, y) {
+				continue
+			}
+			if x < crop.Min.X {
+				crop.Min.X = x
+			}
+			if y < crop.Min.Y {
+				crop.Min.Y = y
+			}
+			if x >= crop.Max.X {
+				crop.Max.X = x + 1
+			}
+			if y >= crop.Max.Y {
+				crop.Max.Y = y + 1
+			}
+		}
+	}
+	// a solid image has nothing to crop to
+	if crop.Empty() || crop == b {
+		return img
+	}
+
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return img
+	}
+	return sub.SubImage(crop)
+}
+
+// thumbnail shrinks img to fit within size, keeping smaller images as they are
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= size && b.Dy() <= size {
+		return img
+	}
+	if b.Dx() >= b.Dy() {
+		return Resize(img, size, 0)
+	}
+	return Resize(img, 0, size)
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func maxUint8(vs ...uint8) uint8 {
+	m := vs[0]
+	for _, v := range vs[1:] {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
+
+func minUint8(vs ...uint8) uint8 {
+	m := vs[0]
+	for _, v := range vs[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,181 @@
+package imaging
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DiskCache keeps encoded images on local disk, evicting the least recently
+// used once the files add up to more than the size cap. Files left by a
+// previous run are kept, oldest first in line for eviction.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	name string
+	size int64
+}
+
+// temp files are written next to the cache, then renamed into place
+const tempPrefix = ".tmp-"
+
+// NewDiskCache opens a cache in dir holding up to maxSize bytes
+func NewDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &DiskCache{
+		dir:     dir,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var infos []os.FileInfo
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(f.Name(), tempPrefix) {
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().After(infos[j].ModTime()) })
+	for _, info := range infos {
+		c.entries[info.Name()] = c.order.PushBack(&cacheEntry{name: info.Name(), size: info.Size()})
+		c.size += info.Size()
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+
+	return c, nil
+}
+
+// Get returns the cached data of key
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+
+	name := cacheName(key)
+
+	c.mu.Lock()
+	elem, ok := c.entries[name]
+	if ok {
+		c.order.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		c.mu.Lock()
+		c.remove(name)
+		c.mu.Unlock()
+		return nil, false
+	}
+	return data, true
+}
+
+// Set stores data as key, evicting older entries to stay under the size cap
+func (c *DiskCache) Set(key string, data []byte) error {
+
+	size := int64(len(data))
+	if size > c.maxSize {
+		return nil
+	}
+	name := cacheName(key)
+
+	tmp, err := os.CreateTemp(c.dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if elem, ok := c.entries[name]; ok {
+		entry := elem.Value.(*cacheEntry)
+		c.size += size - entry.size
+		entry.size = size
+		c.order.MoveToFront(elem)
+	} else {
+		c.entries[name] = c.order.PushFront(&cacheEntry{name: name, size: size})
+		c.size += size
+	}
+	c.evict()
+	return nil
+}
+
+// Size is the total bytes of cached files
+func (c *DiskCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// evict removes least recently used files until the cache fits, c.mu must be held
+func (c *DiskCache) evict() {
+	for c.size > c.maxSize {
+		elem := c.order.Back()
+		if elem == nil {
+			return
+		}
+		c.remove(elem.Value.(*cacheEntry).name)
+	}
+}
+
+// remove drops an entry and its file, c.mu must be held
+func (c *DiskCache) remove(name string) {
+	elem, ok := c.entries[name]
+	if !ok {
+		return
+	}
+	c.order.Remove(elem)
+	delete(c.entries, name)
+	c.size -= elem.Value.(*cacheEntry).size
+	os.Remove(filepath.Join(c.dir, name))
+}
+
+// cacheName is the file name of a key, hashed so any key is a safe name
+func cacheName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,222 @@
+package imaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+)
+
+const (
+	defaultDownloadTimeout     = 30 * time.Second
+	defaultDownloadRetries     = 3
+	defaultDownloadBackoff     = 500 * time.Millisecond
+	defaultDownloadConcurrency = 10
+
+	// longest wait between two attempts
+	maxDownloadBackoff = 30 * time.Second
+)
+
+// Downloader downloads images, retrying requests that fail for reasons
+// that may pass, such as timeouts or overloaded servers. It is safe for
+// concurrent use, and limits how many downloads run at once.
+type Downloader struct {
+	client   *http.Client
+	maxBytes int64
+	retries  int
+	backoff  time.Duration
+	slots    chan struct{}
+}
+
+// DownloaderOption configures a Downloader
+type DownloaderOption func(*Downloader)
+
+// WithDownloadTimeout bounds each attempt, including reading the body
+func WithDownloadTimeout(timeout time.Duration) DownloaderOption {
+	return func(d *Downloader) {
+		d.client.Timeout = timeout
+	}
+}
+
+// WithMaxDownloadSize rejects images larger than maxBytes
+func WithMaxDownloadSize(maxBytes int64) DownloaderOption {
+	return func(d *Downloader) {
+		d.maxBytes = maxBytes
+	}
+}
+
+// WithDownloadRetries retries a failed download up to retries times,
+// waiting about backoff before the first retry and twice as long each time after
+func WithDownloadRetries(retries int, backoff time.Duration) DownloaderOption {
+	return func(d *Downloader) {
+		d.retries = retries
+		d.backoff = backoff
+	}
+}
+
+// WithDownloadConcurrency limits how many downloads run at once,
+// always allowing at least one
+func WithDownloadConcurrency(concurrency int) DownloaderOption {
+	return func(d *Downloader) {
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		d.slots = make(chan struct{}, concurrency)
+	}
+}
+
+func NewDownloader(opts ...DownloaderOption) *Downloader {
+	d := &Downloader{
+		client:   &http.Client{Timeout: defaultDownloadTimeout},
+		maxBytes: maxFetchBytes,
+		retries:  defaultDownloadRetries,
+		backoff:  defaultDownloadBackoff,
+		slots:    make(chan struct{}, defaultDownloadConcurrency),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Concurrency is the most downloads that run at once
+func (d *Downloader) Concurrency() int {
+	return cap(d.slots)
+}
+
+// permanentError is a download that would fail the same way if retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Download reads the encoded image at url
+func (d *Downloader) Download(ctx context.Context, url string) ([]byte, error) {
+
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(d.retryDelay(attempt)):
+			}
+		}
+
+		var data []byte
+		if data, err = d.download(ctx, url); err == nil {
+			return data, nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("gave up after %d attempts: %w", d.retries+1, err)
+}
+
+// DownloadImage downloads the first image of labels found in images, falling
+// back to the next label when an image is missing or fails to download
+func (d *Downloader) DownloadImage(ctx context.Context, images []analogdb.Image, labels ...string) ([]byte, analogdb.Image, error) {
+
+	var errs []error
+	for _, label := range labels {
+		image, ok := analogdb.FindImage(images, label)
+		if !ok {
+			continue
+		}
+		data, err := d.Download(ctx, image.Url)
+		if err == nil {
+			return data, image, nil
+		}
+		if ctx.Err() != nil {
+			return nil, analogdb.Image{}, err
+		}
+		errs = append(errs, fmt.Errorf("%s image: %w", label, err))
+	}
+	if len(errs) == 0 {
+		return nil, analogdb.Image{}, &permanentError{fmt.Errorf("no image labeled %s", strings.Join(labels, ", "))}
+	}
+	return nil, analogdb.Image{}, errors.Join(errs...)
+}
+
+// download makes a single attempt, holding one of the download slots
+func (d *Downloader) download(ctx context.Context, url string) ([]byte, error) {
+
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-d.slots }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch code := resp.StatusCode; {
+	case code == http.StatusOK:
+	case code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500:
+		return nil, fmt.Errorf("%s returned status %d", url, code)
+	default:
+		return nil, &permanentError{fmt.Errorf("%s returned status %d", url, code)}
+	}
+
+	// servers that don't know the type are checked once the body is read
+	contentType := resp.Header.Get("Content-Type")
+	sniff := contentType == "" || strings.HasPrefix(contentType, "application/octet-stream")
+	if !sniff && !isImageType(contentType) {
+		return nil, &permanentError{fmt.Errorf("%s has content type %s, not an image", url, contentType)}
+	}
+	if resp.ContentLength > d.maxBytes {
+		return nil, &permanentError{fmt.Errorf("%s is larger than %d bytes", url, d.maxBytes)}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, d.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > d.maxBytes {
+		return nil, &permanentError{fmt.Errorf("%s is larger than %d bytes", url, d.maxBytes)}
+	}
+	if sniff && !isImageType(http.DetectContentType(data)) {
+		return nil, &permanentError{fmt.Errorf("%s is not an image", url)}
+	}
+	return data, nil
+}
+
+// retryDelay doubles with every attempt, jittered so downloads that
+// failed together don't all retry at the same moment
+func (d *Downloader) retryDelay(attempt int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempt && delay < maxDownloadBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxDownloadBackoff {
+		delay = maxDownloadBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func isImageType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasPrefix(mediaType, "image/")
+}
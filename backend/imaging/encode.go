@@ -0,0 +1,60 @@
+// Package imaging decodes, resizes and encodes the pictures of posts
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	// register decoders of every format posts are uploaded in
+	_ "golang.org/x/image/webp"
+	_ "image/gif"
+)
+
+// formats images can be encoded in
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+const jpegQuality = 85
+
+// ParseFormat normalizes a format name or file extension
+func ParseFormat(s string) (string, error) {
+	switch s {
+	case "jpg", "jpeg":
+		return FormatJPEG, nil
+	case "png":
+		return FormatPNG, nil
+	case "webp":
+		return FormatWebP, nil
+	}
+	return "", fmt.Errorf("unsupported image format %q", s)
+}
+
+// ContentType is the media type of a format
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// Decode reads a jpeg, png, gif or webp image
+func Decode(r io.Reader) (image.Image, string, error) {
+	return image.Decode(r)
+}
+
+// Encode writes img in format
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	case FormatPNG:
+		enc := png.Encoder{CompressionLevel: png.BestSpeed}
+		return enc.Encode(w, img)
+	case FormatWebP:
+		return EncodeWebP(w, img)
+	}
+	return fmt.Errorf("unsupported image format %q", format)
+}
@@ -0,0 +1,30 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+)
+
+// limits on downloaded images, guarding against huge files and decompression bombs
+const (
+	maxFetchBytes  = 64 << 20
+	maxFetchPixels = 100_000_000
+)
+
+// DecodeBytes decodes a downloaded image, refusing images with
+// so many pixels that decoding them would exhaust memory
+func DecodeBytes(data []byte) (image.Image, error) {
+
+	// check dimensions before decoding allocates the whole image
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxFetchPixels {
+		return nil, fmt.Errorf("%dx%d is too large to decode", cfg.Width, cfg.Height)
+	}
+
+	img, _, err := Decode(bytes.NewReader(data))
+	return img, err
+}
@@ -0,0 +1,33 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+)
+
+// DHash is a 64 bit perceptual difference hash of img. Each bit compares
+// the brightness of neighboring cells of a 9x8 grid, so resized, recompressed
+// or slightly edited copies of a photo hash within a few bits of each other.
+func DHash(img image.Image) uint64 {
+
+	small := Resize(thumbnail(img, analyzeSize), 9, 8)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := color.GrayModel.Convert(small.At(x, y)).(color.Gray).Y
+			right := color.GrayModel.Convert(small.At(x+1, y)).(color.Gray).Y
+			hash <<= 1
+			if left < right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance is the number of bits that differ between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
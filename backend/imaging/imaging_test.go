@@ -0,0 +1,454 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+	"golang.org/x/image/webp"
+)
+
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x ^ y), A: uint8(255 - x%3)})
+		}
+	}
+	return img
+}
+
+func TestEncodeWebP(t *testing.T) {
+	t.Run("lossless", func(t *testing.T) {
+		for _, img := range []*image.NRGBA{
+			testImage(37, 21),
+			testImage(1, 1),
+			image.NewNRGBA(image.Rect(0, 0, 8, 3)),
+		} {
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Bounds() != img.Bounds() {
+				t.Fatalf("got bounds %v want %v", decoded.Bounds(), img.Bounds())
+			}
+			for y := 0; y < img.Rect.Dy(); y++ {
+				for x := 0; x < img.Rect.Dx(); x++ {
+					got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					if want := img.NRGBAAt(x, y); got != want && want.A != 0 {
+						t.Fatalf("pixel %d,%d got %v want %v", x, y, got, want)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestResize(t *testing.T) {
+	img := testImage(300, 200)
+	tests := []struct {
+		w, h         int
+		wantW, wantH int
+	}{
+		{w: 150, h: 0, wantW: 150, wantH: 100},
+		{w: 0, h: 50, wantW: 75, wantH: 50},
+		{w: 100, h: 100, wantW: 100, wantH: 100},
+	}
+	for _, tt := range tests {
+		got := Resize(img, tt.w, tt.h).Bounds()
+		if got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+			t.Errorf("resize to %dx%d got %dx%d want %dx%d", tt.w, tt.h, got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewDiskCache(dir, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := cache.Set(fmt.Sprint(i), make([]byte, 100)); err != nil {
+			t.Fatal(err)
+		}
+		// keep the first entry recently used
+		cache.Get("0")
+	}
+	if _, ok := cache.Get("1"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if _, ok := cache.Get("0"); !ok {
+		t.Error("expected recently used entry to be kept")
+	}
+	if size := cache.Size(); size != 200 {
+		t.Errorf("got size %d want 200", size)
+	}
+
+	// entries survive a restart
+	reopened, err := NewDiskCache(dir, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("2"); !ok {
+		t.Error("expected entry to be kept on disk")
+	}
+}
+
+func fill(img *image.NRGBA, r image.Rectangle, c color.NRGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+}
+
+func TestIsGrayscale(t *testing.T) {
+	gray := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 50; x++ {
+			v := uint8(x * 5)
+			gray.SetNRGBA(x, y, color.NRGBA{R: v, G: v + 2, B: v, A: 0xff})
+		}
+	}
+	if !IsGrayscale(gray) {
+		t.Error("expected gray image to be grayscale")
+	}
+	if IsGrayscale(testImage(50, 50)) {
+		t.Error("expected color image not to be grayscale")
+	}
+}
+
+func TestPalette(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	fill(img, img.Rect, white)
+	fill(img, image.Rect(0, 0, 100, 25), red)
+
+	colors := Palette(img, 5)
+	if len(colors) != 5 {
+		t.Fatalf("got %d colors want 5", len(colors))
+	}
+	if got := colors[0]; got.Hex != "#ffffff" || got.Css != "white" || got.Percent != 0.75 {
+		t.Errorf("got first color %+v want white at 0.75", got)
+	}
+	if got := colors[1]; got.Hex != "#ff0000" || got.Html != "red" || got.Percent != 0.25 {
+		t.Errorf("got second color %+v want red at 0.25", got)
+	}
+	for _, c := range colors[2:] {
+		if c.Percent != 0 {
+			t.Errorf("expected padded color to have no share, got %+v", c)
+		}
+	}
+}
+
+func TestRemoveBorder(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 80))
+	fill(img, img.Rect, color.NRGBA{A: 0xff})
+	fill(img, image.Rect(10, 5, 90, 70), color.NRGBA{R: 200, G: 180, B: 160, A: 0xff})
+
+	if got, want := RemoveBorder(img).Bounds(), image.Rect(10, 5, 90, 70); got != want {
+		t.Errorf("got bounds %v want %v", got, want)
+	}
+}
+
+func TestHasSprocketHoles(t *testing.T) {
+	// a smooth sky over a dark horizon
+	scene := image.NewNRGBA(image.Rect(0, 0, 600, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 600; x++ {
+			scene.SetNRGBA(x, y, color.NRGBA{R: uint8(100 + x/6), G: uint8(150 + y/4), B: 220, A: 0xff})
+		}
+	}
+	fill(scene, image.Rect(0, 300, 600, 400), color.NRGBA{R: 30, G: 40, B: 20, A: 0xff})
+	if HasSprocketHoles(scene) {
+		t.Error("expected image without holes not to have sprocket holes")
+	}
+
+	// dark film rebate along the top with bright holes every 40 pixels
+	film := image.NewNRGBA(scene.Rect)
+	copy(film.Pix, scene.Pix)
+	fill(film, image.Rect(0, 0, 600, 50), color.NRGBA{R: 20, G: 15, B: 10, A: 0xff})
+	for x := 20; x < 580; x += 40 {
+		fill(film, image.Rect(x, 15, x+20, 35), color.NRGBA{R: 240, G: 240, B: 230, A: 0xff})
+	}
+	if !HasSprocketHoles(film) {
+		t.Error("expected image with a row of holes to have sprocket holes")
+	}
+}
+
+func TestDHash(t *testing.T) {
+	scene := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			scene.SetNRGBA(x, y, color.NRGBA{R: uint8(x * y / 500), G: uint8((x + y) / 3), B: uint8(y / 2), A: 0xff})
+		}
+	}
+	fill(scene, image.Rect(50, 50, 150, 200), color.NRGBA{R: 250, G: 250, B: 250, A: 0xff})
+
+	// a smaller copy saved as jpeg, as a repost would be
+	var buf bytes.Buffer
+	if err := Encode(&buf, Resize(scene, 200, 0), FormatJPEG); err != nil {
+		t.Fatal(err)
+	}
+	repost, _, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := HammingDistance(DHash(scene), DHash(repost)); d > 4 {
+		t.Errorf("got distance %d between copies of an image, want at most 4", d)
+	}
+	if d := HammingDistance(DHash(scene), DHash(testImage(400, 300))); d < 10 {
+		t.Errorf("got distance %d between different images, want at least 10", d)
+	}
+}
+
+// exifSegment builds an APP1 segment with a big endian tiff
+// holding a camera make, model, exposure and iso
+func exifSegment() []byte {
+	be := binary.BigEndian
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+
+	entry := func(tag, kind uint16, count, value uint32) []byte {
+		b := make([]byte, 12)
+		be.PutUint16(b, tag)
+		be.PutUint16(b[2:], kind)
+		be.PutUint32(b[4:], count)
+		be.PutUint32(b[8:], value)
+		return b
+	}
+
+	// ifd0 at 8 with 3 entries, then the exif ifd with 2 entries, then values
+	ifd0Size := 2 + 3*12 + 4
+	exifAt := 8 + ifd0Size
+	exifSize := 2 + 2*12 + 4
+	valuesAt := exifAt + exifSize
+	make_, model := "Nikon\x00", "F3HP\x00"
+	modelAt := valuesAt + len(make_)
+	exposureAt := modelAt + len(model)
+
+	ifd0 := []byte{0, 3}
+	ifd0 = append(ifd0, entry(tagMake, 2, uint32(len(make_)), uint32(valuesAt))...)
+	ifd0 = append(ifd0, entry(tagModel, 2, uint32(len(model)), uint32(modelAt))...)
+	ifd0 = append(ifd0, entry(tagExifIFD, 4, 1, uint32(exifAt))...)
+	ifd0 = append(ifd0, 0, 0, 0, 0)
+
+	exif := []byte{0, 2}
+	exif = append(exif, entry(tagExposureTime, 5, 1, uint32(exposureAt))...)
+	exif = append(exif, entry(tagISO, 3, 1, 400<<16)...)
+	exif = append(exif, 0, 0, 0, 0)
+
+	exposure := make([]byte, 8)
+	be.PutUint32(exposure, 1)
+	be.PutUint32(exposure[4:], 250)
+
+	tiff = append(tiff, ifd0...)
+	tiff = append(tiff, exif...)
+	tiff = append(tiff, make_...)
+	tiff = append(tiff, model...)
+	tiff = append(tiff, exposure...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	be.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func TestReadMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImage(16, 16), FormatJPEG); err != nil {
+		t.Fatal(err)
+	}
+	plain := buf.Bytes()
+
+	xmp := append([]byte("http://ns.adobe.com/xap/1.0/\x00"), `<x:xmpmeta><rdf:Description xmp:CreatorTool="SilverFast 9"/></x:xmpmeta>`...)
+	xmpSegment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(xmpSegment[2:], uint16(len(xmp)+2))
+	xmpSegment = append(xmpSegment, xmp...)
+
+	// metadata segments follow the start of image marker
+	data := append([]byte{0xff, 0xd8}, exifSegment()...)
+	data = append(data, xmpSegment...)
+	data = append(data, plain[2:]...)
+
+	m := ReadMetadata(data)
+	if !m.HasExif {
+		t.Fatal("expected exif to be read")
+	}
+	if m.CameraMake != "Nikon" || m.CameraModel != "F3HP" {
+		t.Errorf("got camera %q %q want Nikon F3HP", m.CameraMake, m.CameraModel)
+	}
+	if m.ExposureTime != "1/250" || m.ISO != 400 {
+		t.Errorf("got exposure %q iso %d want 1/250 iso 400", m.ExposureTime, m.ISO)
+	}
+	if m.Software != "SilverFast 9" {
+		t.Errorf("got software %q from xmp want SilverFast 9", m.Software)
+	}
+	if m.FileSize != int64(len(data)) {
+		t.Errorf("got file size %d want %d", m.FileSize, len(data))
+	}
+
+	if m := ReadMetadata(plain); m.HasExif || m.CameraMake != "" {
+		t.Errorf("expected no exif in a plain jpeg, got %+v", m)
+	}
+	if _, _, err := Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("expected jpeg with metadata to decode, got %s", err)
+	}
+}
+
+func TestDownloader(t *testing.T) {
+
+	var png bytes.Buffer
+	if err := Encode(&png, testImage(8, 8), FormatPNG); err != nil {
+		t.Fatal(err)
+	}
+
+	var flaky, missing int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/flaky.png", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&flaky, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(png.Bytes())
+	})
+	mux.HandleFunc("/missing.png", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&missing, 1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/page.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png.Bytes())
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	d := NewDownloader(WithDownloadRetries(3, time.Millisecond), WithDownloadConcurrency(2))
+
+	t.Run("retries", func(t *testing.T) {
+		data, err := d.Download(ctx, srv.URL+"/flaky.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, png.Bytes()) || flaky != 3 {
+			t.Errorf("got %d bytes after %d attempts, want %d bytes after 3", len(data), flaky, png.Len())
+		}
+	})
+	t.Run("permanent", func(t *testing.T) {
+		if _, err := d.Download(ctx, srv.URL+"/missing.png"); err == nil || missing != 1 {
+			t.Errorf("got err %v after %d attempts, want error after 1", err, missing)
+		}
+		if _, err := d.Download(ctx, srv.URL+"/page.png"); err == nil {
+			t.Error("downloaded a page that isn't an image")
+		}
+	})
+	t.Run("size", func(t *testing.T) {
+		small := NewDownloader(WithMaxDownloadSize(int64(png.Len() - 1)))
+		if _, err := small.Download(ctx, srv.URL+"/image.png"); err == nil {
+			t.Error("downloaded an image over the size limit")
+		}
+	})
+	t.Run("concurrency", func(t *testing.T) {
+		serial := NewDownloader(WithDownloadConcurrency(0))
+		if serial.Concurrency() != 1 {
+			t.Fatalf("got concurrency %d, want 1", serial.Concurrency())
+		}
+		if _, err := serial.Download(ctx, srv.URL+"/image.png"); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("fallback", func(t *testing.T) {
+		images := []analogdb.Image{
+			{Label: analogdb.ImageLow, Url: srv.URL + "/image.png"},
+			{Label: analogdb.ImageMedium, Url: srv.URL + "/missing.png"},
+		}
+		_, image, err := d.DownloadImage(ctx, images, analogdb.ImageMedium, analogdb.ImageHigh, analogdb.ImageLow)
+		if err != nil || image.Label != analogdb.ImageLow {
+			t.Errorf("got %s image, err %v, want %s", image.Label, err, analogdb.ImageLow)
+		}
+		if _, _, err := d.DownloadImage(ctx, images, analogdb.ImageRaw); err == nil {
+			t.Error("downloaded an image the post doesn't have")
+		}
+	})
+}
+
+func TestAnalyzePost(t *testing.T) {
+
+	var png bytes.Buffer
+	if err := Encode(&png, testImage(64, 48), FormatPNG); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png.Bytes())
+	})
+	mux.HandleFunc("/missing.png", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	d := NewDownloader(WithDownloadRetries(0, 0))
+
+	t.Run("computes missing attributes", func(t *testing.T) {
+		post := &analogdb.CreatePost{Images: []analogdb.Image{{Label: analogdb.ImageRaw, Url: srv.URL + "/image.png"}}}
+		if !PostNeedsAnalysis(post) {
+			t.Fatal("a post without colors needs analysis")
+		}
+		if err := d.AnalyzePost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+		if post.Hash == nil || post.Metadata == nil {
+			t.Error("want post hashed and its metadata read")
+		}
+		if len(post.Colors) != postColorCount || post.Grayscale == nil || post.Sprocket == nil {
+			t.Errorf("want %d colors, grayscale and sprocket, got %d colors", postColorCount, len(post.Colors))
+		}
+		if PostNeedsAnalysis(post) {
+			t.Error("an analyzed post shouldn't need analysis")
+		}
+	})
+	t.Run("keeps given attributes", func(t *testing.T) {
+		grayscale, sprocket := true, true
+		colors := make([]analogdb.Color, postColorCount)
+		post := &analogdb.CreatePost{
+			Images:    []analogdb.Image{{Label: analogdb.ImageRaw, Url: srv.URL + "/image.png"}},
+			Colors:    colors,
+			Grayscale: &grayscale,
+			Sprocket:  &sprocket,
+		}
+		if err := d.AnalyzePost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+		if post.Hash == nil || !*post.Grayscale || !*post.Sprocket || &post.Colors[0] != &colors[0] {
+			t.Error("want post hashed, keeping its colors, grayscale and sprocket")
+		}
+	})
+	t.Run("missing image", func(t *testing.T) {
+		post := &analogdb.CreatePost{Images: []analogdb.Image{{Label: analogdb.ImageRaw, Url: srv.URL + "/missing.png"}}}
+		if err := d.AnalyzePost(ctx, post); err == nil || post.Hash != nil {
+			t.Errorf("got err %v, want error and no hash", err)
+		}
+	})
+}
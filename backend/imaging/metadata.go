@@ -0,0 +1,445 @@
+package imaging
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/evanofslack/analogdb"
+)
+
+// EXIF tags read from the image and exif directories
+const (
+	tagMake         = 0x010f
+	tagModel        = 0x0110
+	tagSoftware     = 0x0131
+	tagExifIFD      = 0x8769
+	tagExposureTime = 0x829a
+	tagFNumber      = 0x829d
+	tagISO          = 0x8827
+	tagFocalLength  = 0x920a
+	tagLensMake     = 0xa433
+	tagLensModel    = 0xa434
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	xmpHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	iccHeader  = []byte("ICC_PROFILE\x00")
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// ReadMetadata reads the EXIF, XMP and color profile of an encoded jpeg,
+// png or webp. Missing or malformed metadata is left empty, so any image
+// has at least its file size.
+func ReadMetadata(data []byte) *analogdb.PostMetadata {
+
+	m := &analogdb.PostMetadata{FileSize: int64(len(data))}
+
+	var exif, xmp, icc []byte
+	switch {
+	case len(data) > 2 && data[0] == 0xff && data[1] == 0xd8:
+		exif, xmp, icc = jpegMetadata(data)
+	case bytes.HasPrefix(data, pngHeader):
+		exif, xmp, icc = pngMetadata(data)
+	case len(data) > 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		exif, xmp, icc = webpMetadata(data)
+	}
+
+	if exif != nil {
+		readExif(m, bytes.TrimPrefix(exif, exifHeader))
+	}
+	if xmp != nil {
+		readXMP(m, string(xmp))
+	}
+	if icc != nil {
+		m.ColorProfile = iccDescription(icc)
+	}
+	return m
+}
+
+// jpegMetadata finds the metadata segments before the image data
+func jpegMetadata(data []byte) (exif, xmp, icc []byte) {
+
+	var iccChunks [][]byte
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			break
+		}
+		marker := data[i+1]
+		// standalone markers have no length
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) || marker == 0xff {
+			i += 2
+			continue
+		}
+		// start of scan or end of image, no metadata follows
+		if marker == 0xda || marker == 0xd9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			break
+		}
+		payload := data[i+4 : i+2+length]
+
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(payload, exifHeader) && exif == nil:
+			exif = payload
+		case marker == 0xe1 && bytes.HasPrefix(payload, xmpHeader) && xmp == nil:
+			xmp = payload[len(xmpHeader):]
+		case marker == 0xe2 && bytes.HasPrefix(payload, iccHeader) && len(payload) > len(iccHeader)+2:
+			// profiles are split over segments, numbered from 1
+			chunk := payload[len(iccHeader)+2:]
+			seq := int(payload[len(iccHeader)])
+			for len(iccChunks) < seq {
+				iccChunks = append(iccChunks, nil)
+			}
+			if seq > 0 {
+				iccChunks[seq-1] = chunk
+			}
+		}
+		i += 2 + length
+	}
+	if len(iccChunks) > 0 {
+		icc = bytes.Join(iccChunks, nil)
+	}
+	return exif, xmp, icc
+}
+
+// pngMetadata finds the metadata chunks of a png
+func pngMetadata(data []byte) (exif, xmp, icc []byte) {
+
+	for i := len(pngHeader); i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		kind := string(data[i+4 : i+8])
+		if length < 0 || i+12+length > len(data) {
+			break
+		}
+		chunk := data[i+8 : i+8+length]
+
+		switch kind {
+		case "eXIf":
+			exif = chunk
+		case "iCCP":
+			// profile name, compression method, then the zlib compressed profile
+			if name, rest, ok := bytes.Cut(chunk, []byte{0}); ok && len(rest) > 1 {
+				if profile, err := inflate(rest[1:]); err == nil {
+					icc = profile
+				} else {
+					icc = name
+				}
+			}
+		case "iTXt":
+			// keyword, compression flag and method, language, translated keyword, text
+			keyword, rest, ok := bytes.Cut(chunk, []byte{0})
+			if ok && string(keyword) == "XML:com.adobe.xmp" && len(rest) > 2 && rest[0] == 0 {
+				parts := bytes.SplitN(rest[2:], []byte{0}, 3)
+				if len(parts) == 3 {
+					xmp = parts[2]
+				}
+			}
+		case "IDAT", "IEND":
+			return exif, xmp, icc
+		}
+		i += 12 + length
+	}
+	return exif, xmp, icc
+}
+
+// webpMetadata finds the metadata chunks of an extended webp
+func webpMetadata(data []byte) (exif, xmp, icc []byte) {
+
+	for i := 12; i+8 <= len(data); {
+		kind := string(data[i : i+4])
+		length := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if length < 0 || i+8+length > len(data) {
+			break
+		}
+		chunk := data[i+8 : i+8+length]
+
+		switch kind {
+		case "EXIF":
+			exif = chunk
+		case "XMP ":
+			xmp = chunk
+		case "ICCP":
+			icc = chunk
+		}
+		// chunks are padded to an even length
+		i += 8 + length + length&1
+	}
+	return exif, xmp, icc
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(io.LimitReader(r, 1<<20))
+}
+
+// tiffReader reads the directories of the tiff structure holding exif
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type tiffEntry struct {
+	kind  uint16
+	value []byte
+}
+
+// readExif fills in metadata from the image and exif directories
+func readExif(m *analogdb.PostMetadata, data []byte) {
+
+	if len(data) < 8 {
+		return
+	}
+	t := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return
+	}
+
+	ifd0 := t.directory(t.order.Uint32(data[4:]))
+	if len(ifd0) == 0 {
+		return
+	}
+	m.HasExif = true
+
+	m.CameraMake = t.ascii(ifd0[tagMake])
+	m.CameraModel = t.ascii(ifd0[tagModel])
+	m.Software = t.ascii(ifd0[tagSoftware])
+
+	pointer, ok := ifd0[tagExifIFD]
+	if !ok {
+		return
+	}
+	exif := t.directory(t.uint(pointer))
+
+	if v, ok := t.rational(exif[tagExposureTime]); ok {
+		m.ExposureTime = formatExposure(v)
+	}
+	if v, ok := t.rational(exif[tagFNumber]); ok {
+		m.FNumber = math.Round(v*10) / 10
+	}
+	if v, ok := t.rational(exif[tagFocalLength]); ok {
+		m.FocalLength = math.Round(v*10) / 10
+	}
+	if iso, ok := exif[tagISO]; ok {
+		m.ISO = int(t.uint(iso))
+	}
+	lens := t.ascii(exif[tagLensModel])
+	if lensMake := t.ascii(exif[tagLensMake]); lensMake != "" && lens != "" && !strings.HasPrefix(lens, lensMake) {
+		lens = lensMake + " " + lens
+	}
+	m.Lens = lens
+}
+
+// directory reads the entries of the directory at offset by tag
+func (t *tiffReader) directory(offset uint32) map[uint16]tiffEntry {
+
+	entries := make(map[uint16]tiffEntry)
+	start := int(offset)
+	if start <= 0 || start+2 > len(t.data) {
+		return entries
+	}
+	count := int(t.order.Uint16(t.data[start:]))
+	for i := 0; i < count; i++ {
+		pos := start + 2 + i*12
+		if pos+12 > len(t.data) {
+			break
+		}
+		tag := t.order.Uint16(t.data[pos:])
+		kind := t.order.Uint16(t.data[pos+2:])
+		n := t.order.Uint32(t.data[pos+4:])
+
+		size := tiffTypeSize(kind) * int(n)
+		if size <= 0 || size > len(t.data) {
+			continue
+		}
+		// values of up to four bytes are held in the entry itself
+		var value []byte
+		if size <= 4 {
+			value = t.data[pos+8 : pos+8+size]
+		} else {
+			at := int(t.order.Uint32(t.data[pos+8:]))
+			if at < 0 || at+size > len(t.data) {
+				continue
+			}
+			value = t.data[at : at+size]
+		}
+		entries[tag] = tiffEntry{kind: kind, value: value}
+	}
+	return entries
+}
+
+func tiffTypeSize(kind uint16) int {
+	switch kind {
+	case 1, 2, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case 4, 9:
+		return 4
+	case 5, 10:
+		return 8
+	}
+	return 0
+}
+
+func (t *tiffReader) ascii(e tiffEntry) string {
+	if e.kind != 2 {
+		return ""
+	}
+	s, _, _ := strings.Cut(string(e.value), "\x00")
+	return strings.TrimSpace(s)
+}
+
+func (t *tiffReader) uint(e tiffEntry) uint32 {
+	switch {
+	case e.kind == 3 && len(e.value) >= 2:
+		return uint32(t.order.Uint16(e.value))
+	case (e.kind == 4 || e.kind == 9) && len(e.value) >= 4:
+		return t.order.Uint32(e.value)
+	}
+	return 0
+}
+
+func (t *tiffReader) rational(e tiffEntry) (float64, bool) {
+	if (e.kind != 5 && e.kind != 10) || len(e.value) < 8 {
+		return 0, false
+	}
+	num, den := t.order.Uint32(e.value), t.order.Uint32(e.value[4:])
+	if den == 0 {
+		return 0, false
+	}
+	if e.kind == 10 {
+		return float64(int32(num)) / float64(int32(den)), true
+	}
+	return float64(num) / float64(den), true
+}
+
+// formatExposure writes an exposure in seconds the way cameras show it
+func formatExposure(seconds float64) string {
+	if seconds <= 0 {
+		return ""
+	}
+	if seconds < 1 {
+		return fmt.Sprintf("1/%d", int(math.Round(1/seconds)))
+	}
+	return fmt.Sprintf("%gs", math.Round(seconds*10)/10)
+}
+
+// readXMP fills in what the exif left out from an XMP packet,
+// which scanners and editors often write in place of exif
+func readXMP(m *analogdb.PostMetadata, xmp string) {
+	fill := func(field *string, names ...string) {
+		for _, name := range names {
+			if *field != "" {
+				return
+			}
+			*field = xmpValue(xmp, name)
+		}
+	}
+	fill(&m.CameraMake, "tiff:Make")
+	fill(&m.CameraModel, "tiff:Model")
+	fill(&m.Lens, "exifEX:LensModel", "aux:Lens")
+	fill(&m.Software, "xmp:CreatorTool", "tiff:Software")
+}
+
+// xmpPatterns match each property read from XMP
+var xmpPatterns = func() map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp)
+	for _, name := range []string{"tiff:Make", "tiff:Model", "exifEX:LensModel", "aux:Lens", "xmp:CreatorTool", "tiff:Software"} {
+		quoted := regexp.QuoteMeta(name)
+		patterns[name] = regexp.MustCompile(quoted + `="([^"]*)"|<` + quoted + `>([^<]*)</` + quoted + `>`)
+	}
+	return patterns
+}()
+
+// xmpValue reads a property written as an attribute or as an element
+func xmpValue(xmp, name string) string {
+	re, ok := xmpPatterns[name]
+	if !ok {
+		return ""
+	}
+	match := re.FindStringSubmatch(xmp)
+	if match == nil {
+		return ""
+	}
+	value := match[1]
+	if value == "" {
+		value = match[2]
+	}
+	return strings.TrimSpace(value)
+}
+
+// iccDescription reads the description of an ICC color profile
+func iccDescription(icc []byte) string {
+
+	if len(icc) < 132 {
+		// a png profile that couldn't be inflated is only its name
+		return strings.TrimSpace(string(icc))
+	}
+	count := int(binary.BigEndian.Uint32(icc[128:]))
+	for i := 0; i < count; i++ {
+		pos := 132 + i*12
+		if pos+12 > len(icc) {
+			break
+		}
+		if string(icc[pos:pos+4]) != "desc" {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint32(icc[pos+4:]))
+		size := int(binary.BigEndian.Uint32(icc[pos+8:]))
+		if offset < 0 || size < 12 || offset+size > len(icc) {
+			return ""
+		}
+		return iccText(icc[offset : offset+size])
+	}
+	return ""
+}
+
+// iccText decodes a v2 text description or the first v4 localized string
+func iccText(tag []byte) string {
+	switch string(tag[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		if n <= 0 || 12+n > len(tag) {
+			return ""
+		}
+		s, _, _ := strings.Cut(string(tag[12:12+n]), "\x00")
+		return strings.TrimSpace(s)
+	case "mluc":
+		if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:]) == 0 {
+			return ""
+		}
+		length := int(binary.BigEndian.Uint32(tag[20:]))
+		offset := int(binary.BigEndian.Uint32(tag[24:]))
+		if offset < 0 || length < 0 || offset+length > len(tag) {
+			return ""
+		}
+		units := make([]uint16, length/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(tag[offset+2*i:])
+		}
+		return strings.TrimSpace(strings.TrimRight(string(utf16.Decode(units)), "\x00"))
+	}
+	return ""
+}
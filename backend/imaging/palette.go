@@ -0,0 +1,264 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/evanofslack/analogdb"
+)
+
+// colors closer than this distance in CIELAB are grouped into one
+const paletteTolerance = 20.0
+
+// groups of css colors shown under a single html name,
+// so similar shades are found together when filtering by color
+var cssOverrides = map[string]string{
+	"maroon":               "red",
+	"firebrick":            "red",
+	"salmon":               "red",
+	"darkred":              "red",
+	"lightsalmon":          "orange",
+	"orange":               "orange",
+	"darkorange":           "orange",
+	"orangered":            "orange",
+	"coral":                "orange",
+	"mediumseagreen":       "green",
+	"seagreen":             "green",
+	"yellowgreen":          "green",
+	"greenyellow":          "green",
+	"steelblue":            "teal",
+	"lightsteelblue":       "teal",
+	"mediumaquamarine":     "teal",
+	"darkcyan":             "teal",
+	"darkseagreen":         "teal",
+	"paleturquoise":        "teal",
+	"cadetblue":            "teal",
+	"cornflowerblue":       "teal",
+	"lightblue":            "teal",
+	"skyblue":              "teal",
+	"lightskyblue":         "teal",
+	"sienna":               "brown",
+	"chocolate":            "brown",
+	"rosybrown":            "brown",
+	"saddlebrown":          "brown",
+	"darkkhaki":            "brown",
+	"darksalmon":           "brown",
+	"brown":                "brown",
+	"burlywood":            "tan",
+	"bisque":               "tan",
+	"antiquewhite":         "tan",
+	"blanchedalmond":       "tan",
+	"peru":                 "tan",
+	"sandybrown":           "tan",
+	"papayawhip":           "tan",
+	"tan":                  "tan",
+	"navajowhite":          "tan",
+	"moccasin":             "tan",
+	"peachpuff":            "tan",
+	"wheat":                "tan",
+	"khaki":                "tan",
+	"darkgray":             "gray",
+	"dimgray":              "gray",
+	"thistle":              "gray",
+	"silver":               "gray",
+	"lightslategray":       "gray",
+	"darkslategray":        "gray",
+	"gainsboro":            "gray",
+	"lightyellow":          "yellow",
+	"lightgoldenrodyellow": "yellow",
+	"lemonchiffon":         "yellow",
+	"goldenrod":            "yellow",
+	"darkolivegreen":       "olive",
+	"olivedrab":            "olive",
+	"darkslateblue":        "navy",
+	"midnightblue":         "navy",
+	"violet":               "purple",
+	"lightcoral":           "purple",
+	"lightpink":            "purple",
+	"royalblue":            "purple",
+	"seashell":             "white",
+	"snow":                 "white",
+}
+
+var htmlOverrides = map[string]string{
+	"silver":  "gray",
+	"fuchsia": "purple",
+	"blue":    "teal",
+	"aqua":    "teal",
+}
+
+// Palette extracts the count most common colors of img, grouping similar
+// shades together. Percents are of every pixel in the image. Images with
+// fewer distinct colors repeat their last color with no share of the image.
+func Palette(img image.Image, count int) []analogdb.Color {
+
+	bins := colorBins(img)
+	total := 0
+	for _, bin := range bins {
+		total += bin.pixels
+	}
+	if total == 0 || count <= 0 {
+		return nil
+	}
+
+	// loosen grouping for images with only a few shades
+	var groups []colorBin
+	for tolerance := paletteTolerance; ; tolerance /= 2 {
+		groups = groupColors(bins, tolerance)
+		if len(groups) >= count || tolerance < 1 {
+			break
+		}
+	}
+
+	colors := make([]analogdb.Color, 0, count)
+	for i := 0; i < count; i++ {
+		if i >= len(groups) {
+			last := colors[len(colors)-1]
+			last.Percent = 0
+			colors = append(colors, last)
+			continue
+		}
+		colors = append(colors, namedPaletteColor(groups[i].color, float64(groups[i].pixels)/float64(total)))
+	}
+	return colors
+}
+
+// colorBin is a group of pixels of about the same color
+type colorBin struct {
+	color  color.RGBA
+	lab    [3]float64
+	pixels int
+}
+
+// colorBins counts pixels by color, with 5 bits per channel so
+// shades that differ only by noise or compression are counted together
+func colorBins(img image.Image) []colorBin {
+
+	type sum struct{ r, g, b, n int }
+	sums := make(map[int]*sum)
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			key := int(c.R>>3)<<10 | int(c.G>>3)<<5 | int(c.B>>3)
+			s, ok := sums[key]
+			if !ok {
+				s = &sum{}
+				sums[key] = s
+			}
+			s.r += int(c.R)
+			s.g += int(c.G)
+			s.b += int(c.B)
+			s.n++
+		}
+	}
+
+	bins := make([]colorBin, 0, len(sums))
+	for _, s := range sums {
+		c := color.RGBA{R: uint8(s.r / s.n), G: uint8(s.g / s.n), B: uint8(s.b / s.n), A: 0xff}
+		bins = append(bins, colorBin{color: c, lab: rgbToLab(c), pixels: s.n})
+	}
+	sort.Slice(bins, func(i, j int) bool {
+		if bins[i].pixels != bins[j].pixels {
+			return bins[i].pixels > bins[j].pixels
+		}
+		return bins[i].color.R < bins[j].color.R ||
+			bins[i].color.R == bins[j].color.R && (bins[i].color.G < bins[j].color.G ||
+				bins[i].color.G == bins[j].color.G && bins[i].color.B < bins[j].color.B)
+	})
+	return bins
+}
+
+// groupColors merges each color into the most common color within tolerance,
+// returning groups ordered by their share of pixels
+func groupColors(bins []colorBin, tolerance float64) []colorBin {
+
+	var groups []colorBin
+	for _, bin := range bins {
+		merged := false
+		for i := range groups {
+			if labDistance(groups[i].lab, bin.lab) < tolerance {
+				groups[i].pixels += bin.pixels
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			groups = append(groups, bin)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].pixels > groups[j].pixels })
+	return groups
+}
+
+func namedPaletteColor(c color.RGBA, percent float64) analogdb.Color {
+
+	named := analogdb.Color{
+		Hex:     fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B),
+		Css:     nearestColor(c, cssColors),
+		Html:    nearestColor(c, htmlColors),
+		Percent: math.Round(percent*1e8) / 1e8,
+	}
+
+	// navy and purple are rare enough to keep as they are
+	if named.Html == "navy" || named.Html == "purple" {
+		return named
+	}
+	if html, ok := cssOverrides[named.Css]; ok {
+		named.Html = html
+	}
+	if html, ok := htmlOverrides[named.Html]; ok {
+		named.Html = html
+	}
+	return named
+}
+
+// nearestColor is the name of the closest color in rgb
+func nearestColor(c color.RGBA, colors []namedColor) string {
+	best, bestDist := "", math.MaxInt
+	for _, named := range colors {
+		dr := int(c.R) - int(named.r)
+		dg := int(c.G) - int(named.g)
+		db := int(c.B) - int(named.b)
+		if dist := dr*dr + dg*dg + db*db; dist < bestDist {
+			best, bestDist = named.name, dist
+		}
+	}
+	return best
+}
+
+// rgbToLab converts an sRGB color to CIELAB under a D65 white point
+func rgbToLab(c color.RGBA) [3]float64 {
+
+	linear := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.04045 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	r, g, b := linear(c.R), linear(c.G), linear(c.B)
+
+	x := (0.4124*r + 0.3576*g + 0.1805*b) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := (0.0193*r + 0.1192*g + 0.9505*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// labDistance is the CIE76 color difference
+func labDistance(a, b [3]float64) float64 {
+	dl, da, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
@@ -0,0 +1,63 @@
+package imaging
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/evanofslack/analogdb"
+)
+
+// number of colors in the palette of a post
+const postColorCount = 5
+
+// the image of a post is downloaded once, for both its metadata and analysis.
+// smaller renditions are usually stripped of their metadata, so the original
+// is preferred, falling back to the low rendition just to analyze it.
+var postImageLabels = []string{analogdb.ImageRaw, analogdb.ImageHigh, analogdb.ImageLow}
+
+// PostNeedsAnalysis is whether a post is missing its colors, grayscale or
+// sprocket, so it can't be created unless its image is analyzed
+func PostNeedsAnalysis(post *analogdb.CreatePost) bool {
+	return len(post.Colors) == 0 || post.Grayscale == nil || post.Sprocket == nil
+}
+
+// AnalyzePost downloads the image of a post once to hash it for finding
+// duplicates, read its metadata, and compute its colors, grayscale and
+// sprocket when they are missing. A post that didn't need analysis keeps
+// its attributes if the image can't be downloaded or decoded, only
+// without a hash or metadata.
+func (d *Downloader) AnalyzePost(ctx context.Context, post *analogdb.CreatePost) error {
+
+	data, source, err := d.DownloadImage(ctx, post.Images, postImageLabels...)
+	if err != nil {
+		return fmt.Errorf("failed to download post image: %w", err)
+	}
+
+	if source.Label != analogdb.ImageLow {
+		post.Metadata = ReadMetadata(data)
+	}
+
+	img, err := DecodeBytes(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode post image %s: %w", source.Url, err)
+	}
+
+	hash := DHash(img)
+	post.Hash = &hash
+
+	if !PostNeedsAnalysis(post) {
+		return nil
+	}
+	analysis := Analyze(img, postColorCount)
+
+	if len(post.Colors) == 0 {
+		post.Colors = analysis.Colors
+	}
+	if post.Grayscale == nil {
+		post.Grayscale = &analysis.Grayscale
+	}
+	if post.Sprocket == nil {
+		post.Sprocket = &analysis.Sprocket
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// Resize scales src to w x h. If either dimension is zero it follows the
+// aspect ratio of src, otherwise src is cropped around its center to the
+// aspect ratio of w x h before scaling so the result is never distorted.
+func Resize(src image.Image, w, h int) image.Image {
+
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw == 0 || sh == 0 || (w == 0 && h == 0) {
+		return src
+	}
+
+	switch {
+	case w == 0:
+		w = max(1, sw*h/sh)
+	case h == 0:
+		h = max(1, sh*w/sw)
+	}
+
+	// crop the larger side to match the requested aspect ratio
+	crop := b
+	if sw*h > sh*w {
+		cw := sh * w / h
+		crop.Min.X += (sw - cw) / 2
+		crop.Max.X = crop.Min.X + cw
+	} else if sw*h < sh*w {
+		ch := sw * h / w
+		crop.Min.Y += (sh - ch) / 2
+		crop.Max.Y = crop.Min.Y + ch
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+	return dst
+}
+
+// ParseSize parses a size formatted as {width}x{height}, either
+// dimension may be 0 to follow the aspect ratio of the source
+func ParseSize(size string) (int, int, error) {
+	var w, h int
+	_, err := fmt.Sscanf(size, "%dx%d", &w, &h)
+	if err != nil || w < 0 || h < 0 || w+h == 0 || fmt.Sprintf("%dx%d", w, h) != size {
+		return 0, 0, fmt.Errorf("%q must be formatted as {width}x{height}", size)
+	}
+	return w, h, nil
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
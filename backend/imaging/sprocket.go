@@ -0,0 +1,155 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Sprocket shots expose the film past the frame, so a row of evenly spaced
+// sprocket holes runs along an edge of the image. Lines parallel to each edge
+// are scanned for a contrasting pattern that repeats at a steady period.
+const (
+	// share of the image scanned in from each edge
+	sprocketDepth = 0.2
+	// fewest holes along an edge to count it
+	sprocketMinHoles = 5
+	// period of holes as a share of the edge length
+	sprocketMinPeriod = 0.02
+	sprocketMaxPeriod = 0.2
+	// holes must repeat this evenly, as a coefficient of variation
+	sprocketMaxVariation = 0.15
+	// luminance difference between holes and film
+	sprocketMinContrast = 60
+	// lines that must show holes, so a single noisy line isn't enough
+	sprocketMinLines = 3
+)
+
+// HasSprocketHoles reports if an edge of img shows a row of sprocket holes
+func HasSprocketHoles(img image.Image) bool {
+
+	gray := image.NewGray(img.Bounds())
+	b := gray.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			gray.Set(x, y, img.At(x, y))
+		}
+	}
+
+	w, h := b.Dx(), b.Dy()
+	depthY := int(float64(h) * sprocketDepth)
+	depthX := int(float64(w) * sprocketDepth)
+
+	row := func(y int) []uint8 {
+		off := gray.PixOffset(b.Min.X, y)
+		return gray.Pix[off : off+w]
+	}
+	col := func(x int) []uint8 {
+		line := make([]uint8, h)
+		for y := 0; y < h; y++ {
+			line[y] = gray.GrayAt(x, b.Min.Y+y).Y
+		}
+		return line
+	}
+
+	edges := []func(i int) []uint8{
+		func(i int) []uint8 { return row(b.Min.Y + i) },
+		func(i int) []uint8 { return row(b.Max.Y - 1 - i) },
+		func(i int) []uint8 { return col(b.Min.X + i) },
+		func(i int) []uint8 { return col(b.Max.X - 1 - i) },
+	}
+	depths := []int{depthY, depthY, depthX, depthX}
+
+	for e, line := range edges {
+		found := 0
+		for i := 0; i < depths[e]; i++ {
+			if periodicHoles(line(i)) {
+				found++
+			}
+			if found >= sprocketMinLines {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// periodicHoles reports if a line of luminance alternates between
+// holes and film at a steady period, with holes either lighter or darker
+func periodicHoles(line []uint8) bool {
+
+	if len(line) == 0 {
+		return false
+	}
+	sorted := append([]uint8(nil), line...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	lo := int(sorted[len(sorted)/10])
+	hi := int(sorted[len(sorted)*9/10])
+	if hi-lo < sprocketMinContrast {
+		return false
+	}
+	threshold := uint8((lo + hi) / 2)
+
+	for _, bright := range []bool{true, false} {
+
+		// start and width of every run on the hole side of the threshold
+		var starts, widths []int
+		inRun := false
+		for i, v := range line {
+			hole := (v > threshold) == bright
+			if hole && !inRun {
+				starts = append(starts, i)
+				widths = append(widths, 0)
+			}
+			if hole {
+				widths[len(widths)-1]++
+			}
+			inRun = hole
+		}
+		// runs cut off by the ends of the line aren't whole holes
+		if len(starts) > 0 && starts[0] == 0 {
+			starts, widths = starts[1:], widths[1:]
+		}
+		if n := len(starts); n > 0 && starts[n-1]+widths[n-1] == len(line) {
+			starts, widths = starts[:n-1], widths[:n-1]
+		}
+		if len(starts) < sprocketMinHoles {
+			continue
+		}
+
+		periods := make([]float64, len(starts)-1)
+		for i := range periods {
+			periods[i] = float64(starts[i+1] - starts[i])
+		}
+		sizes := make([]float64, len(widths))
+		for i, w := range widths {
+			sizes[i] = float64(w)
+		}
+
+		mean, variation := meanVariation(periods)
+		if mean < sprocketMinPeriod*float64(len(line)) || mean > sprocketMaxPeriod*float64(len(line)) {
+			continue
+		}
+		if _, sizeVariation := meanVariation(sizes); variation <= sprocketMaxVariation && sizeVariation <= 2*sprocketMaxVariation {
+			return true
+		}
+	}
+	return false
+}
+
+// meanVariation is the mean and coefficient of variation of values
+func meanVariation(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0, math.Inf(1)
+	}
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq/float64(len(values))) / mean
+}
@@ -0,0 +1,324 @@
+package imaging
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// The encoder writes lossless VP8L bitstreams without transforms or
+// backward references: each pixel is four prefix coded channels. Files
+// are larger than a tuned encoder would make, but every decoder reads
+// them and it needs nothing outside of the standard library.
+
+const (
+	vp8lSignature  = 0x2f
+	vp8lMaxSize    = 1 << 14
+	maxCodeLength  = 15
+	maxCodeLenCode = 7
+
+	// literals, length prefixes and no color cache
+	greenAlphabet = 256 + 24
+)
+
+// order code lengths of the code length code are written in
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// EncodeWebP writes img as a lossless webp
+func EncodeWebP(w io.Writer, img image.Image) error {
+
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width <= 0 || height <= 0 || width > vp8lMaxSize || height > vp8lMaxSize {
+		return errors.New("webp: image dimensions out of range")
+	}
+
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok || nrgba.Rect.Min != (image.Point{}) {
+		nrgba = image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(nrgba, nrgba.Rect, img, b.Min, draw.Src)
+	}
+
+	// histogram of every channel, in the order they are coded
+	var green, red, blue, alpha [256]int
+	opaque := true
+	for y := 0; y < height; y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+width*4]
+		for x := 0; x < len(row); x += 4 {
+			red[row[x]]++
+			green[row[x+1]]++
+			blue[row[x+2]]++
+			alpha[row[x+3]]++
+			if row[x+3] != 0xff {
+				opaque = false
+			}
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.writeBits(vp8lSignature, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if opaque {
+		bw.writeBits(0, 1)
+	} else {
+		bw.writeBits(1, 1)
+	}
+	bw.writeBits(0, 3) // version
+
+	bw.writeBits(0, 1) // no transforms
+	bw.writeBits(0, 1) // no color cache
+	bw.writeBits(0, 1) // no meta prefix codes
+
+	greenFreq := make([]int, greenAlphabet)
+	copy(greenFreq, green[:])
+	codes := [4]*prefixCode{
+		bw.writePrefixCode(greenFreq),
+		bw.writePrefixCode(red[:]),
+		bw.writePrefixCode(blue[:]),
+		bw.writePrefixCode(alpha[:]),
+	}
+	// distance code, unused without backward references
+	bw.writePrefixCode(make([]int, 40))
+
+	for y := 0; y < height; y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+width*4]
+		for x := 0; x < len(row); x += 4 {
+			codes[0].write(bw, row[x+1])
+			codes[1].write(bw, row[x])
+			codes[2].write(bw, row[x+2])
+			codes[3].write(bw, row[x+3])
+		}
+	}
+	data := bw.flush()
+
+	// riff container with a single VP8L chunk, padded to an even size
+	pad := len(data) & 1
+	buf := bufio.NewWriter(w)
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(4+8+len(data)+pad))
+	buf.WriteString("WEBPVP8L")
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if pad == 1 {
+		buf.WriteByte(0)
+	}
+	return buf.Flush()
+}
+
+// bitWriter packs bits least significant first
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (bw *bitWriter) writeBits(v uint32, n uint) {
+	bw.acc |= uint64(v) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+func (bw *bitWriter) flush() []byte {
+	if bw.nbits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nbits = 0, 0
+	}
+	return bw.buf
+}
+
+// prefixCode is a canonical huffman code, with codes bit reversed
+// so they can be written least significant bit first
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+func newPrefixCode(lengths []int) *prefixCode {
+	pc := &prefixCode{lengths: lengths, codes: make([]uint32, len(lengths))}
+
+	var count [maxCodeLength + 1]int
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+	var next [maxCodeLength + 2]uint32
+	code := uint32(0)
+	for l := 1; l <= maxCodeLength; l++ {
+		code = (code + uint32(count[l-1])) << 1
+		next[l] = code
+	}
+	for sym, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		pc.codes[sym] = reverseBits(next[l], l)
+		next[l]++
+	}
+	return pc
+}
+
+func (pc *prefixCode) write(bw *bitWriter, sym byte) {
+	if l := pc.lengths[sym]; l > 0 {
+		bw.writeBits(pc.codes[sym], uint(l))
+	}
+}
+
+func reverseBits(v uint32, n int) uint32 {
+	var r uint32
+	for i := 0; i < n; i++ {
+		r = r<<1 | v&1
+		v >>= 1
+	}
+	return r
+}
+
+// writePrefixCode writes the code for a histogram and returns it. Symbols
+// that never occur get no code, a lone symbol is coded with zero bits.
+func (bw *bitWriter) writePrefixCode(freq []int) *prefixCode {
+
+	var used []int
+	for sym, f := range freq {
+		if f > 0 {
+			used = append(used, sym)
+		}
+	}
+
+	// simple codes hold up to two symbols below 256
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		lengths := make([]int, len(freq))
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		bw.writeBits(1, 1)
+		bw.writeBits(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.writeBits(0, 1)
+			bw.writeBits(uint32(used[0]), 1)
+		} else {
+			bw.writeBits(1, 1)
+			bw.writeBits(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.writeBits(uint32(used[1]), 8)
+			lengths[used[0]], lengths[used[1]] = 1, 1
+		}
+		return newPrefixCode(lengths)
+	}
+
+	lengths := huffmanLengths(freq, maxCodeLength)
+
+	// the code lengths are themselves prefix coded
+	var lenFreq [19]int
+	for _, l := range lengths {
+		lenFreq[l]++
+	}
+	lenLengths := huffmanLengths(lenFreq[:], maxCodeLenCode)
+	lenCode := newPrefixCode(lenLengths)
+
+	bw.writeBits(0, 1)
+	bw.writeBits(19-4, 4)
+	for _, sym := range codeLengthOrder {
+		bw.writeBits(uint32(lenLengths[sym]), 3)
+	}
+	bw.writeBits(0, 1) // lengths of every symbol follow
+	for _, l := range lengths {
+		lenCode.write(bw, byte(l))
+	}
+	return newPrefixCode(lengths)
+}
+
+// huffmanLengths computes code lengths of a complete prefix code no longer
+// than maxLen. Frequencies are flattened until the tree is shallow enough.
+// At least two symbols always get a code, so every code is complete.
+func huffmanLengths(freq []int, maxLen int) []int {
+
+	f := make([]int, len(freq))
+	copy(f, freq)
+
+	n := 0
+	for _, v := range f {
+		if v > 0 {
+			n++
+		}
+	}
+	for sym := 0; n < 2 && sym < len(f); sym++ {
+		if f[sym] == 0 {
+			f[sym] = 1
+			n++
+		}
+	}
+
+	for {
+		lengths := huffmanTree(f)
+		max := 0
+		for _, l := range lengths {
+			if l > max {
+				max = l
+			}
+		}
+		if max <= maxLen {
+			return lengths
+		}
+		for i, v := range f {
+			if v > 0 {
+				f[i] = (v + 1) / 2
+			}
+		}
+	}
+}
+
+type huffmanNode struct {
+	freq        int
+	sym         int
+	left, right *huffmanNode
+}
+
+func huffmanTree(freq []int) []int {
+
+	var nodes []*huffmanNode
+	for sym, f := range freq {
+		if f > 0 {
+			nodes = append(nodes, &huffmanNode{freq: f, sym: sym})
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].freq < nodes[j].freq })
+
+	// two queue merge, the merged queue is already sorted
+	var merged []*huffmanNode
+	pop := func() *huffmanNode {
+		if len(merged) == 0 || (len(nodes) > 0 && nodes[0].freq <= merged[0].freq) {
+			n := nodes[0]
+			nodes = nodes[1:]
+			return n
+		}
+		n := merged[0]
+		merged = merged[1:]
+		return n
+	}
+	for len(nodes)+len(merged) > 1 {
+		a, b := pop(), pop()
+		merged = append(merged, &huffmanNode{freq: a.freq + b.freq, left: a, right: b})
+	}
+
+	lengths := make([]int, len(freq))
+	var walk func(n *huffmanNode, depth int)
+	walk = func(n *huffmanNode, depth int) {
+		if n.left == nil {
+			lengths[n.sym] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(merged[0], 0)
+	return lengths
+}
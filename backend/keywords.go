@@ -1,7 +1,13 @@
 package analogdb
 
-import "context"
+import (
+	"context"
+	"strings"
+	"unicode"
+)
 
+// minimum length of a normalized keyword
+const minKeywordLength = 2
 
 // Keyword represents a single word/tag for a post
 type Keyword struct {
@@ -10,15 +16,226 @@ type Keyword struct {
 }
 
 type KeywordService interface {
-	// FindKeywords(ctx context.Context, filter *KeywordFilter) ([]string, error)
+	FindKeywords(ctx context.Context, filter *KeywordFilter) (*[]KeywordSummary, error)
+	FindKeywordByWord(ctx context.Context, word string) (*KeywordDetail, error)
+	FindRelatedKeywords(ctx context.Context, word string, limit int) (*[]RelatedKeyword, error)
 	GetKeywordSummary(ctx context.Context, limit int) (*[]KeywordSummary, error)
+	FindKeywordSynonyms(ctx context.Context) (*[]KeywordSynonym, error)
+	CreateKeywordSynonym(ctx context.Context, synonym *KeywordSynonym) error
+	DeleteKeywordSynonym(ctx context.Context, synonym string) error
+	FindBlacklistedKeywords(ctx context.Context) (*[]string, error)
+	CreateBlacklistedKeyword(ctx context.Context, word string) error
+	DeleteBlacklistedKeyword(ctx context.Context, word string) error
+	RenormalizeKeywords(ctx context.Context) (*RenormalizeResult, error)
 }
 
 type KeywordFilter struct {
-	Limit *int
+	Limit  *int
+	Prefix *string
 }
 
 type KeywordSummary struct {
 	Word  string `json:"word"`
 	Count int    `json:"count"`
 }
+
+// KeywordDetail describes how a single keyword is used across posts
+type KeywordDetail struct {
+	Word               string         `json:"word"`
+	Count              int            `json:"count"`
+	AverageWeight      float64        `json:"average_weight"`
+	WeightDistribution []WeightBucket `json:"weight_distribution"`
+}
+
+// WeightBucket is the number of times a keyword
+// was assigned a weight in the range [Min, Max)
+type WeightBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// RelatedKeyword is a keyword that appears on the same posts as another,
+// ranked by pointwise mutual information of the two keywords
+type RelatedKeyword struct {
+	Word  string  `json:"word"`
+	Count int     `json:"count"`
+	PMI   float64 `json:"pmi"`
+}
+
+// KeywordSynonym maps a synonym to the keyword it is normalized to
+type KeywordSynonym struct {
+	Synonym string `json:"synonym"`
+	Word    string `json:"word"`
+}
+
+// RenormalizeResult summarizes re-normalizing all existing keywords
+type RenormalizeResult struct {
+	Posts   int `json:"posts"`
+	Updated int `json:"updated"`
+}
+
+// KeywordRules normalize keywords so the same concept is always stored
+// and queried as the same word. Keywords are lowercased, stripped of
+// punctuation and lemmatized, synonyms are mapped to a single word
+// and blacklisted words are dropped.
+type KeywordRules struct {
+	synonyms  map[string]string
+	blacklist map[string]bool
+}
+
+func NewKeywordRules(synonyms []KeywordSynonym, blacklist []string) *KeywordRules {
+	rules := &KeywordRules{
+		synonyms:  make(map[string]string, len(synonyms)),
+		blacklist: make(map[string]bool, len(blacklist)),
+	}
+	for _, s := range synonyms {
+		rules.synonyms[strings.ToLower(strings.TrimSpace(s.Synonym))] = strings.ToLower(strings.TrimSpace(s.Word))
+	}
+	// blacklist the normalized form so plurals etc. are also matched
+	for _, word := range blacklist {
+		rules.blacklist[cleanKeyword(word)] = true
+	}
+	return rules
+}
+
+// NormalizeWord normalizes a single word. Returns false if
+// the word is blacklisted or too short after normalization.
+func (rules *KeywordRules) NormalizeWord(word string) (string, bool) {
+
+	word = strings.ToLower(strings.TrimSpace(word))
+
+	// synonyms may contain punctuation (i.e. b&w), so check before cleaning
+	if synonym, ok := rules.synonym(word); ok {
+		word = synonym
+	} else {
+		word = cleanKeyword(word)
+		if synonym, ok := rules.synonym(word); ok {
+			word = synonym
+		}
+	}
+
+	if len(word) < minKeywordLength {
+		return word, false
+	}
+	if rules != nil && rules.blacklist[word] {
+		return word, false
+	}
+	return word, true
+}
+
+// NormalizeKeywords normalizes each keyword, dropping blacklisted
+// keywords and merging duplicates by keeping the highest weight.
+func (rules *KeywordRules) NormalizeKeywords(keywords []Keyword) []Keyword {
+
+	normalized := make([]Keyword, 0, len(keywords))
+	seen := make(map[string]int, len(keywords))
+
+	for _, kw := range keywords {
+		word, ok := rules.NormalizeWord(kw.Word)
+		if !ok {
+			continue
+		}
+		if i, exists := seen[word]; exists {
+			if kw.Weight > normalized[i].Weight {
+				normalized[i].Weight = kw.Weight
+			}
+			continue
+		}
+		seen[word] = len(normalized)
+		normalized = append(normalized, Keyword{Word: word, Weight: kw.Weight})
+	}
+	return normalized
+}
+
+func (rules *KeywordRules) synonym(word string) (string, bool) {
+	if rules == nil {
+		return "", false
+	}
+	synonym, ok := rules.synonyms[word]
+	return synonym, ok
+}
+
+// cleanKeyword lowercases, strips everything but letters
+// and numbers, and lemmatizes a single word
+func cleanKeyword(word string) string {
+	word = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, word)
+	return lemmatize(word)
+}
+
+// words that look plural but should not be changed
+var lemmaExceptions = map[string]bool{
+	"series":  true,
+	"species": true,
+	"lens":    true,
+	"glass":   true,
+	"canvas":  true,
+	"atlas":   true,
+	"chaos":   true,
+	"news":    true,
+	"bus":     true,
+	"gas":     true,
+	"yes":     true,
+	"its":     true,
+	"this":    true,
+	"always":  true,
+	"perhaps": true,
+}
+
+// plurals the suffix rules get wrong, mostly
+// words whose singular ends in -ie or -se
+var lemmaIrregulars = map[string]string{
+	"movies":     "movie",
+	"cookies":    "cookie",
+	"selfies":    "selfie",
+	"zombies":    "zombie",
+	"hippies":    "hippie",
+	"rookies":    "rookie",
+	"hoodies":    "hoodie",
+	"brownies":   "brownie",
+	"calories":   "calorie",
+	"prairies":   "prairie",
+	"lenses":     "lens",
+	"buses":      "bus",
+	"gases":      "gas",
+	"canvases":   "canvas",
+	"atlases":    "atlas",
+	"statuses":   "status",
+	"caches":     "cache",
+	"niches":     "niche",
+	"headaches":  "headache",
+	"avalanches": "avalanche",
+	"mustaches":  "mustache",
+	"people":     "person",
+	"children":   "child",
+	"women":      "woman",
+}
+
+// lemmatize reduces common english plurals to their singular form.
+// This is a small set of suffix rules, not a full lemmatizer.
+func lemmatize(word string) string {
+
+	if len(word) <= 3 || lemmaExceptions[word] {
+		return word
+	}
+	if singular, ok := lemmaIrregulars[word]; ok {
+		return singular
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "shes"), strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "xes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
@@ -0,0 +1,39 @@
+package analogdb
+
+import "testing"
+
+func TestLemmatize(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"cats", "cat"},
+		{"cities", "city"},
+		{"berries", "berry"},
+		{"movies", "movie"},
+		{"selfies", "selfie"},
+		{"pies", "pie"},
+		{"lenses", "lens"},
+		{"lens", "lens"},
+		{"houses", "house"},
+		{"glasses", "glass"},
+		{"buses", "bus"},
+		{"boxes", "box"},
+		{"churches", "church"},
+		{"niches", "niche"},
+		{"series", "series"},
+		{"grass", "grass"},
+		{"cactus", "cactus"},
+		{"analysis", "analysis"},
+		{"women", "woman"},
+		{"sky", "sky"},
+		{"is", "is"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := lemmatize(tt.word); got != tt.want {
+				t.Errorf("lemmatize(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package analogdb
+
+import "context"
+
+// PostMetadata is the technical metadata of a post, read from the
+// EXIF, XMP and color profile of its highest resolution image
+type PostMetadata struct {
+	CameraMake   string  `json:"camera_make,omitempty"`
+	CameraModel  string  `json:"camera_model,omitempty"`
+	Lens         string  `json:"lens,omitempty"`
+	FocalLength  float64 `json:"focal_length,omitempty"`
+	ExposureTime string  `json:"exposure_time,omitempty"`
+	FNumber      float64 `json:"f_number,omitempty"`
+	ISO          int     `json:"iso,omitempty"`
+	// software that scanned or edited the image
+	Software     string `json:"software,omitempty"`
+	ColorProfile string `json:"color_profile,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	HasExif      bool   `json:"has_exif"`
+}
+
+// MetadataService reads the metadata of posts created before it was read
+type MetadataService interface {
+	SetPostMetadata(ctx context.Context, postID int, metadata *PostMetadata) error
+	PostIDsWithoutMetadata(ctx context.Context) ([]int, error)
+}
@@ -18,6 +18,7 @@ const (
 	HttpSubsystem     = "http"
 	CacheSubsystem    = "cache"
 	RedisSubsystem    = "redis"
+	VectorSubsystem   = "vector"
 )
 
 // track stats from cache
@@ -13,11 +13,34 @@ const defaultMinColorPercent = 0.0
 var primes = []int{11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71, 73, 79, 83, 89, 97, 101, 107, 113, 131, 137, 149, 167, 173, 179, 191, 197, 227, 233, 239, 251, 257, 263}
 
 // Image represents the source info for an image.
+// A post has one image per label, each a rendition of the same photo.
 type Image struct {
 	Label  string `json:"resolution"`
 	Url    string `json:"url"`
 	Width  int    `json:"width"`
 	Height int    `json:"height"`
+	Format string `json:"format,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+}
+
+// labels of the renditions every post has, smallest to largest
+const (
+	ImageLow    = "low"
+	ImageMedium = "medium"
+	ImageHigh   = "high"
+	ImageRaw    = "raw"
+)
+
+var requiredImageLabels = []string{ImageLow, ImageMedium, ImageHigh, ImageRaw}
+
+// FindImage returns the image with a label
+func FindImage(images []Image, label string) (Image, bool) {
+	for _, image := range images {
+		if image.Label == label {
+			return image, true
+		}
+	}
+	return Image{}, false
 }
 
 // Color represents a single color of an image
@@ -28,37 +51,90 @@ type Color struct {
 	Percent float64 `json:"percent"`
 }
 
+// ColorSummary represents how often a color appears across posts
+type ColorSummary struct {
+	Html    string  `json:"html"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"average_percent"`
+}
+
 // CreatePost is the model for creating a post.
 // This includes info from the original reddit post
-// as well as attributes about the image
+// as well as attributes about the image. Grayscale and
+// sprocket are nil when unknown, computed from the image.
 type CreatePost struct {
 	Title     string    `json:"title"`
 	Author    string    `json:"author"`
 	Permalink string    `json:"permalink"`
 	Score     int       `json:"upvotes"`
 	Nsfw      bool      `json:"nsfw"`
-	Grayscale bool      `json:"grayscale"`
+	Grayscale *bool     `json:"grayscale"`
 	Time      int       `json:"unix_time"`
-	Sprocket  bool      `json:"sprocket"`
+	Sprocket  *bool     `json:"sprocket"`
 	Images    []Image   `json:"images"`
 	Colors    []Color   `json:"colors"`
 	Keywords  []Keyword `json:"keywords"`
+	// perceptual hash and metadata of the image, read by the server
+	Hash     *uint64       `json:"-"`
+	Metadata *PostMetadata `json:"-"`
 }
 
 // DisplayPost is the model for displaying a post.
 // Renames some of the json keys.
 type DisplayPost struct {
-	Title     string    `json:"title"`
-	Author    string    `json:"author"`
-	Permalink string    `json:"permalink"`
-	Score     int       `json:"score"`
-	Nsfw      bool      `json:"nsfw"`
-	Grayscale bool      `json:"grayscale"`
-	Time      int       `json:"timestamp"`
-	Sprocket  bool      `json:"sprocket"`
-	Images    []Image   `json:"images"`
-	Colors    []Color   `json:"colors"`
-	Keywords  []Keyword `json:"keywords,omitempty"`
+	Title     string        `json:"title"`
+	Author    string        `json:"author"`
+	Permalink string        `json:"permalink"`
+	Score     int           `json:"score"`
+	Nsfw      bool          `json:"nsfw"`
+	Grayscale bool          `json:"grayscale"`
+	Time      int           `json:"timestamp"`
+	Sprocket  bool          `json:"sprocket"`
+	Images    []Image       `json:"images"`
+	Colors    []Color       `json:"colors"`
+	Keywords  []Keyword     `json:"keywords,omitempty"`
+	Metadata  *PostMetadata `json:"metadata,omitempty"`
+}
+
+// number of colors every post has
+const postColorCount = 5
+
+// Validate checks a post has everything needed to create it
+func (post *CreatePost) Validate() error {
+	invalid := func(msg string) error {
+		return &Error{Code: ERRUNPROCESSABLE, Message: msg}
+	}
+	if strings.TrimSpace(post.Title) == "" {
+		return invalid("post must have a title")
+	}
+	if strings.TrimSpace(post.Author) == "" {
+		return invalid("post must have an author")
+	}
+	if strings.TrimSpace(post.Permalink) == "" {
+		return invalid("post must have a permalink")
+	}
+	labels := make(map[string]bool, len(post.Images))
+	for _, image := range post.Images {
+		if image.Label == "" {
+			return invalid("post image must have a resolution")
+		}
+		if labels[image.Label] {
+			return invalid(fmt.Sprintf("post has more than one %s image", image.Label))
+		}
+		labels[image.Label] = true
+		if image.Url == "" {
+			return invalid(fmt.Sprintf("post image %s must have a url", image.Label))
+		}
+	}
+	for _, label := range requiredImageLabels {
+		if !labels[label] {
+			return invalid(fmt.Sprintf("post must have a %s image", label))
+		}
+	}
+	if len(post.Colors) != postColorCount {
+		return invalid(fmt.Sprintf("post must have %d colors, got %d", postColorCount, len(post.Colors)))
+	}
+	return nil
 }
 
 // PatchPost is the model for patching a post.
@@ -87,6 +163,10 @@ const (
 	SortTime
 	SortScore
 	SortRandom
+	SortOldest
+	SortTrending
+	SortResolution
+	SortColor
 )
 
 func (s PostSort) String() string {
@@ -97,6 +177,14 @@ func (s PostSort) String() string {
 		return "score"
 	case SortRandom:
 		return "random"
+	case SortOldest:
+		return "oldest"
+	case SortTrending:
+		return "trending"
+	case SortResolution:
+		return "resolution"
+	case SortColor:
+		return "color"
 	default:
 		return "unknown"
 	}
@@ -110,6 +198,14 @@ func PostSortFromString(s string) PostSort {
 		return SortScore
 	case "random":
 		return SortRandom
+	case "oldest":
+		return SortOldest
+	case "trending":
+		return SortTrending
+	case "resolution":
+		return SortResolution
+	case "color":
+		return SortColor
 	default:
 		return SortUnknown
 	}
@@ -149,6 +245,11 @@ type PostFilter struct {
 	Colors        *[]string
 	ColorPercents *[]float64
 	Keywords      *[]string
+	Film          *string
+	Camera        *string
+	CameraMake    *string
+	HasExif       *bool
+	Encoded       *bool
 	Width         *Dimension
 	Height        *Dimension
 	AspectRatio   *Dimension
@@ -195,6 +296,21 @@ func (filter *PostFilter) String() string {
 	if filter.Keywords != nil {
 		out = append(out, fmt.Sprintf("keywords: %v", *filter.Keywords))
 	}
+	if filter.Film != nil {
+		out = append(out, fmt.Sprintf("film: %s", *filter.Film))
+	}
+	if filter.Camera != nil {
+		out = append(out, fmt.Sprintf("camera: %s", *filter.Camera))
+	}
+	if filter.CameraMake != nil {
+		out = append(out, fmt.Sprintf("camera_make: %s", *filter.CameraMake))
+	}
+	if filter.HasExif != nil {
+		out = append(out, fmt.Sprintf("has_exif: %t", *filter.HasExif))
+	}
+	if filter.Encoded != nil {
+		out = append(out, fmt.Sprintf("encoded: %t", *filter.Encoded))
+	}
 	if filter.Width != nil {
 		out = append(out, fmt.Sprintf("width: %s", filter.Width))
 	}
@@ -207,6 +323,11 @@ func (filter *PostFilter) String() string {
 	return strings.Join(out, ", ")
 }
 
+// RandomSeeds are the seeds random order is picked from
+func RandomSeeds() []int {
+	return append([]int{}, primes...)
+}
+
 func (filter *PostFilter) SetSeed() {
 	if filter.Seed == nil {
 		randomIndex := rand.Intn(len(primes))
@@ -316,6 +437,20 @@ type Response struct {
 	Posts []Post `json:"posts"`
 }
 
+// ImportFailure is a post that could not be imported
+type ImportFailure struct {
+	Permalink string `json:"permalink"`
+	Reason    string `json:"reason"`
+}
+
+// ImportResult summarizes a batch of imported posts
+type ImportResult struct {
+	Created []int           `json:"created"`
+	Updated []int           `json:"updated"`
+	Skipped int             `json:"skipped"`
+	Failed  []ImportFailure `json:"failed"`
+}
+
 type PostService interface {
 	FindPosts(ctx context.Context, filter *PostFilter) ([]*Post, int, error)
 	FindPostByID(ctx context.Context, id int) (*Post, error)
@@ -323,4 +458,6 @@ type PostService interface {
 	PatchPost(ctx context.Context, post *PatchPost, id int) error
 	DeletePost(ctx context.Context, id int) error
 	AllPostIDs(ctx context.Context) ([]int, error)
+	ExportPosts(ctx context.Context, filter *PostFilter, after int, fn func(*Post) error) error
+	ImportPosts(ctx context.Context, posts []*CreatePost, update bool) (*ImportResult, error)
 }
@@ -3,10 +3,21 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/evanofslack/analogdb"
 )
 
+const (
+	// reddit usernames are stored with this prefix
+	authorPrefix = "u/"
+
+	// number of most common keywords and colors in author profile
+	authorTopKeywords = 10
+	authorTopColors   = 5
+)
+
 // ensure interface is implemented
 var _ analogdb.AuthorService = (*AuthorService)(nil)
 
@@ -37,6 +48,46 @@ func (s *AuthorService) FindAuthors(ctx context.Context) ([]string, error) {
 	return authors, nil
 }
 
+func (s *AuthorService) FindAuthorByName(ctx context.Context, name string) (*analogdb.Author, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Str("author", name).Msg("Starting find author by name")
+	defer s.db.logger.Debug().Ctx(ctx).Str("author", name).Msg("Finished find author by name")
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	author, err := findAuthorByName(ctx, tx, name)
+
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Str("author", name).Msg("Failed to find author by name")
+		return nil, err
+	}
+
+	return author, nil
+}
+
+func (s *AuthorService) FindAuthorSummaries(ctx context.Context, filter *analogdb.AuthorFilter) ([]*analogdb.AuthorSummary, int, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Str("filter", filter.String()).Msg("Starting find author summaries")
+	defer s.db.logger.Debug().Ctx(ctx).Str("filter", filter.String()).Msg("Finished find author summaries")
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	summaries, count, err := findAuthorSummaries(ctx, tx, filter)
+
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find author summaries")
+		return nil, 0, err
+	}
+
+	return summaries, count, nil
+}
+
 func findAuthors(ctx context.Context, tx *sql.Tx) ([]string, error) {
 	query := `
 			SELECT id, author FROM pictures ORDER BY id ASC`
@@ -61,3 +112,223 @@ func findAuthors(ctx context.Context, tx *sql.Tx) ([]string, error) {
 	}
 	return authors, nil
 }
+
+func findAuthorByName(ctx context.Context, tx *sql.Tx, name string) (*analogdb.Author, error) {
+
+	name = addAuthorPrefix(name)
+
+	query := `
+			SELECT
+				COUNT(*),
+				COALESCE(SUM(score), 0),
+				COALESCE(AVG(score), 0),
+				COALESCE(MIN(time), 0),
+				COALESCE(MAX(time), 0),
+				COALESCE(AVG(CASE WHEN greyscale THEN 1 ELSE 0 END), 0),
+				COALESCE(AVG(CASE WHEN sprocket THEN 1 ELSE 0 END), 0)
+			FROM pictures
+			WHERE author = $1
+	`
+
+	author := &analogdb.Author{Name: strings.TrimPrefix(name, authorPrefix)}
+
+	err := tx.QueryRowContext(ctx, query, name).Scan(
+		&author.PostCount,
+		&author.TotalScore,
+		&author.AverageScore,
+		&author.FirstPostTime,
+		&author.LastPostTime,
+		&author.GrayscaleRatio,
+		&author.SprocketRatio,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if author.PostCount == 0 {
+		return nil, &analogdb.Error{Code: analogdb.ERRNOTFOUND, Message: "Author not found"}
+	}
+
+	if author.Keywords, err = findAuthorKeywords(ctx, tx, name, authorTopKeywords); err != nil {
+		return nil, err
+	}
+
+	if author.Colors, err = findAuthorColors(ctx, tx, name, authorTopColors); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// findAuthorKeywords gets the most common keywords across an author's posts
+func findAuthorKeywords(ctx context.Context, tx *sql.Tx, name string, limit int) ([]analogdb.KeywordSummary, error) {
+	query := `
+			SELECT
+				k.word,
+				COUNT(DISTINCT k.post_id) as count
+			FROM keywords k
+			INNER JOIN pictures p ON p.id = k.post_id
+			WHERE p.author = $1
+			GROUP BY k.word
+			ORDER BY count DESC, k.word ASC
+			LIMIT $2
+	`
+
+	rows, err := tx.QueryContext(ctx, query, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keywords := make([]analogdb.KeywordSummary, 0)
+	var kw analogdb.KeywordSummary
+	for rows.Next() {
+		if err := rows.Scan(&kw.Word, &kw.Count); err != nil {
+			return nil, err
+		}
+		keywords = append(keywords, kw)
+	}
+	return keywords, rows.Err()
+}
+
+// findAuthorColors gets the most common colors across an author's posts
+func findAuthorColors(ctx context.Context, tx *sql.Tx, name string, limit int) ([]analogdb.ColorSummary, error) {
+	query := `
+			SELECT
+				c.html,
+				COUNT(DISTINCT c.post_id) as count,
+				AVG(c.percent)
+			FROM colors c
+			INNER JOIN pictures p ON p.id = c.post_id
+			WHERE p.author = $1
+			GROUP BY c.html
+			ORDER BY count DESC, c.html ASC
+			LIMIT $2
+	`
+
+	rows, err := tx.QueryContext(ctx, query, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	colors := make([]analogdb.ColorSummary, 0)
+	var c analogdb.ColorSummary
+	for rows.Next() {
+		if err := rows.Scan(&c.Html, &c.Count, &c.Percent); err != nil {
+			return nil, err
+		}
+		colors = append(colors, c)
+	}
+	return colors, rows.Err()
+}
+
+func findAuthorSummaries(ctx context.Context, tx *sql.Tx, filter *analogdb.AuthorFilter) ([]*analogdb.AuthorSummary, int, error) {
+
+	aggregate := authorFilterToAggregate(filter)
+	where, having, args := authorFilterToWhere(filter, aggregate)
+	limit := ""
+	if l := filter.Limit; l != nil && *l > 0 {
+		limit = fmt.Sprintf(" LIMIT %d", *l)
+	}
+
+	query := fmt.Sprintf(`
+			SELECT
+				author,
+				COUNT(*),
+				COALESCE(SUM(score), 0)
+			FROM pictures
+			WHERE %s
+			GROUP BY author
+			HAVING %s
+			ORDER BY %s DESC, author DESC
+	`, where, having, aggregate) + limit
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	summaries := make([]*analogdb.AuthorSummary, 0)
+	for rows.Next() {
+		var summary analogdb.AuthorSummary
+		if err := rows.Scan(&summary.Name, &summary.PostCount, &summary.TotalScore); err != nil {
+			return nil, 0, err
+		}
+		summary.Name = strings.TrimPrefix(summary.Name, authorPrefix)
+		summaries = append(summaries, &summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	count, err := countAuthors(ctx, tx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, 0, err
+	}
+	return summaries, count, nil
+}
+
+// countAuthors counts every author matching the filter, ignoring the keyset
+// so the total is the same on every page
+func countAuthors(ctx context.Context, tx *sql.Tx, filter *analogdb.AuthorFilter) (int, error) {
+
+	unpaged := *filter
+	unpaged.Keyset = nil
+	where, _, args := authorFilterToWhere(&unpaged, authorFilterToAggregate(&unpaged))
+
+	query := fmt.Sprintf(`
+			SELECT COUNT(DISTINCT author)
+			FROM pictures
+			WHERE %s
+	`, where)
+
+	var count int
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// authorFilterToAggregate gets the aggregate authors are sorted by
+func authorFilterToAggregate(filter *analogdb.AuthorFilter) string {
+	if sort := filter.Sort; sort != nil && *sort == analogdb.AuthorSortScore {
+		return "COALESCE(SUM(score), 0)"
+	}
+	return "COUNT(*)"
+}
+
+// authorFilterToWhere converts an AuthorFilter to SQL WHERE and HAVING statements
+func authorFilterToWhere(filter *analogdb.AuthorFilter, aggregate string) (string, string, []any) {
+
+	index := 1
+	where, having, args := []string{"author IS NOT NULL"}, []string{"1=1"}, []any{}
+
+	// match the start of the author name
+	if prefix := filter.Prefix; prefix != nil {
+		where = append(where, fmt.Sprintf("author ILIKE $%d", index))
+		args = append(args, escapeLike(addAuthorPrefix(*prefix))+"%")
+		index += 1
+	}
+
+	// aggregates are not unique, so the keyset is the name
+	// of the last author seen. compare against that author's
+	// aggregate, breaking ties with the name.
+	if keyset := filter.Keyset; keyset != nil {
+		having = append(having, fmt.Sprintf("(%[1]s, author) < (SELECT %[1]s, author FROM pictures WHERE author = $%[2]d GROUP BY author)", aggregate, index))
+		args = append(args, addAuthorPrefix(*keyset))
+		index += 1
+	}
+
+	return strings.Join(where, " AND "), strings.Join(having, " AND "), args
+}
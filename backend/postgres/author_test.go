@@ -3,6 +3,8 @@ package postgres
 import (
 	"context"
 	"testing"
+
+	"github.com/evanofslack/analogdb"
 )
 
 const (
@@ -41,3 +43,98 @@ func TestFindAuthors(t *testing.T) {
 		}
 	})
 }
+
+func TestFindAuthorByName(t *testing.T) {
+	t.Run("Author profile", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		as := NewAuthorService(db)
+
+		author, err := as.FindAuthorByName(context.Background(), postAuthor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := author.Name, postAuthor[2:]; got != want {
+			t.Fatalf("wrong author name, want %s, got %s", want, got)
+		}
+		if got, want := author.PostCount, 1; got != want {
+			t.Fatalf("wrong number of posts, want %d, got %d", want, got)
+		}
+		if author.FirstPostTime != author.LastPostTime {
+			t.Fatal("first and last post time must match with a single post")
+		}
+	})
+	t.Run("Nonexisting author", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		as := NewAuthorService(db)
+
+		if _, err := as.FindAuthorByName(context.Background(), "not-a-real-author"); err == nil {
+			t.Fatal("error should be returned when no matching author is found")
+		}
+	})
+}
+
+func TestFindAuthorSummaries(t *testing.T) {
+	t.Run("Paginate by posts", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		as := NewAuthorService(db)
+
+		sort := analogdb.AuthorSortPosts
+		filter := &analogdb.AuthorFilter{Limit: &limit, Sort: &sort}
+
+		summaries, count, err := as.FindAuthorSummaries(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != totalAuthors {
+			t.Fatalf("wrong number of total authors, want %d, got %d", totalAuthors, count)
+		}
+		if got, want := len(summaries), limit; got != want {
+			t.Fatalf("wrong number of authors, want %d, got %d", want, got)
+		}
+
+		seen := make(map[string]bool)
+		for i, summary := range summaries {
+			seen[summary.Name] = true
+			if i > 0 && summary.PostCount > summaries[i-1].PostCount {
+				t.Fatal("authors not sorted most to least posts")
+			}
+		}
+
+		last := summaries[limit-1]
+		filter.Keyset = &last.Name
+		summaries, nextCount, err := as.FindAuthorSummaries(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nextCount != count {
+			t.Fatalf("total authors changed between pages, want %d, got %d", count, nextCount)
+		}
+		for _, summary := range summaries {
+			if seen[summary.Name] {
+				t.Fatal("paginated authors must not repeat")
+			}
+			if summary.PostCount > last.PostCount {
+				t.Fatal("authors not sorted most to least posts with keyset")
+			}
+		}
+	})
+	t.Run("Prefix matches literally", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		as := NewAuthorService(db)
+
+		prefix := "%"
+		filter := &analogdb.AuthorFilter{Limit: &limit, Prefix: &prefix}
+
+		summaries, count, err := as.FindAuthorSummaries(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(summaries) != 0 || count != 0 {
+			t.Fatalf("wildcard prefix must not match authors, got %d", count)
+		}
+	})
+}
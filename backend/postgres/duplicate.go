@@ -0,0 +1,409 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/lib/pq"
+)
+
+// ensure interface is implemented
+var _ analogdb.DuplicateService = (*DuplicateService)(nil)
+
+type DuplicateService struct {
+	db *DB
+}
+
+func NewDuplicateService(db *DB) *DuplicateService {
+	return &DuplicateService{db: db}
+}
+
+func (s *DuplicateService) FindDuplicateClusters(ctx context.Context, filter *analogdb.DuplicateFilter) ([]analogdb.DuplicateCluster, int, error) {
+	tx, err := s.db.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	return s.db.findDuplicateClusters(ctx, tx, filter)
+}
+
+func (s *DuplicateService) FindNearDuplicates(ctx context.Context, hash uint64, maxDistance int) ([]int, error) {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	matches, err := s.db.findNearDuplicates(ctx, tx, hash, maxDistance)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.id)
+	}
+	return ids, nil
+}
+
+// LinkDuplicates recomputes every pair of posts with image hashes within
+// maxDistance, replacing the pairs found before
+func (s *DuplicateService) LinkDuplicates(ctx context.Context, maxDistance int) (int, error) {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	return s.db.linkDuplicates(ctx, tx, maxDistance)
+}
+
+func (s *DuplicateService) SetImageHash(ctx context.Context, postID int, hash uint64) error {
+
+	result, err := s.db.db.ExecContext(ctx, `UPDATE pictures SET image_hash = $1 WHERE id = $2`, int64(hash), postID)
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Int("postID", postID).Msg("Failed to set image hash")
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return &analogdb.Error{Code: analogdb.ERRNOTFOUND, Message: "Post not found"}
+	}
+	return nil
+}
+
+func (s *DuplicateService) UnhashedPostIDs(ctx context.Context) ([]int, error) {
+
+	rows, err := s.db.db.QueryContext(ctx, `SELECT id FROM pictures WHERE image_hash IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// nearDuplicate is a post whose image hash is within some distance of another
+type nearDuplicate struct {
+	id       int
+	distance int
+}
+
+// checkDuplicate looks for posts with an image like the one of post,
+// rejecting the post or only logging it depending on how the DB is configured.
+// Returns the near duplicates found, to be linked to the post once created.
+func (db *DB) checkDuplicate(ctx context.Context, tx *sql.Tx, post *analogdb.CreatePost) ([]nearDuplicate, error) {
+
+	if db.duplicateDistance < 0 || post.Hash == nil {
+		return nil, nil
+	}
+
+	matches, err := db.findNearDuplicates(ctx, tx, *post.Hash, db.duplicateDistance)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.id)
+	}
+
+	if db.rejectDuplicates {
+		db.logger.Info().Ctx(ctx).Str("permalink", post.Permalink).Ints("duplicates", ids).Msg("Rejected duplicate post")
+		return nil, &analogdb.Error{Code: analogdb.ERRCONFLICT, Message: fmt.Sprintf("post looks like a duplicate of posts %v", ids)}
+	}
+	db.logger.Warn().Ctx(ctx).Str("permalink", post.Permalink).Ints("duplicates", ids).Msg("Creating post that looks like a duplicate")
+	return matches, nil
+}
+
+// insertDuplicates records a newly created post as a duplicate of matches
+func (db *DB) insertDuplicates(ctx context.Context, tx *sql.Tx, id int, matches []nearDuplicate) error {
+
+	if len(matches) == 0 {
+		return nil
+	}
+
+	// the new post always has the highest id
+	ids := make([]int64, 0, len(matches))
+	dupes := make([]int64, 0, len(matches))
+	distances := make([]int64, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, int64(match.id))
+		dupes = append(dupes, int64(id))
+		distances = append(distances, int64(match.distance))
+	}
+	return insertDuplicatePairs(ctx, tx, ids, dupes, distances)
+}
+
+func insertDuplicatePairs(ctx context.Context, tx *sql.Tx, ids, dupes, distances []int64) error {
+
+	query := `
+	INSERT INTO duplicates (post_id, duplicate_id, distance)
+	SELECT * FROM unnest($1::int[], $2::int[], $3::int[])
+	ON CONFLICT (post_id, duplicate_id) DO UPDATE SET distance = EXCLUDED.distance`
+
+	_, err := tx.ExecContext(ctx, query, pq.Array(ids), pq.Array(dupes), pq.Array(distances))
+	return err
+}
+
+// findNearDuplicates is every post whose image hash is within maxDistance of hash
+func (db *DB) findNearDuplicates(ctx context.Context, tx *sql.Tx, hash uint64, maxDistance int) ([]nearDuplicate, error) {
+
+	query := `
+	SELECT id, bit_count((image_hash # $1)::bit(64))
+	FROM pictures
+	WHERE image_hash IS NOT NULL
+	AND bit_count((image_hash # $1)::bit(64)) <= $2
+	ORDER BY id`
+
+	rows, err := tx.QueryContext(ctx, query, int64(hash), maxDistance)
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find near duplicate posts")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []nearDuplicate
+	for rows.Next() {
+		var match nearDuplicate
+		if err := rows.Scan(&match.id, &match.distance); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+// linkDuplicates replaces the stored duplicate pairs with every
+// pair of posts with image hashes within maxDistance
+func (db *DB) linkDuplicates(ctx context.Context, tx *sql.Tx, maxDistance int) (int, error) {
+
+	db.logger.Debug().Ctx(ctx).Int("maxDistance", maxDistance).Msg("Starting link duplicates")
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, image_hash FROM pictures WHERE image_hash IS NOT NULL`)
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to link duplicates")
+		return 0, err
+	}
+	hashes := make(map[int]uint64)
+	for rows.Next() {
+		var id int
+		var hash int64
+		if err := rows.Scan(&id, &hash); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		hashes[id] = uint64(hash)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	pairs := duplicatePairs(hashes, maxDistance)
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM duplicates`); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to link duplicates")
+		return 0, err
+	}
+	ids := make([]int64, 0, len(pairs))
+	dupes := make([]int64, 0, len(pairs))
+	distances := make([]int64, 0, len(pairs))
+	for _, pair := range pairs {
+		ids = append(ids, int64(pair.a))
+		dupes = append(dupes, int64(pair.b))
+		distances = append(distances, int64(pair.distance))
+	}
+	if err := insertDuplicatePairs(ctx, tx, ids, dupes, distances); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to link duplicates")
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	db.logger.Info().Ctx(ctx).Int("posts", len(hashes)).Int("pairs", len(pairs)).Msg("Finished linking duplicates")
+	return len(pairs), nil
+}
+
+// duplicatePair is two posts with image hashes within some distance, a < b
+type duplicatePair struct {
+	a, b     int
+	distance int
+}
+
+// duplicatePairs finds every pair of hashes within maxDistance without
+// comparing every hash to every other. Hashes are split into maxDistance+1
+// chunks of bits; two hashes differing in at most maxDistance bits must
+// have at least one chunk in common, so only hashes sharing a chunk are compared.
+func duplicatePairs(hashes map[int]uint64, maxDistance int) []duplicatePair {
+
+	ids := make([]int, 0, len(hashes))
+	for id := range hashes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	// every pair of hashes is within 64 bits, so all
+	// hashes go in a single bucket of zero bit chunks
+	all := maxDistance >= 64
+	chunks := maxDistance + 1
+	if all {
+		chunks = 1
+	}
+
+	seen := make(map[[2]int]bool)
+	var pairs []duplicatePair
+	for c := 0; c < chunks; c++ {
+		start, end := c*64/chunks, (c+1)*64/chunks
+		if all {
+			end = start
+		}
+		mask := uint64(1)<<(end-start) - 1
+
+		buckets := make(map[uint64][]int)
+		for _, id := range ids {
+			key := (hashes[id] >> start) & mask
+			buckets[key] = append(buckets[key], id)
+		}
+
+		for _, bucket := range buckets {
+			for i, a := range bucket {
+				for _, b := range bucket[i+1:] {
+					if seen[[2]int{a, b}] {
+						continue
+					}
+					distance := bits.OnesCount64(hashes[a] ^ hashes[b])
+					if distance > maxDistance {
+						continue
+					}
+					seen[[2]int{a, b}] = true
+					pairs = append(pairs, duplicatePair{a: a, b: b, distance: distance})
+				}
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].a != pairs[j].a {
+			return pairs[i].a < pairs[j].a
+		}
+		return pairs[i].b < pairs[j].b
+	})
+	return pairs
+}
+
+// findDuplicateClusters groups the stored duplicate pairs within the filter's
+// distance into clusters of linked posts, returning a page of clusters and
+// the total number of clusters
+func (db *DB) findDuplicateClusters(ctx context.Context, tx *sql.Tx, filter *analogdb.DuplicateFilter) ([]analogdb.DuplicateCluster, int, error) {
+
+	db.logger.Debug().Ctx(ctx).Int("maxDistance", filter.MaxDistance).Msg("Starting find duplicate clusters")
+
+	query := `
+	SELECT post_id, duplicate_id, distance
+	FROM duplicates
+	WHERE distance <= $1`
+
+	rows, err := tx.QueryContext(ctx, query, filter.MaxDistance)
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find duplicate clusters")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var pairs []duplicatePair
+	for rows.Next() {
+		var pair duplicatePair
+		if err := rows.Scan(&pair.a, &pair.b, &pair.distance); err != nil {
+			return nil, 0, err
+		}
+		pairs = append(pairs, pair)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	clusters := clusterDuplicates(pairs)
+	total := len(clusters)
+
+	// skip clusters up to and including the keyset
+	if keyset := filter.Keyset; keyset != nil {
+		i := sort.Search(len(clusters), func(i int) bool {
+			return newestPost(clusters[i]) < *keyset
+		})
+		clusters = clusters[i:]
+	}
+	if limit := filter.Limit; limit != nil && *limit > 0 && len(clusters) > *limit {
+		clusters = clusters[:*limit]
+	}
+
+	db.logger.Info().Ctx(ctx).Int("clusters", len(clusters)).Int("total", total).Msg("Finished find duplicate clusters")
+
+	return clusters, total, nil
+}
+
+// clusterDuplicates groups linked posts into clusters, newest cluster first
+func clusterDuplicates(pairs []duplicatePair) []analogdb.DuplicateCluster {
+
+	// union find over linked posts
+	parent := make(map[int]int)
+	var find func(id int) int
+	find = func(id int) int {
+		p, ok := parent[id]
+		if !ok || p == id {
+			parent[id] = id
+			return id
+		}
+		root := find(p)
+		parent[id] = root
+		return root
+	}
+
+	for _, pair := range pairs {
+		if ra, rb := find(pair.a), find(pair.b); ra != rb {
+			parent[ra] = rb
+		}
+	}
+
+	clusters := make(map[int]*analogdb.DuplicateCluster)
+	for id := range parent {
+		root := find(id)
+		cluster, ok := clusters[root]
+		if !ok {
+			cluster = &analogdb.DuplicateCluster{}
+			clusters[root] = cluster
+		}
+		cluster.PostIDs = append(cluster.PostIDs, id)
+	}
+	for _, pair := range pairs {
+		if cluster := clusters[find(pair.a)]; pair.distance > cluster.Distance {
+			cluster.Distance = pair.distance
+		}
+	}
+
+	result := make([]analogdb.DuplicateCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		sort.Ints(cluster.PostIDs)
+		result = append(result, *cluster)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return newestPost(result[i]) > newestPost(result[j])
+	})
+	return result
+}
+
+func newestPost(cluster analogdb.DuplicateCluster) int {
+	return cluster.PostIDs[len(cluster.PostIDs)-1]
+}
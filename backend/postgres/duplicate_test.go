@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+func TestDuplicatePairs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// clusters of hashes a few bits apart among random hashes
+	hashes := make(map[int]uint64)
+	id := 1
+	for c := 0; c < 50; c++ {
+		base := rng.Uint64()
+		for i := 0; i < 3; i++ {
+			hash := base
+			for flips := rng.Intn(6); flips > 0; flips-- {
+				hash ^= 1 << rng.Intn(64)
+			}
+			hashes[id] = hash
+			id++
+		}
+	}
+	for i := 0; i < 500; i++ {
+		hashes[id] = rng.Uint64()
+		id++
+	}
+
+	for _, maxDistance := range []int{0, 3, 6, 10, 64} {
+		want := 0
+		for a, ha := range hashes {
+			for b, hb := range hashes {
+				if a < b && bits.OnesCount64(ha^hb) <= maxDistance {
+					want++
+				}
+			}
+		}
+
+		pairs := duplicatePairs(hashes, maxDistance)
+		if got := len(pairs); got != want {
+			t.Errorf("distance %d: want %d pairs, got %d", maxDistance, want, got)
+		}
+		for _, pair := range pairs {
+			if pair.a >= pair.b || pair.distance != bits.OnesCount64(hashes[pair.a]^hashes[pair.b]) {
+				t.Fatalf("distance %d: invalid pair %+v", maxDistance, pair)
+			}
+		}
+	}
+}
+
+func TestClusterDuplicates(t *testing.T) {
+	pairs := []duplicatePair{
+		{a: 1, b: 2, distance: 1},
+		{a: 2, b: 5, distance: 3},
+		{a: 3, b: 4, distance: 2},
+	}
+
+	clusters := clusterDuplicates(pairs)
+	if len(clusters) != 2 {
+		t.Fatalf("want 2 clusters, got %d", len(clusters))
+	}
+	// newest cluster first
+	if got := clusters[0].PostIDs; len(got) != 3 || got[0] != 1 || got[2] != 5 {
+		t.Errorf("want cluster [1 2 5], got %v", got)
+	}
+	if got, want := clusters[0].Distance, 3; got != want {
+		t.Errorf("want distance %d, got %d", want, got)
+	}
+	if got := clusters[1].PostIDs; len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("want cluster [3 4], got %v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/lib/pq"
+)
+
+// ensure interface is implemented
+var _ analogdb.EncodingService = (*EncodingService)(nil)
+
+type EncodingService struct {
+	db *DB
+}
+
+func NewEncodingService(db *DB) *EncodingService {
+	return &EncodingService{db: db}
+}
+
+func (s *EncodingService) FindEncodingByID(ctx context.Context, postID int) (*analogdb.Encoding, error) {
+
+	query := `
+	SELECT p.id, COALESCE(e.status, $2), e.error, e.schema_version, e.encoded_at
+	FROM pictures p
+	LEFT OUTER JOIN encodings e ON e.post_id = p.id
+	WHERE p.id = $1`
+
+	var encoding analogdb.Encoding
+	var reason sql.NullString
+	var version sql.NullInt64
+	var encodedAt sql.NullTime
+	err := s.db.db.QueryRowContext(ctx, query, postID, analogdb.EncodingPending).Scan(&encoding.PostID, &encoding.Status, &reason, &version, &encodedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &analogdb.Error{Code: analogdb.ERRNOTFOUND, Message: "Post not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	encoding.Error = reason.String
+	encoding.SchemaVersion = int(version.Int64)
+	if encodedAt.Valid {
+		encoding.EncodedAt = &encodedAt.Time
+	}
+	return &encoding, nil
+}
+
+func (s *EncodingService) MarkEncoded(ctx context.Context, postIDs []int, schemaVersion int) error {
+
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	// posts deleted while they were encoded are skipped by the join
+	query := `
+	INSERT INTO encodings (post_id, status, error, schema_version, encoded_at)
+	SELECT p.id, $2, NULL, $3, now()
+	FROM pictures p
+	WHERE p.id = ANY($1)
+	ON CONFLICT (post_id) DO UPDATE SET
+		status = EXCLUDED.status,
+		error = NULL,
+		schema_version = EXCLUDED.schema_version,
+		encoded_at = EXCLUDED.encoded_at`
+
+	if _, err := s.db.db.ExecContext(ctx, query, pq.Array(postIDs), analogdb.EncodingEncoded, schemaVersion); err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Int("posts", len(postIDs)).Msg("Failed to mark posts encoded")
+		return err
+	}
+	return nil
+}
+
+func (s *EncodingService) MarkFailed(ctx context.Context, failures []analogdb.EncodeFailure, schemaVersion int) error {
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(failures))
+	reasons := make([]string, len(failures))
+	for i, f := range failures {
+		ids[i] = f.PostID
+		reasons[i] = f.Reason
+	}
+
+	// an older embedding may still be in the vector DB, so encoded_at is kept
+	query := `
+	INSERT INTO encodings (post_id, status, error, schema_version)
+	SELECT f.id, $3, f.reason, $4
+	FROM unnest($1::int[], $2::text[]) AS f(id, reason)
+	JOIN pictures p ON p.id = f.id
+	ON CONFLICT (post_id) DO UPDATE SET
+		status = EXCLUDED.status,
+		error = EXCLUDED.error,
+		schema_version = EXCLUDED.schema_version`
+
+	if _, err := s.db.db.ExecContext(ctx, query, pq.Array(ids), pq.Array(reasons), analogdb.EncodingFailed, schemaVersion); err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Int("posts", len(failures)).Msg("Failed to mark posts failed to encode")
+		return err
+	}
+	return nil
+}
+
+func (s *EncodingService) OutdatedPostIDs(ctx context.Context, schemaVersion int) ([]int, error) {
+
+	query := `
+	SELECT p.id
+	FROM pictures p
+	LEFT OUTER JOIN encodings e ON e.post_id = p.id
+	WHERE e.status IS DISTINCT FROM $1
+	OR e.schema_version IS DISTINCT FROM $2
+	ORDER BY p.id`
+
+	rows, err := s.db.db.QueryContext(ctx, query, analogdb.EncodingEncoded, schemaVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/evanofslack/analogdb"
+)
+
+// number of posts fetched from the export cursor at a time
+const exportBatchSize = 500
+
+// ExportPosts calls fn with every post matching filter, in order of id,
+// starting after the post with id after. Posts are read from a server side
+// cursor, so only a single batch is held in memory at a time.
+func (s *PostService) ExportPosts(ctx context.Context, filter *analogdb.PostFilter, after int, fn func(*analogdb.Post) error) error {
+	// a cursor lives in a transaction, which also gives the export a consistent snapshot
+	tx, err := s.db.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return s.db.exportPosts(ctx, tx, filter, after, fn)
+}
+
+func (db *DB) exportPosts(ctx context.Context, tx *sql.Tx, filter *analogdb.PostFilter, after int, fn func(*analogdb.Post) error) error {
+
+	db.logger.Debug().Ctx(ctx).Int("after", after).Msg("Starting export posts")
+
+	// exports are always the full set in id order, so they can be resumed
+	exportFilter := *filter
+	exportFilter.Sort = nil
+	exportFilter.Keyset = nil
+	exportFilter.Limit = nil
+
+	query, args, index := db.selectPostsQuery(ctx, &exportFilter, false)
+	query += fmt.Sprintf(" AND p.id > $%d ORDER BY p.id ASC", index)
+	args = append(args, after)
+
+	if _, err := tx.ExecContext(ctx, "DECLARE export_posts NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to declare export cursor")
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM export_posts", exportBatchSize)
+	exported := 0
+	for {
+		n, err := db.exportBatch(ctx, tx, fetch, fn)
+		exported += n
+		if err != nil {
+			db.logger.Error().Err(err).Ctx(ctx).Int("exported", exported).Msg("Failed to export posts")
+			return err
+		}
+		if n < exportBatchSize {
+			break
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "CLOSE export_posts"); err != nil {
+		return err
+	}
+
+	db.logger.Info().Ctx(ctx).Int("exported", exported).Msg("Finished exporting posts")
+	return nil
+}
+
+// exportBatch fetches the next batch of posts from the export cursor,
+// returning how many were fetched
+func (db *DB) exportBatch(ctx context.Context, tx *sql.Tx, fetch string, fn func(*analogdb.Post) error) (int, error) {
+
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		p, err := scanRowToRawPost(rows)
+		if err != nil {
+			return n, err
+		}
+		post, err := rawPostToPost(*p)
+		if err != nil {
+			return n, err
+		}
+		stripAuthorPrefix(post)
+
+		if err := fn(post); err != nil {
+			return n, err
+		}
+		n += 1
+	}
+	return n, rows.Err()
+}
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/evanofslack/analogdb"
+)
+
+func TestExportPosts(t *testing.T) {
+	t.Run("Ordered", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ps := NewPostService(db)
+
+		last := 0
+		exported := 0
+		err := ps.ExportPosts(context.Background(), analogdb.NewPostFilter(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil), 0, func(post *analogdb.Post) error {
+			if post.Id <= last {
+				t.Fatalf("post %d exported after post %d", post.Id, last)
+			}
+			last = post.Id
+			exported += 1
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids, err := ps.AllPostIDs(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := len(ids), exported; got != want {
+			t.Fatalf("want %d posts exported, got %d", want, got)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ps := NewPostService(db)
+
+		after := 1000
+		err := ps.ExportPosts(context.Background(), analogdb.NewPostFilter(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil), after, func(post *analogdb.Post) error {
+			if post.Id <= after {
+				t.Fatalf("post %d exported, want posts after %d", post.Id, after)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ps := NewPostService(db)
+
+		nsfw := true
+		err := ps.ExportPosts(context.Background(), analogdb.NewPostFilter(nil, nil, nil, &nsfw, nil, nil, nil, nil, nil, nil, nil, nil, nil), 0, func(post *analogdb.Post) error {
+			if !post.Nsfw {
+				t.Fatalf("post %d is not nsfw", post.Id)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("Import round trip", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ps := NewPostService(db)
+
+		ctx := context.Background()
+
+		var images []analogdb.Image
+		for _, label := range []string{analogdb.ImageLow, analogdb.ImageMedium, analogdb.ImageHigh, analogdb.ImageRaw} {
+			images = append(images, analogdb.Image{Label: label, Url: "test.export.roundtrip.com"})
+		}
+		color := analogdb.Color{Hex: "#000000", Css: "Black", Percent: 0.2}
+		created, err := ps.CreatePost(ctx, &analogdb.CreatePost{
+			Title:     "test export round trip",
+			Author:    "u/test export author",
+			Permalink: "test.export.roundtrip.permalink.com",
+			Grayscale: new(bool),
+			Sprocket:  new(bool),
+			Images:    images,
+			Colors:    []analogdb.Color{color, color, color, color, color},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// export the post as GET /export does, then delete it to import it back
+		ids := []int{created.Id}
+		var exported []*analogdb.CreatePost
+		err = ps.ExportPosts(ctx, analogdb.NewPostFilterWithIDs(ids), 0, func(post *analogdb.Post) error {
+			grayscale, sprocket := post.Grayscale, post.Sprocket
+			exported = append(exported, &analogdb.CreatePost{
+				Title:     post.Title,
+				Author:    post.Author,
+				Permalink: post.Permalink,
+				Score:     post.Score,
+				Nsfw:      post.Nsfw,
+				Grayscale: &grayscale,
+				Time:      post.Time,
+				Sprocket:  &sprocket,
+				Images:    post.Images,
+				Colors:    post.Colors,
+				Keywords:  post.Keywords,
+			})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ps.DeletePost(ctx, created.Id); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := ps.ImportPosts(ctx, exported, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Created) != 1 {
+			t.Fatalf("want 1 post imported, got %d created and %d failed", len(result.Created), len(result.Failed))
+		}
+		defer ps.DeletePost(ctx, result.Created[0])
+
+		// the imported post is found by its author, with or without the prefix
+		for _, author := range []string{"test export author", "u/test export author"} {
+			author := author
+			posts, _, err := ps.FindPosts(ctx, analogdb.NewPostFilter(nil, nil, nil, nil, nil, nil, nil, nil, nil, &author, nil, nil, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(posts) != 1 || posts[0].Id != result.Created[0] {
+				t.Fatalf("want imported post %d found by author %s, got %d posts", result.Created[0], author, len(posts))
+			}
+		}
+	})
+}
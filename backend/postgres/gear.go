@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/evanofslack/analogdb"
+)
+
+// tables that hold film stocks and cameras extracted from post titles
+const (
+	filmsTable   = "films"
+	camerasTable = "cameras"
+)
+
+// number of posts to extract gear from per transaction
+const extractGearBatchSize = 500
+
+// ensure interface is implemented
+var _ analogdb.GearService = (*GearService)(nil)
+
+type GearService struct {
+	db *DB
+}
+
+func NewGearService(db *DB) *GearService {
+	return &GearService{db: db}
+}
+
+func (s *GearService) FindFilms(ctx context.Context) (*[]analogdb.GearSummary, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Msg("Starting find films")
+	defer s.db.logger.Debug().Ctx(ctx).Msg("Finished find films")
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	films, err := findGear(ctx, tx, filmsTable)
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find films")
+		return nil, err
+	}
+	return films, nil
+}
+
+func (s *GearService) FindCameras(ctx context.Context) (*[]analogdb.GearSummary, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Msg("Starting find cameras")
+	defer s.db.logger.Debug().Ctx(ctx).Msg("Finished find cameras")
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cameras, err := findGear(ctx, tx, camerasTable)
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find cameras")
+		return nil, err
+	}
+	return cameras, nil
+}
+
+// ExtractGear re-extracts film stocks and cameras from the titles of all posts.
+// Needed to backfill existing posts and after the catalog changes.
+func (s *GearService) ExtractGear(ctx context.Context) (*analogdb.ExtractGearResult, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Msg("Starting extract gear")
+
+	result := &analogdb.ExtractGearResult{}
+	lastID := 0
+
+	for {
+		batch, last, err := s.db.extractGearBatch(ctx, lastID)
+		if err != nil {
+			s.db.logger.Error().Err(err).Ctx(ctx).Int("afterID", lastID).Msg("Failed to extract gear")
+			return nil, err
+		}
+		result.Posts += batch.Posts
+		result.Films += batch.Films
+		result.Cameras += batch.Cameras
+		if batch.Posts < extractGearBatchSize {
+			break
+		}
+		lastID = last
+	}
+
+	s.db.logger.Info().Ctx(ctx).Int("posts", result.Posts).Int("films", result.Films).Int("cameras", result.Cameras).Msg("Finished extracting gear")
+	return result, nil
+}
+
+// extractGearBatch extracts gear for the next batch of posts with
+// an id greater than afterID, returning the last post id processed
+func (db *DB) extractGearBatch(ctx context.Context, afterID int) (*analogdb.ExtractGearResult, int, error) {
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT id, title FROM pictures WHERE id > $1 ORDER BY id ASC LIMIT $2"
+	rows, err := tx.QueryContext(ctx, query, afterID, extractGearBatchSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids, titles := []int64{}, []string{}
+	for rows.Next() {
+		var id int64
+		var title string
+		if err := rows.Scan(&id, &title); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+		titles = append(titles, title)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	result := &analogdb.ExtractGearResult{Posts: len(ids)}
+	if len(ids) == 0 {
+		return result, afterID, nil
+	}
+
+	// replace any previously extracted gear
+	for _, table := range []string{filmsTable, camerasTable} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE post_id > $1 AND post_id <= $2", table)
+		if _, err := tx.ExecContext(ctx, query, afterID, ids[len(ids)-1]); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	for i, id := range ids {
+		films, cameras, err := db.insertGear(ctx, tx, titles[i], id)
+		if err != nil {
+			return nil, 0, err
+		}
+		result.Films += len(films)
+		result.Cameras += len(cameras)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+	return result, int(ids[len(ids)-1]), nil
+}
+
+// insertGear extracts film stocks and cameras from a post title
+// and inserts them into the DB, returning what was inserted
+func (db *DB) insertGear(ctx context.Context, tx *sql.Tx, title string, postID int64) ([]string, []string, error) {
+
+	db.logger.Debug().Ctx(ctx).Int64("postID", postID).Msg("Starting insert gear")
+
+	films := analogdb.ExtractFilms(title)
+	if err := insertGearNames(ctx, tx, filmsTable, films, postID); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Int64("postID", postID).Msg("Failed to insert films")
+		return nil, nil, err
+	}
+
+	cameras := analogdb.ExtractCameras(title)
+	if err := insertGearNames(ctx, tx, camerasTable, cameras, postID); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Int64("postID", postID).Msg("Failed to insert cameras")
+		return nil, nil, err
+	}
+
+	db.logger.Debug().Ctx(ctx).Int64("postID", postID).Int("films", len(films)).Int("cameras", len(cameras)).Msg("Finished inserting gear")
+
+	return films, cameras, nil
+}
+
+func insertGearNames(ctx context.Context, tx *sql.Tx, table string, names []string, postID int64) error {
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	vals := []any{}
+	inserts := []string{}
+	for i, name := range names {
+		inserts = append(inserts, fmt.Sprintf("($%d, $%d)", 2*i+1, 2*i+2))
+		vals = append(vals, name, postID)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (name, post_id) VALUES ", table) + strings.Join(inserts, ",")
+	_, err := tx.ExecContext(ctx, query, vals...)
+	return err
+}
+
+// findGear lists every film or camera from most to least used
+func findGear(ctx context.Context, tx *sql.Tx, table string) (*[]analogdb.GearSummary, error) {
+
+	query := fmt.Sprintf(`
+			SELECT
+				name,
+				COUNT(DISTINCT post_id) as count
+			FROM %s
+			GROUP BY name
+			ORDER BY count DESC, name ASC
+	`, table)
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gear := make([]analogdb.GearSummary, 0)
+	var g analogdb.GearSummary
+	for rows.Next() {
+		if err := rows.Scan(&g.Name, &g.Count); err != nil {
+			return nil, err
+		}
+		gear = append(gear, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &gear, nil
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/evanofslack/analogdb"
+)
+
+func TestFindFilms(t *testing.T) {
+	t.Run("Sorted", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		gs := NewGearService(db)
+
+		films, err := gs.FindFilms(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, film := range *films {
+			if i > 0 && film.Count > (*films)[i-1].Count {
+				t.Fatal("films not sorted most to least used")
+			}
+		}
+	})
+}
+
+func TestFilmPost(t *testing.T) {
+	t.Run("Alias", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+
+		tx, err := db.db.BeginTx(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		film := "portra400"
+		filter := analogdb.NewPostFilter(&limit, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		filter.Film = &film
+		posts, _, err := db.findPosts(context.Background(), tx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, post := range posts {
+			found := false
+			for _, f := range analogdb.ExtractFilms(post.Title) {
+				if f == "Kodak Portra 400" {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("post title %s does not name film %s", post.Title, film)
+			}
+		}
+	})
+	t.Run("Wildcards match literally", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+
+		tx, err := db.db.BeginTx(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		film := "%"
+		filter := analogdb.NewPostFilter(&limit, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		filter.Film = &film
+		posts, _, err := db.findPosts(context.Background(), tx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != 0 {
+			t.Fatalf("wildcard film must not match posts, got %d", len(posts))
+		}
+	})
+}
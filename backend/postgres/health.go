@@ -16,6 +16,17 @@ func NewReadyService(db *DB) *ReadyService {
 	return &ReadyService{db: db}
 }
 
+// Readyz fails until the DB can be reached and every migration is applied
 func (s *ReadyService) Readyz(ctx context.Context) error {
-	return s.db.db.Ping()
+	if err := s.db.db.PingContext(ctx); err != nil {
+		return err
+	}
+	pending, err := s.db.migrationsPending(ctx)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return &analogdb.Error{Code: analogdb.ERRUNAVAILABLE, Message: "Database migrations are pending"}
+	}
+	return nil
 }
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/lib/pq"
+)
+
+// ImportPosts creates a batch of posts in a single transaction. Posts with
+// a permalink that already exists are skipped, or if update is set, patched
+// with the fields a patch can change. Each post is written under its own
+// savepoint, so a post that fails is left out and the rest of the batch kept.
+func (s *PostService) ImportPosts(ctx context.Context, posts []*analogdb.CreatePost, update bool) (*analogdb.ImportResult, error) {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return s.db.importPosts(ctx, tx, posts, update)
+}
+
+func (db *DB) importPosts(ctx context.Context, tx *sql.Tx, posts []*analogdb.CreatePost, update bool) (*analogdb.ImportResult, error) {
+
+	db.logger.Debug().Ctx(ctx).Int("posts", len(posts)).Bool("update", update).Msg("Starting import posts")
+
+	permalinks := make([]string, 0, len(posts))
+	for _, post := range posts {
+		permalinks = append(permalinks, post.Permalink)
+	}
+
+	existing, err := db.findPostIDsByPermalink(ctx, tx, permalinks)
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to import posts")
+		return nil, err
+	}
+
+	result := &analogdb.ImportResult{}
+	for _, post := range posts {
+		// duplicates within a batch are handled the same as posts already in the db
+		id, ok := existing[post.Permalink]
+		if ok && !update {
+			result.Skipped += 1
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_post"); err != nil {
+			db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to import posts")
+			return nil, err
+		}
+
+		if ok {
+			err = db.applyPatch(ctx, tx, importToPatch(post), id)
+		} else {
+			id, err = db.importPost(ctx, tx, post)
+		}
+
+		if err != nil {
+			// the batch can't go on without the connection
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			db.logger.Warn().Err(err).Ctx(ctx).Str("permalink", post.Permalink).Msg("Failed to import post")
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_post"); err != nil {
+				db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to import posts")
+				return nil, err
+			}
+			result.Failed = append(result.Failed, analogdb.ImportFailure{Permalink: post.Permalink, Reason: err.Error()})
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT import_post"); err != nil {
+			db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to import posts")
+			return nil, err
+		}
+		if ok {
+			result.Updated = append(result.Updated, id)
+			continue
+		}
+		existing[post.Permalink] = id
+		result.Created = append(result.Created, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to import posts")
+		return nil, err
+	}
+
+	db.logger.Info().Ctx(ctx).Int("created", len(result.Created)).Int("updated", len(result.Updated)).Int("skipped", result.Skipped).Int("failed", len(result.Failed)).Msg("Finished importing posts")
+
+	return result, nil
+}
+
+// importPost creates a post, checking for duplicates the same as CreatePost
+func (db *DB) importPost(ctx context.Context, tx *sql.Tx, post *analogdb.CreatePost) (int, error) {
+	matches, err := db.checkDuplicate(ctx, tx, post)
+	if err != nil {
+		return 0, err
+	}
+	id, err := db.insertPostAndRelations(ctx, tx, post)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.insertDuplicates(ctx, tx, int(*id), matches); err != nil {
+		return 0, err
+	}
+	return int(*id), nil
+}
+
+// findPostIDsByPermalink maps each of permalinks that belongs to a post to its id
+func (db *DB) findPostIDsByPermalink(ctx context.Context, tx *sql.Tx, permalinks []string) (map[string]int, error) {
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, permalink FROM pictures WHERE permalink = ANY($1)`, pq.Array(permalinks))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var permalink string
+		if err := rows.Scan(&id, &permalink); err != nil {
+			return nil, err
+		}
+		ids[permalink] = id
+	}
+	return ids, rows.Err()
+}
+
+// importToPatch is a patch of every field an import can update
+func importToPatch(post *analogdb.CreatePost) *analogdb.PatchPost {
+	keywords := post.Keywords
+	if keywords == nil {
+		keywords = []analogdb.Keyword{}
+	}
+	return &analogdb.PatchPost{
+		Score:     &post.Score,
+		Nsfw:      &post.Nsfw,
+		Grayscale: post.Grayscale,
+		Sprocket:  post.Sprocket,
+		Colors:    &post.Colors,
+		Keywords:  &keywords,
+	}
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/evanofslack/analogdb"
+)
+
+func TestImportPosts(t *testing.T) {
+	t.Run("Duplicate permalinks", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ps := NewPostService(db)
+
+		testImage := analogdb.Image{
+			Label:  "test",
+			Url:    "test.com",
+			Width:  0,
+			Height: 0,
+		}
+		fourImages := []analogdb.Image{}
+		for _, label := range []string{analogdb.ImageLow, analogdb.ImageMedium, analogdb.ImageHigh, analogdb.ImageRaw} {
+			image := testImage
+			image.Label = label
+			fourImages = append(fourImages, image)
+		}
+
+		testColor := analogdb.Color{
+			Hex:     "#000000",
+			Css:     "Black",
+			Percent: 0.2500000,
+		}
+		fiveColors := []analogdb.Color{testColor, testColor, testColor, testColor, testColor}
+
+		post := analogdb.CreatePost{
+			Title:     "test import",
+			Author:    "test author",
+			Permalink: "test.import.permalink.com",
+			Score:     1,
+			Images:    fourImages,
+			Colors:    fiveColors,
+		}
+		duplicate := post
+		duplicate.Score = 2
+
+		ctx := context.Background()
+
+		// a duplicate in the same batch is skipped
+		result, err := ps.ImportPosts(ctx, []*analogdb.CreatePost{&post, &duplicate}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Created) != 1 || result.Skipped != 1 {
+			t.Fatalf("want 1 created and 1 skipped, got %d created and %d skipped", len(result.Created), result.Skipped)
+		}
+		id := result.Created[0]
+		defer ps.DeletePost(ctx, id)
+
+		// and updated if asked to
+		result, err = ps.ImportPosts(ctx, []*analogdb.CreatePost{&duplicate}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Updated) != 1 || result.Updated[0] != id {
+			t.Fatalf("want post %d updated, got %v", id, result.Updated)
+		}
+
+		updated, err := ps.FindPostByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := duplicate.Score, updated.Score; got != want {
+			t.Fatalf("want score %d, got %d", want, got)
+		}
+
+		// a post that fails to insert is left out of the batch
+		bad := post
+		bad.Permalink = "test.import.bad.permalink.com"
+		bad.Images = append(append([]analogdb.Image{}, fourImages...), fourImages[0])
+		good := post
+		good.Permalink = "test.import.good.permalink.com"
+		// the original image of each post is unique
+		good.Images = []analogdb.Image{}
+		for _, image := range fourImages {
+			image.Url = "test.import.good.com"
+			good.Images = append(good.Images, image)
+		}
+
+		result, err = ps.ImportPosts(ctx, []*analogdb.CreatePost{&bad, &good}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Created) != 1 || len(result.Failed) != 1 {
+			t.Fatalf("want 1 created and 1 failed, got %d created and %d failed", len(result.Created), len(result.Failed))
+		}
+		defer ps.DeletePost(ctx, result.Created[0])
+		if want, got := bad.Permalink, result.Failed[0].Permalink; got != want {
+			t.Fatalf("want %s failed, got %s", want, got)
+		}
+	})
+}
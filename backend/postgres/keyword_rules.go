@@ -0,0 +1,397 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+)
+
+// how long keyword rules are used before reloading from the db
+const keywordRulesTTL = time.Minute
+
+// keywordRulesCache holds the most recently loaded keyword rules
+type keywordRulesCache struct {
+	mu       sync.Mutex
+	rules    *analogdb.KeywordRules
+	loadedAt time.Time
+	// bumped whenever the rules change, so a load that raced
+	// with the change is not kept as fresh
+	generation int
+	loading    bool
+}
+
+// keywordRules gets the current keyword normalization rules, reloading
+// them if they are stale. If the rules cannot be loaded, keywords are
+// still normalized, just without synonyms or a blacklist.
+func (db *DB) keywordRules(ctx context.Context) *analogdb.KeywordRules {
+
+	cache := &db.rulesCache
+
+	// the rules are loaded without holding the lock, so a slow
+	// load doesn't block every post being created. while one
+	// caller reloads, the others keep using the stale rules.
+	cache.mu.Lock()
+	rules := cache.rules
+	if rules != nil && (cache.loading || time.Since(cache.loadedAt) < keywordRulesTTL) {
+		cache.mu.Unlock()
+		return rules
+	}
+	cache.loading = true
+	generation := cache.generation
+	cache.mu.Unlock()
+
+	loaded, err := db.loadKeywordRules(ctx)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.loading = false
+
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to load keyword rules")
+		// keep using the previous rules if we have them
+		return cache.rules
+	}
+
+	cache.rules = loaded
+	if cache.generation == generation {
+		cache.loadedAt = time.Now()
+	}
+	return loaded
+}
+
+// invalidateKeywordRules forces the rules to be reloaded on next use
+func (db *DB) invalidateKeywordRules() {
+	db.rulesCache.mu.Lock()
+	defer db.rulesCache.mu.Unlock()
+	db.rulesCache.loadedAt = time.Time{}
+	db.rulesCache.generation += 1
+}
+
+func (db *DB) loadKeywordRules(ctx context.Context) (*analogdb.KeywordRules, error) {
+
+	db.logger.Debug().Ctx(ctx).Msg("Starting load keyword rules")
+
+	tx, err := db.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	synonyms, err := findKeywordSynonyms(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	blacklist, err := findBlacklistedKeywords(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	db.logger.Info().Ctx(ctx).Int("synonyms", len(*synonyms)).Int("blacklist", len(*blacklist)).Msg("Finished loading keyword rules")
+
+	return analogdb.NewKeywordRules(*synonyms, *blacklist), nil
+}
+
+func (s *KeywordService) FindKeywordSynonyms(ctx context.Context) (*[]analogdb.KeywordSynonym, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Msg("Starting find keyword synonyms")
+	defer s.db.logger.Debug().Ctx(ctx).Msg("Finished find keyword synonyms")
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	synonyms, err := findKeywordSynonyms(ctx, tx)
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find keyword synonyms")
+		return nil, err
+	}
+	return synonyms, nil
+}
+
+func (s *KeywordService) CreateKeywordSynonym(ctx context.Context, synonym *analogdb.KeywordSynonym) error {
+
+	s.db.logger.Debug().Ctx(ctx).Str("synonym", synonym.Synonym).Msg("Starting create keyword synonym")
+
+	if synonym.Synonym == "" || synonym.Word == "" {
+		return &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "Must provide synonym and word"}
+	}
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// synonyms are stored lowercased; the word they map to is
+	// normalized so it matches keywords already stored
+	rules := s.db.keywordRules(ctx)
+	word, _ := rules.NormalizeWord(synonym.Word)
+
+	query := `
+			INSERT INTO keyword_synonyms (synonym, word)
+			VALUES ($1, $2)
+			ON CONFLICT (synonym) DO UPDATE SET word = EXCLUDED.word
+	`
+	if _, err := tx.ExecContext(ctx, query, normalizeRuleWord(synonym.Synonym), word); err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Str("synonym", synonym.Synonym).Msg("Failed to create keyword synonym")
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.db.invalidateKeywordRules()
+
+	s.db.logger.Info().Ctx(ctx).Str("synonym", synonym.Synonym).Msg("Finished creating keyword synonym")
+	return nil
+}
+
+func (s *KeywordService) DeleteKeywordSynonym(ctx context.Context, synonym string) error {
+
+	s.db.logger.Debug().Ctx(ctx).Str("synonym", synonym).Msg("Starting delete keyword synonym")
+
+	query := "DELETE FROM keyword_synonyms WHERE synonym = $1"
+	if err := s.db.deleteKeywordRule(ctx, query, normalizeRuleWord(synonym), "Keyword synonym not found"); err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Str("synonym", synonym).Msg("Failed to delete keyword synonym")
+		return err
+	}
+
+	s.db.logger.Info().Ctx(ctx).Str("synonym", synonym).Msg("Finished deleting keyword synonym")
+	return nil
+}
+
+func (s *KeywordService) FindBlacklistedKeywords(ctx context.Context) (*[]string, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Msg("Starting find blacklisted keywords")
+	defer s.db.logger.Debug().Ctx(ctx).Msg("Finished find blacklisted keywords")
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	blacklist, err := findBlacklistedKeywords(ctx, tx)
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find blacklisted keywords")
+		return nil, err
+	}
+	return blacklist, nil
+}
+
+func (s *KeywordService) CreateBlacklistedKeyword(ctx context.Context, word string) error {
+
+	s.db.logger.Debug().Ctx(ctx).Str("keyword", word).Msg("Starting create blacklisted keyword")
+
+	if word = normalizeRuleWord(word); word == "" {
+		return &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "Must provide word"}
+	}
+
+	query := "INSERT INTO keyword_blacklist (word) VALUES ($1) ON CONFLICT (word) DO NOTHING"
+	if _, err := s.db.db.ExecContext(ctx, query, word); err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Str("keyword", word).Msg("Failed to create blacklisted keyword")
+		return err
+	}
+	s.db.invalidateKeywordRules()
+
+	s.db.logger.Info().Ctx(ctx).Str("keyword", word).Msg("Finished creating blacklisted keyword")
+	return nil
+}
+
+func (s *KeywordService) DeleteBlacklistedKeyword(ctx context.Context, word string) error {
+
+	s.db.logger.Debug().Ctx(ctx).Str("keyword", word).Msg("Starting delete blacklisted keyword")
+
+	query := "DELETE FROM keyword_blacklist WHERE word = $1"
+	if err := s.db.deleteKeywordRule(ctx, query, normalizeRuleWord(word), "Blacklisted keyword not found"); err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Str("keyword", word).Msg("Failed to delete blacklisted keyword")
+		return err
+	}
+
+	s.db.logger.Info().Ctx(ctx).Str("keyword", word).Msg("Finished deleting blacklisted keyword")
+	return nil
+}
+
+// RenormalizeKeywords applies the current keyword rules to all existing
+// keywords. Posts are processed in batches, each in its own transaction,
+// and only posts whose keywords change are rewritten.
+func (s *KeywordService) RenormalizeKeywords(ctx context.Context) (*analogdb.RenormalizeResult, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Msg("Starting renormalize keywords")
+
+	// always use the latest rules, not ones cached or being reloaded
+	rules, err := s.db.loadKeywordRules(ctx)
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to renormalize keywords")
+		return nil, err
+	}
+
+	result := &analogdb.RenormalizeResult{}
+	lastID := 0
+
+	for {
+		n, updated, last, err := s.db.renormalizeKeywordBatch(ctx, rules, lastID)
+		if err != nil {
+			s.db.logger.Error().Err(err).Ctx(ctx).Int("afterID", lastID).Msg("Failed to renormalize keywords")
+			return nil, err
+		}
+		result.Posts += n
+		result.Updated += updated
+		if n < renormalizeBatchSize {
+			break
+		}
+		lastID = last
+	}
+
+	s.db.logger.Info().Ctx(ctx).Int("posts", result.Posts).Int("updated", result.Updated).Msg("Finished renormalizing keywords")
+	return result, nil
+}
+
+// number of posts renormalized per transaction
+const renormalizeBatchSize = 500
+
+// renormalizeKeywordBatch renormalizes keywords of the next batch of posts
+// with an id greater than afterID. Returns the number of posts processed,
+// the number updated and the last post id processed.
+func (db *DB) renormalizeKeywordBatch(ctx context.Context, rules *analogdb.KeywordRules, afterID int) (int, int, int, error) {
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+			SELECT k.post_id, k.word, k.weight
+			FROM keywords k
+			WHERE k.post_id IN (
+				SELECT DISTINCT post_id FROM keywords
+				WHERE post_id > $1
+				ORDER BY post_id ASC
+				LIMIT $2
+			)
+			ORDER BY k.post_id ASC
+	`
+	rows, err := tx.QueryContext(ctx, query, afterID, renormalizeBatchSize)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	ids := []int{}
+	keywords := make(map[int][]analogdb.Keyword)
+	for rows.Next() {
+		var id int
+		var kw analogdb.Keyword
+		if err := rows.Scan(&id, &kw.Word, &kw.Weight); err != nil {
+			rows.Close()
+			return 0, 0, 0, err
+		}
+		if _, ok := keywords[id]; !ok {
+			ids = append(ids, id)
+		}
+		keywords[id] = append(keywords[id], kw)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, 0, err
+	}
+
+	updated := 0
+	for _, id := range ids {
+		normalized := rules.NormalizeKeywords(keywords[id])
+		if keywordsEqual(keywords[id], normalized) {
+			continue
+		}
+		if err := db.updateKeywords(ctx, tx, normalized, id); err != nil {
+			return 0, 0, 0, err
+		}
+		updated += 1
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, 0, err
+	}
+
+	last := 0
+	if len(ids) > 0 {
+		last = ids[len(ids)-1]
+	}
+	return len(ids), updated, last, nil
+}
+
+func (db *DB) deleteKeywordRule(ctx context.Context, query string, arg string, notFound string) error {
+
+	res, err := db.db.ExecContext(ctx, query, arg)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &analogdb.Error{Code: analogdb.ERRNOTFOUND, Message: notFound}
+	}
+	db.invalidateKeywordRules()
+	return nil
+}
+
+func findKeywordSynonyms(ctx context.Context, tx *sql.Tx) (*[]analogdb.KeywordSynonym, error) {
+
+	rows, err := tx.QueryContext(ctx, "SELECT synonym, word FROM keyword_synonyms ORDER BY synonym ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	synonyms := make([]analogdb.KeywordSynonym, 0)
+	var s analogdb.KeywordSynonym
+	for rows.Next() {
+		if err := rows.Scan(&s.Synonym, &s.Word); err != nil {
+			return nil, err
+		}
+		synonyms = append(synonyms, s)
+	}
+	return &synonyms, rows.Err()
+}
+
+func findBlacklistedKeywords(ctx context.Context, tx *sql.Tx) (*[]string, error) {
+
+	rows, err := tx.QueryContext(ctx, "SELECT word FROM keyword_blacklist ORDER BY word ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blacklist := make([]string, 0)
+	var word string
+	for rows.Next() {
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		blacklist = append(blacklist, word)
+	}
+	return &blacklist, rows.Err()
+}
+
+// normalizeRuleWord lowercases and trims a synonym or blacklisted word
+func normalizeRuleWord(word string) string {
+	return strings.ToLower(strings.TrimSpace(word))
+}
+
+func keywordsEqual(a, b []analogdb.Keyword) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -3,10 +3,20 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/evanofslack/analogdb"
 )
 
+const (
+	// number of buckets in keyword weight distribution
+	weightBuckets = 10
+
+	// minimum number of posts two keywords must share to be related
+	minCooccurrence = 3
+)
+
 // ensure interface is implemented
 var _ analogdb.KeywordService = (*KeywordService)(nil)
 
@@ -18,6 +28,66 @@ func NewKeywordService(db *DB) *KeywordService {
 	return &KeywordService{db: db}
 }
 
+func (s *KeywordService) FindKeywords(ctx context.Context, filter *analogdb.KeywordFilter) (*[]analogdb.KeywordSummary, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Msg("Starting find keywords")
+	defer s.db.logger.Debug().Ctx(ctx).Msg("Finished find keywords")
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	keywords, err := findKeywords(ctx, tx, filter)
+
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find keywords")
+		return nil, err
+	}
+
+	return keywords, nil
+}
+
+func (s *KeywordService) FindKeywordByWord(ctx context.Context, word string) (*analogdb.KeywordDetail, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Str("keyword", word).Msg("Starting find keyword by word")
+	defer s.db.logger.Debug().Ctx(ctx).Str("keyword", word).Msg("Finished find keyword by word")
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	keyword, err := findKeywordByWord(ctx, tx, word)
+
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Str("keyword", word).Msg("Failed to find keyword by word")
+		return nil, err
+	}
+
+	return keyword, nil
+}
+
+func (s *KeywordService) FindRelatedKeywords(ctx context.Context, word string, limit int) (*[]analogdb.RelatedKeyword, error) {
+
+	s.db.logger.Debug().Ctx(ctx).Str("keyword", word).Msg("Starting find related keywords")
+	defer s.db.logger.Debug().Ctx(ctx).Str("keyword", word).Msg("Finished find related keywords")
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	related, err := findRelatedKeywords(ctx, tx, word, limit)
+
+	if err != nil {
+		s.db.logger.Error().Err(err).Ctx(ctx).Str("keyword", word).Msg("Failed to find related keywords")
+		return nil, err
+	}
+
+	return related, nil
+}
+
 func (s *KeywordService) GetKeywordSummary(ctx context.Context, limit int) (*[]analogdb.KeywordSummary, error) {
 
 	s.db.logger.Debug().Ctx(ctx).Msg("Starting find keyword summary")
@@ -72,3 +142,174 @@ func getKeywordSummary(ctx context.Context, tx *sql.Tx, limit int) (*[]analogdb.
 	}
 	return &keywords, nil
 }
+
+// findKeywords lists keywords from most to least used,
+// optionally only those starting with a prefix
+func findKeywords(ctx context.Context, tx *sql.Tx, filter *analogdb.KeywordFilter) (*[]analogdb.KeywordSummary, error) {
+
+	index := 1
+	where, args := []string{"1=1"}, []any{}
+
+	if prefix := filter.Prefix; prefix != nil {
+		where = append(where, fmt.Sprintf("word LIKE $%d", index))
+		args = append(args, escapeLike(strings.ToLower(*prefix))+"%")
+		index += 1
+	}
+
+	limit := ""
+	if l := filter.Limit; l != nil && *l > 0 {
+		limit = fmt.Sprintf(" LIMIT %d", *l)
+	}
+
+	query := fmt.Sprintf(`
+			SELECT
+				word,
+				count(word) as count
+			FROM keywords
+			WHERE %s
+			GROUP BY word
+			ORDER BY count DESC, word ASC
+	`, strings.Join(where, " AND ")) + limit
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keywords := make([]analogdb.KeywordSummary, 0)
+	var kw analogdb.KeywordSummary
+	for rows.Next() {
+		if err := rows.Scan(&kw.Word, &kw.Count); err != nil {
+			return nil, err
+		}
+		keywords = append(keywords, kw)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &keywords, nil
+}
+
+func findKeywordByWord(ctx context.Context, tx *sql.Tx, word string) (*analogdb.KeywordDetail, error) {
+
+	query := `
+			SELECT
+				COUNT(*),
+				COALESCE(AVG(weight), 0)
+			FROM keywords
+			WHERE word = $1
+	`
+
+	keyword := &analogdb.KeywordDetail{Word: word}
+
+	if err := tx.QueryRowContext(ctx, query, word).Scan(&keyword.Count, &keyword.AverageWeight); err != nil {
+		return nil, err
+	}
+
+	if keyword.Count == 0 {
+		return nil, &analogdb.Error{Code: analogdb.ERRNOTFOUND, Message: "Keyword not found"}
+	}
+
+	// weights are between 0 and 1, a weight of exactly 1
+	// falls outside the last bucket so clamp it back in.
+	query = `
+			SELECT
+				LEAST(WIDTH_BUCKET(weight, 0, 1, $2), $2) as bucket,
+				COUNT(*)
+			FROM keywords
+			WHERE word = $1 AND weight IS NOT NULL
+			GROUP BY bucket
+			ORDER BY bucket ASC
+	`
+
+	rows, err := tx.QueryContext(ctx, query, word, weightBuckets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// include empty buckets so distribution is always the same shape
+	width := 1.0 / weightBuckets
+	distribution := make([]analogdb.WeightBucket, weightBuckets)
+	for i := range distribution {
+		distribution[i] = analogdb.WeightBucket{Min: float64(i) * width, Max: float64(i+1) * width}
+	}
+
+	var bucket, count int
+	for rows.Next() {
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		// buckets are 1 indexed, 0 is for weights below the range
+		if bucket < 1 {
+			bucket = 1
+		}
+		distribution[bucket-1].Count += count
+	}
+	keyword.WeightDistribution = distribution
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return keyword, nil
+}
+
+// findRelatedKeywords finds keywords that appear on the same posts as word,
+// ranked by pointwise mutual information:
+//
+//	pmi(a, b) = ln(p(a, b) / (p(a) * p(b)))
+//	          = ln(n * count(a, b) / (count(a) * count(b)))
+//
+// where n is the total number of posts with keywords. Rare keywords
+// have inflated PMI, so pairs must share at least minCooccurrence posts.
+func findRelatedKeywords(ctx context.Context, tx *sql.Tx, word string, limit int) (*[]analogdb.RelatedKeyword, error) {
+
+	query := `
+			WITH
+				total AS (
+					SELECT COUNT(DISTINCT post_id)::float AS n FROM keywords
+				),
+				target AS (
+					SELECT DISTINCT post_id FROM keywords WHERE word = $1
+				),
+				counts AS (
+					SELECT word, COUNT(DISTINCT post_id)::float AS c FROM keywords GROUP BY word
+				)
+			SELECT
+				k.word,
+				COUNT(DISTINCT k.post_id) as count,
+				LN(total.n * COUNT(DISTINCT k.post_id) / ((SELECT COUNT(*) FROM target) * counts.c)) as pmi
+			FROM keywords k
+			INNER JOIN target t ON t.post_id = k.post_id
+			INNER JOIN counts ON counts.word = k.word
+			CROSS JOIN total
+			WHERE k.word <> $1
+			GROUP BY k.word, counts.c, total.n
+			HAVING COUNT(DISTINCT k.post_id) >= $2
+			ORDER BY pmi DESC, count DESC
+			LIMIT $3
+	`
+
+	rows, err := tx.QueryContext(ctx, query, word, minCooccurrence, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	related := make([]analogdb.RelatedKeyword, 0)
+	var kw analogdb.RelatedKeyword
+	for rows.Next() {
+		if err := rows.Scan(&kw.Word, &kw.Count, &kw.PMI); err != nil {
+			return nil, err
+		}
+		related = append(related, kw)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &related, nil
+}
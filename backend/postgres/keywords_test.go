@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/evanofslack/analogdb"
+)
+
+func TestFindKeywords(t *testing.T) {
+	t.Run("Prefix", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ks := NewKeywordService(db)
+
+		prefix := "po"
+		filter := &analogdb.KeywordFilter{Limit: &limit, Prefix: &prefix}
+
+		keywords, err := ks.FindKeywords(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*keywords) == 0 {
+			t.Fatal("must be at least one matching keyword")
+		}
+		for i, kw := range *keywords {
+			if !strings.HasPrefix(kw.Word, prefix) {
+				t.Fatalf("keyword %s does not start with prefix %s", kw.Word, prefix)
+			}
+			if i > 0 && kw.Count > (*keywords)[i-1].Count {
+				t.Fatal("keywords not sorted most to least used")
+			}
+		}
+	})
+	t.Run("Wildcard prefix", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ks := NewKeywordService(db)
+
+		// wildcards match literally, not every keyword
+		prefix := "%_"
+		filter := &analogdb.KeywordFilter{Limit: &limit, Prefix: &prefix}
+
+		keywords, err := ks.FindKeywords(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, kw := range *keywords {
+			if !strings.HasPrefix(kw.Word, prefix) {
+				t.Fatalf("keyword %s does not start with prefix %s", kw.Word, prefix)
+			}
+		}
+	})
+}
+
+func TestFindKeywordByWord(t *testing.T) {
+	t.Run("Weight distribution", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ks := NewKeywordService(db)
+
+		summary, err := ks.GetKeywordSummary(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		top := (*summary)[0]
+
+		keyword, err := ks.FindKeywordByWord(context.Background(), top.Word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := keyword.Count, top.Count; got != want {
+			t.Fatalf("wrong keyword count, want %d, got %d", want, got)
+		}
+		total := 0
+		for _, bucket := range keyword.WeightDistribution {
+			total += bucket.Count
+		}
+		if total > keyword.Count {
+			t.Fatalf("weight distribution total %d exceeds keyword count %d", total, keyword.Count)
+		}
+	})
+	t.Run("Nonexisting keyword", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ks := NewKeywordService(db)
+
+		if _, err := ks.FindKeywordByWord(context.Background(), "not-a-real-keyword"); err == nil {
+			t.Fatal("error should be returned when no matching keyword is found")
+		}
+	})
+}
+
+func TestKeywordRules(t *testing.T) {
+	t.Run("Synonym", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ks := NewKeywordService(db)
+		ctx := context.Background()
+
+		synonym := &analogdb.KeywordSynonym{Synonym: "Monochromes", Word: "blackandwhite"}
+		if err := ks.CreateKeywordSynonym(ctx, synonym); err != nil {
+			t.Fatal(err)
+		}
+		defer ks.DeleteKeywordSynonym(ctx, synonym.Synonym)
+
+		rules := db.keywordRules(ctx)
+		for _, word := range []string{"monochromes", "B&W", "bw"} {
+			if got, ok := rules.NormalizeWord(word); !ok || got != "blackandwhite" {
+				t.Fatalf("want %s normalized to blackandwhite, got %s", word, got)
+			}
+		}
+	})
+	t.Run("Blacklist", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ks := NewKeywordService(db)
+		ctx := context.Background()
+
+		if err := ks.CreateBlacklistedKeyword(ctx, "grainy"); err != nil {
+			t.Fatal(err)
+		}
+		defer ks.DeleteBlacklistedKeyword(ctx, "grainy")
+
+		keywords := []analogdb.Keyword{{Word: "Grainy", Weight: 0.9}, {Word: "Mountains", Weight: 0.5}, {Word: "mountain", Weight: 0.7}}
+		normalized := db.keywordRules(ctx).NormalizeKeywords(keywords)
+		if len(normalized) != 1 {
+			t.Fatalf("want 1 normalized keyword, got %d", len(normalized))
+		}
+		if got, want := normalized[0], (analogdb.Keyword{Word: "mountain", Weight: 0.7}); got != want {
+			t.Fatalf("want %v, got %v", want, got)
+		}
+	})
+}
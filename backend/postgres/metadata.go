@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/evanofslack/analogdb"
+)
+
+// ensure interface is implemented
+var _ analogdb.MetadataService = (*MetadataService)(nil)
+
+type MetadataService struct {
+	db *DB
+}
+
+func NewMetadataService(db *DB) *MetadataService {
+	return &MetadataService{db: db}
+}
+
+func (s *MetadataService) SetPostMetadata(ctx context.Context, postID int, metadata *analogdb.PostMetadata) error {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM pictures WHERE id = $1)`, postID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return &analogdb.Error{Code: analogdb.ERRNOTFOUND, Message: "Post not found"}
+	}
+
+	if err := s.db.upsertMetadata(ctx, tx, metadata, int64(postID)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MetadataService) PostIDsWithoutMetadata(ctx context.Context) ([]int, error) {
+
+	query := `
+	SELECT p.id
+	FROM pictures p
+	LEFT OUTER JOIN post_metadata m ON m.post_id = p.id
+	WHERE m.post_id IS NULL
+	ORDER BY p.id`
+
+	rows, err := s.db.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// upsertMetadata stores the metadata of a post, replacing any read before
+func (db *DB) upsertMetadata(ctx context.Context, tx *sql.Tx, m *analogdb.PostMetadata, postID int64) error {
+
+	db.logger.Debug().Ctx(ctx).Int64("postID", postID).Msg("Starting upsert metadata")
+
+	query := `
+	INSERT INTO post_metadata
+	(post_id, camera_make, camera_model, lens, focal_length, exposure_time, f_number, iso, software, color_profile, file_size, has_exif)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	ON CONFLICT (post_id) DO UPDATE SET
+		camera_make = EXCLUDED.camera_make,
+		camera_model = EXCLUDED.camera_model,
+		lens = EXCLUDED.lens,
+		focal_length = EXCLUDED.focal_length,
+		exposure_time = EXCLUDED.exposure_time,
+		f_number = EXCLUDED.f_number,
+		iso = EXCLUDED.iso,
+		software = EXCLUDED.software,
+		color_profile = EXCLUDED.color_profile,
+		file_size = EXCLUDED.file_size,
+		has_exif = EXCLUDED.has_exif`
+
+	// fields that weren't read are stored as null rather than zero
+	_, err := tx.ExecContext(ctx, query,
+		postID,
+		sql.NullString{String: m.CameraMake, Valid: m.CameraMake != ""},
+		sql.NullString{String: m.CameraModel, Valid: m.CameraModel != ""},
+		sql.NullString{String: m.Lens, Valid: m.Lens != ""},
+		sql.NullFloat64{Float64: m.FocalLength, Valid: m.FocalLength != 0},
+		sql.NullString{String: m.ExposureTime, Valid: m.ExposureTime != ""},
+		sql.NullFloat64{Float64: m.FNumber, Valid: m.FNumber != 0},
+		sql.NullInt64{Int64: int64(m.ISO), Valid: m.ISO != 0},
+		sql.NullString{String: m.Software, Valid: m.Software != ""},
+		sql.NullString{String: m.ColorProfile, Valid: m.ColorProfile != ""},
+		sql.NullInt64{Int64: m.FileSize, Valid: m.FileSize != 0},
+		m.HasExif,
+	)
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Int64("postID", postID).Msg("Failed to upsert metadata")
+		return err
+	}
+
+	db.logger.Debug().Ctx(ctx).Int64("postID", postID).Msg("Finished upsert metadata")
+	return nil
+}
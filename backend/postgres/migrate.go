@@ -0,0 +1,348 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// migrations are named <version>_<name>.<up|down>.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// key of the advisory lock held while migrating,
+// so replicas starting together don't race
+const migrationLockKey = 7253914011
+
+// Migration is a single versioned change to the schema
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus is the state of the schema compared to the embedded migrations
+type MigrationStatus struct {
+	// latest applied migration, 0 if none are
+	Version int
+	// a migration failed part way, the schema must be fixed by hand
+	Dirty   bool
+	Applied []Migration
+	Pending []Migration
+}
+
+// loadMigrations reads the embedded migrations in order of version
+func loadMigrations() ([]Migration, error) {
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+		content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if match[3] == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d is missing an up file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrationStatus compares the applied migrations with the embedded ones
+func (db *DB) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	version, dirty, err := db.schemaVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{Version: version, Dirty: dirty}
+	for _, m := range migrations {
+		if m.Version <= version {
+			status.Applied = append(status.Applied, m)
+		} else {
+			status.Pending = append(status.Pending, m)
+		}
+	}
+	return status, nil
+}
+
+// MigrateUp applies every pending migration, returning how many were applied
+func (db *DB) MigrateUp(ctx context.Context) (int, error) {
+
+	db.logger.Debug().Ctx(ctx).Msg("Starting migrate up")
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	unlock, err := db.lockMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	// read the version once locked, another replica may have just migrated
+	version, dirty, err := db.schemaVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, dirtyError(version)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		db.logger.Info().Ctx(ctx).Int("version", m.Version).Str("name", m.Name).Msg("Applying migration")
+		if err := db.runMigration(ctx, conn, m.up, m.Version, m.Version); err != nil {
+			db.logger.Error().Err(err).Ctx(ctx).Int("version", m.Version).Msg("Failed to apply migration")
+			return applied, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		applied += 1
+	}
+
+	db.logger.Info().Ctx(ctx).Int("applied", applied).Msg("Finished migrate up")
+	return applied, nil
+}
+
+// MigrateDown reverts the latest steps applied migrations, returning how many were reverted
+func (db *DB) MigrateDown(ctx context.Context, steps int) (int, error) {
+
+	db.logger.Debug().Ctx(ctx).Int("steps", steps).Msg("Starting migrate down")
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	unlock, err := db.lockMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	// read the version once locked, another replica may have just migrated
+	version, dirty, err := db.schemaVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, dirtyError(version)
+	}
+
+	reverted := 0
+	for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+		m := migrations[i]
+		if m.Version > version {
+			continue
+		}
+		if m.down == "" {
+			return reverted, fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+		previous := 0
+		if i > 0 {
+			previous = migrations[i-1].Version
+		}
+		db.logger.Info().Ctx(ctx).Int("version", m.Version).Str("name", m.Name).Msg("Reverting migration")
+		if err := db.runMigration(ctx, conn, m.down, m.Version, previous); err != nil {
+			db.logger.Error().Err(err).Ctx(ctx).Int("version", m.Version).Msg("Failed to revert migration")
+			return reverted, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		reverted += 1
+	}
+
+	db.logger.Info().Ctx(ctx).Int("reverted", reverted).Msg("Finished migrate down")
+	return reverted, nil
+}
+
+// ForceMigrationVersion sets the schema version without running any migrations,
+// clearing the dirty flag once a failed migration has been fixed by hand
+func (db *DB) ForceMigrationVersion(ctx context.Context, version int) error {
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := db.lockMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := db.ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+	return setSchemaVersion(ctx, conn, version, false)
+}
+
+// lockMigrations blocks until no other session is migrating. The lock is held
+// by the session of conn, so it is released if the process dies part way.
+func (db *DB) lockMigrations(ctx context.Context, conn *sql.Conn) (func(), error) {
+	db.logger.Debug().Ctx(ctx).Msg("Waiting for migration lock")
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return nil, fmt.Errorf("Failed to acquire migration lock: %w", err)
+	}
+	return func() {
+		// unlock even if ctx was cancelled, conn goes back to the pool
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+			db.logger.Error().Err(err).Msg("Failed to release migration lock")
+		}
+	}, nil
+}
+
+// migrationsPending reports if the schema is behind the embedded migrations,
+// or dirty from a failed migration. The schema is only read, never created.
+func (db *DB) migrationsPending(ctx context.Context) (bool, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return false, err
+	}
+	latest := 0
+	if n := len(migrations); n > 0 {
+		latest = migrations[n-1].Version
+	}
+
+	var exists bool
+	if err := db.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return false, err
+	}
+	if !exists {
+		return latest > 0, nil
+	}
+
+	var version int
+	var dirty bool
+	err = db.db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return latest > 0, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return dirty || version < latest, nil
+}
+
+// runMigration runs the statements of a migration, marking the schema dirty
+// at from while it runs and moving it to to once it succeeds
+func (db *DB) runMigration(ctx context.Context, conn *sql.Conn, statements string, from, to int) error {
+	if err := setSchemaVersion(ctx, conn, from, true); err != nil {
+		return err
+	}
+	// some migrations manage their own transaction, so run them outside of one
+	if _, err := conn.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	return setSchemaVersion(ctx, conn, to, false)
+}
+
+// schemaMigrationsTable matches the table used by golang-migrate,
+// so schemas migrated with its cli are picked up where they left off
+const schemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`
+
+func (db *DB) ensureMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, schemaMigrationsTable)
+	return err
+}
+
+// schemaVersion is the latest applied migration and whether it failed part way
+func (db *DB) schemaVersion(ctx context.Context, conn *sql.Conn) (int, bool, error) {
+	if err := db.ensureMigrationsTable(ctx, conn); err != nil {
+		return 0, false, err
+	}
+	var version int
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// setSchemaVersion replaces the single row of schema_migrations.
+// A version of 0 means no migrations are applied.
+func setSchemaVersion(ctx context.Context, conn *sql.Conn, version int, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if version > 0 || dirty {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func dirtyError(version int) error {
+	return fmt.Errorf("schema is dirty at version %d, fix the failed migration then force the version", version)
+}
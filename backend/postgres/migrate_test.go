@@ -0,0 +1,21 @@
+package postgres
+
+import "testing"
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("expected migration %d to have version %d, got %d", i, i+1, m.Version)
+		}
+		if m.up == "" || m.down == "" {
+			t.Errorf("expected migration %d_%s to have up and down files", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS keyword_synonyms;
+DROP TABLE IF EXISTS keyword_blacklist;
+
+COMMIT;
@@ -0,0 +1,696 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS keyword_synonyms(
+id SERIAL PRIMARY KEY,
+synonym VARCHAR(255) UNIQUE NOT NULL,
+word VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS keyword_blacklist(
+id SERIAL PRIMARY KEY,
+word VARCHAR(255) UNIQUE NOT NULL
+);
+
+INSERT INTO keyword_synonyms (synonym, word) VALUES
+('b&w', 'blackandwhite'),
+('b/w', 'blackandwhite'),
+('bw', 'blackandwhite'),
+('bnw', 'blackandwhite'),
+('blackwhite', 'blackandwhite')
+ON CONFLICT (synonym) DO NOTHING;
+
+INSERT INTO keyword_blacklist (word) VALUES
+('film'),
+('great'),
+('shot'),
+('thanks'),
+('photo'),
+('good'),
+('nice'),
+('beautiful'),
+('camera'),
+('people'),
+('light'),
+('image'),
+('time'),
+('photos'),
+('work'),
+('amazing'),
+('cool'),
+('lot'),
+('way'),
+('lens'),
+('picture'),
+('colors'),
+('bit'),
+('exposure'),
+('sure'),
+('color'),
+('shots'),
+('awesome'),
+('composition'),
+('little'),
+('lol'),
+('mm'),
+('photography'),
+('different'),
+('right'),
+('better'),
+('thing'),
+('lab'),
+('second'),
+('interesting'),
+('post'),
+('digital'),
+('frame'),
+('subject'),
+('roll'),
+('place'),
+('shutter'),
+('focus'),
+('art'),
+('day'),
+('point'),
+('best'),
+('images'),
+('years'),
+('comment'),
+('look'),
+('lovely'),
+('lighting'),
+('gorgeous'),
+('pictures'),
+('person'),
+('kind'),
+('speed'),
+('perfect'),
+('things'),
+('incredible'),
+('job'),
+('op'),
+('big'),
+('scan'),
+('negative'),
+('stuff'),
+('cameras'),
+('fantastic'),
+('filter'),
+('photographer'),
+('stunning'),
+('format'),
+('bad'),
+('fun'),
+('analog'),
+('pic'),
+('eye'),
+('sub'),
+('edit'),
+('happy'),
+('haha'),
+('scene'),
+('meter'),
+('background'),
+('long'),
+('print'),
+('excellent'),
+('high'),
+('wrong'),
+('iso'),
+('idea'),
+('similar'),
+('r'),
+('scans'),
+('fine'),
+('sense'),
+('guy'),
+('favorite'),
+('year'),
+('issue'),
+('hard'),
+('process'),
+('comments'),
+('lenses'),
+('question'),
+('colours'),
+('scanner'),
+('️'),
+('love'),
+('times'),
+('real'),
+('rolls'),
+('able'),
+('instagram'),
+('world'),
+('borders'),
+('results'),
+('wonderful'),
+('effect'),
+('border'),
+('colour'),
+('case'),
+('story'),
+('left'),
+('reason'),
+('aperture'),
+('opinion'),
+('exposures'),
+('stop'),
+('shit'),
+('area'),
+('highlights'),
+('public'),
+('reddit'),
+('glad'),
+('interested'),
+('moment'),
+('photograph'),
+('low'),
+('days'),
+('sorry'),
+('title'),
+('problem'),
+('experience'),
+('detail'),
+('close'),
+('quality'),
+('true'),
+('super'),
+('vibe'),
+('negatives'),
+('framing'),
+('balance'),
+('painting'),
+('course'),
+('angle'),
+('use'),
+('mind'),
+('👏'),
+('example'),
+('critique'),
+('tones'),
+('t'),
+('possible'),
+('sick'),
+('difference'),
+('fact'),
+('worth'),
+('pics'),
+('crop'),
+('words'),
+('easy'),
+('settings'),
+('prints'),
+('album'),
+('places'),
+('sharing'),
+('ones'),
+('curious'),
+('box'),
+('open'),
+('type'),
+('vibes'),
+('slide'),
+('feedback'),
+('perspective'),
+('style'),
+('field'),
+('scanning'),
+('series'),
+('end'),
+('rest'),
+('cover'),
+('half'),
+('dslr'),
+('filters'),
+('context'),
+('dude'),
+('f'),
+('setup'),
+('normal'),
+('correct'),
+('films'),
+('processing'),
+('general'),
+('range'),
+('original'),
+('stops'),
+('tho'),
+('fan'),
+('line'),
+('🙏'),
+('middle'),
+('lucky'),
+('capture'),
+('room'),
+('video'),
+('higher'),
+('congrats'),
+('frames'),
+('single'),
+('🏻'),
+('lower'),
+('focal'),
+('photographers'),
+('level'),
+('bw 46'),
+('number'),
+('price'),
+('set'),
+('development'),
+('self'),
+('darkroom'),
+('details'),
+('welcome'),
+('sharpness'),
+('size'),
+('dev'),
+('photoshop'),
+('version'),
+('🔥'),
+('feeling'),
+('pro'),
+('week'),
+('insane'),
+('dope'),
+('specific'),
+('shoot'),
+('entire'),
+('ig'),
+('sort'),
+('exact'),
+('foreground'),
+('👌'),
+('lightroom'),
+('hours'),
+('particular'),
+('areas'),
+('form'),
+('distance'),
+('beauty'),
+('difficult'),
+('quick'),
+('today'),
+('special'),
+('sweet'),
+('advice'),
+('free'),
+('info'),
+('multiple'),
+('message'),
+('chance'),
+('ass'),
+('lmao'),
+('actual'),
+('main'),
+('plenty'),
+('extra'),
+('piece'),
+('gear'),
+('thoughts'),
+('tbh'),
+('fucking'),
+('hour'),
+('parts'),
+('cheers'),
+('xa'),
+('lots'),
+('ebay'),
+('attention'),
+('feel'),
+('location'),
+('weeks'),
+('guys'),
+('impressive'),
+('shape'),
+('photographs'),
+('cheaper'),
+('metering'),
+('resolution'),
+('concept'),
+('😍'),
+('posts'),
+('content'),
+('minutes'),
+('labs'),
+('zoom'),
+('certain'),
+('slides'),
+('months'),
+('short'),
+('morning'),
+('favourite'),
+('lack'),
+('thought'),
+('profile'),
+('screen'),
+('result'),
+('fair'),
+('manual'),
+('bunch'),
+('early'),
+('past'),
+('project'),
+('length'),
+('fuck'),
+('killer'),
+('available'),
+('character'),
+('criticism'),
+('u'),
+('answer'),
+('decent'),
+('response'),
+('w'),
+('okay'),
+('😁'),
+('seconds'),
+('technique'),
+('mode'),
+('viewfinder'),
+('handheld'),
+('direction'),
+('subreddit'),
+('editing'),
+('likely'),
+('😊'),
+('setting'),
+('system'),
+('upvotes'),
+('reasons'),
+('wider'),
+('subjects'),
+('temperature'),
+('proper'),
+('rule'),
+('terms'),
+('scenes'),
+('tone'),
+('artistic'),
+('game'),
+('ways'),
+('x200b'),
+('excited'),
+('overall'),
+('tough'),
+('rodinal'),
+('priority'),
+('f3'),
+('compliment'),
+('ok'),
+('expression'),
+('brilliant'),
+('reciprocity'),
+('matter'),
+('c41'),
+('reply'),
+('luck'),
+('willing'),
+('conditions'),
+('bigger'),
+('smaller'),
+('bravo'),
+('popular'),
+('chemicals'),
+('final'),
+('tri'),
+('pretty'),
+('epic'),
+('positive'),
+('situations'),
+('slight'),
+('intention'),
+('need'),
+('rules'),
+('intent'),
+('word'),
+('polarizer'),
+('bulk'),
+('mate'),
+('common'),
+('aesthetic'),
+('link'),
+('equipment'),
+('non'),
+('easier'),
+('month'),
+('order'),
+('neat'),
+('release'),
+('source'),
+('vision'),
+('2nd'),
+('closer'),
+('interpretation'),
+('longer'),
+('information'),
+('mount'),
+('prices'),
+('effort'),
+('visible'),
+('ratio'),
+('idk'),
+('congratulations'),
+('helpful'),
+('questions'),
+('shooting'),
+('unreal'),
+('mid'),
+('x'),
+('spectacular'),
+('imho'),
+('ton'),
+('artist'),
+('weight'),
+('thread'),
+('dpi'),
+('situation'),
+('res'),
+('taste'),
+('previous'),
+('absolute'),
+('zone'),
+('aspect'),
+('position'),
+('internet'),
+('points'),
+('older'),
+('age'),
+('interest'),
+('f8'),
+('kinda'),
+('objects'),
+('contact'),
+('tank'),
+('holder'),
+('sensor'),
+('darker'),
+('impossible'),
+('statement'),
+('minute'),
+('larger'),
+('option'),
+('help'),
+('wallpaper'),
+('bro'),
+('la'),
+('control'),
+('tight'),
+('sensitive'),
+('failure'),
+('layer'),
+('stronger'),
+('😂'),
+('omg'),
+('clarity'),
+('slider'),
+('developer'),
+('reading'),
+('woah'),
+('account'),
+('website'),
+('hahaha'),
+('ppi'),
+('dof'),
+('yea'),
+('try'),
+('user'),
+('impressed'),
+('sec'),
+('printing'),
+('app'),
+('fav'),
+('sprockets'),
+('blacks'),
+('google'),
+('insta'),
+('youtube'),
+('cropping'),
+('ya'),
+('ha'),
+('downvotes'),
+('downvote'),
+('upvote'),
+('enlarger'),
+('neg'),
+('suggestion'),
+('award'),
+('san'),
+('nd'),
+('ish'),
+('1st'),
+('3rd'),
+('cle'),
+('term'),
+('correction'),
+('biggest'),
+('hi'),
+('total'),
+('ahh'),
+('mins'),
+('secs'),
+('de'),
+('ps'),
+('hahah'),
+('ir'),
+('oo'),
+('ii'),
+('worse'),
+('slower'),
+('st'),
+('ad'),
+('bot'),
+('pre'),
+('un'),
+('aps'),
+('<200d>'),
+('min'),
+('af'),
+('lr'),
+('mf'),
+('fd'),
+('ppl'),
+('iirc'),
+('share&utm_medium'),
+('ios_app&utm_name'),
+('yo'),
+('upload'),
+('soooo'),
+('sooo'),
+('soo'),
+('so'),
+('fi'),
+('+1'),
+('w/'),
+('lookin'),
+('ugh'),
+('bruh'),
+('idgaf'),
+('hie'),
+('irl'),
+('afk'),
+('ei'),
+('fyi'),
+('tldr'),
+('4th'),
+('je'),
+('5th'),
+('jk'),
+('keh'),
+('keck'),
+('ahaha'),
+('ups'),
+('/u'),
+(':p'),
+(':d'),
+(':)'),
+('<3'),
+('cn'),
+('hee'),
+('el'),
+('mt'),
+('ttl'),
+('moderator'),
+('gr'),
+('iv'),
+('i''ve'),
+('+2'),
+('mp'),
+('d.'),
+('di'),
+('=)'),
+('ooo'),
+('da'),
+('eg'),
+('aa'),
+('iii'),
+('oof'),
+('cl'),
+('\~'),
+('aye'),
+('al'),
+(':o'),
+('/r'),
+('sq'),
+('ffs'),
+('t.'),
+('boi'),
+('ft'),
+('jfc'),
+('ol'),
+('p.s'),
+('ayyy'),
+('ist'),
+('lo'),
+('ofc'),
+('ns'),
+('loll'),
+('ngl'),
+('mmm'),
+('cv'),
+('~3'),
+('~2'),
+('~1'),
+('it´s'),
+('tbf'),
+('ss'),
+('¯\_(ツ)_/¯'),
+('à'),
+('il'),
+('en'),
+('x.'),
+('sw'),
+('s.'),
+('rn'),
+('fe'),
+('lx'),
+('ae'),
+('+4'),
+('⁠i'),
+('huh'),
+('wow'),
+('u.s'),
+('co.'),
+('ol'''),
+('ag'),
+('ef'),
+('.i'),
+('sr'),
+('artists'),
+('wtf'),
+('lovey'),
+('ur'),
+('xd'),
+('dm'),
+('imo'),
+('fuckin'),
+('yoo'),
+('yooo'),
+('yoooo'),
+('yasss'),
+('lil'),
+('nvm'),
+('circlejerk'),
+('alot')
+ON CONFLICT (word) DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS films(
+id SERIAL PRIMARY KEY,
+name VARCHAR(255) NOT NULL,
+post_id INT NOT NULL,
+CONSTRAINT fk_post_id
+	FOREIGN KEY(post_id)
+		REFERENCES pictures(id)
+			ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS films_name_idx ON films(name);
+CREATE INDEX IF NOT EXISTS films_post_id_idx ON films(post_id);
+
+CREATE TABLE IF NOT EXISTS cameras(
+id SERIAL PRIMARY KEY,
+name VARCHAR(255) NOT NULL,
+post_id INT NOT NULL,
+CONSTRAINT fk_post_id
+	FOREIGN KEY(post_id)
+		REFERENCES pictures(id)
+			ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS cameras_name_idx ON cameras(name);
+CREATE INDEX IF NOT EXISTS cameras_post_id_idx ON cameras(post_id);
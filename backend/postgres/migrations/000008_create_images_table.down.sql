@@ -0,0 +1,47 @@
+BEGIN;
+
+ALTER TABLE pictures
+ADD COLUMN url text,
+ADD COLUMN lowurl text,
+ADD COLUMN lowwidth integer,
+ADD COLUMN lowheight integer,
+ADD COLUMN medurl text,
+ADD COLUMN medwidth integer,
+ADD COLUMN medheight integer,
+ADD COLUMN highurl text,
+ADD COLUMN highwidth integer,
+ADD COLUMN highheight integer,
+ADD COLUMN c1_hex VARCHAR (12),
+ADD COLUMN c1_css VARCHAR (50),
+ADD COLUMN c1_percent NUMERIC (9, 8),
+ADD COLUMN c2_hex VARCHAR (12),
+ADD COLUMN c2_css VARCHAR (50),
+ADD COLUMN c2_percent NUMERIC (9, 8),
+ADD COLUMN c3_hex VARCHAR (12),
+ADD COLUMN c3_css VARCHAR (50),
+ADD COLUMN c3_percent NUMERIC (9, 8),
+ADD COLUMN c4_hex VARCHAR (12),
+ADD COLUMN c4_css VARCHAR (50),
+ADD COLUMN c4_percent NUMERIC (9, 8),
+ADD COLUMN c5_hex VARCHAR (12),
+ADD COLUMN c5_css VARCHAR (50),
+ADD COLUMN c5_percent NUMERIC (9, 8);
+
+UPDATE pictures p SET lowurl = i.url, lowwidth = i.width, lowheight = i.height
+FROM images i WHERE i.post_id = p.id AND i.label = 'low';
+
+UPDATE pictures p SET medurl = i.url, medwidth = i.width, medheight = i.height
+FROM images i WHERE i.post_id = p.id AND i.label = 'medium';
+
+UPDATE pictures p SET highurl = i.url, highwidth = i.width, highheight = i.height
+FROM images i WHERE i.post_id = p.id AND i.label = 'high';
+
+UPDATE pictures p SET url = i.url
+FROM images i WHERE i.post_id = p.id AND i.label = 'raw';
+
+ALTER TABLE pictures ALTER COLUMN url SET NOT NULL;
+ALTER TABLE pictures ADD CONSTRAINT pictures_url_key UNIQUE (url);
+
+DROP TABLE IF EXISTS images;
+
+COMMIT;
@@ -0,0 +1,66 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS images(
+id SERIAL PRIMARY KEY,
+post_id INT NOT NULL,
+label VARCHAR(32) NOT NULL,
+url TEXT NOT NULL,
+width INT NOT NULL,
+height INT NOT NULL,
+format VARCHAR(16),
+bytes BIGINT,
+CONSTRAINT fk_post_id
+	FOREIGN KEY(post_id)
+		REFERENCES pictures(id)
+			ON DELETE CASCADE,
+CONSTRAINT unique_post_label
+	UNIQUE(post_id, label)
+);
+
+-- format is taken from the extension of the url, size is unknown
+INSERT INTO images (post_id, label, url, width, height, format)
+SELECT id, 'low', lowurl, lowwidth, lowheight, LOWER(SUBSTRING(lowurl FROM '\.([A-Za-z0-9]+)(?:\?.*)?$'))
+FROM pictures WHERE lowurl IS NOT NULL
+UNION ALL
+SELECT id, 'medium', medurl, medwidth, medheight, LOWER(SUBSTRING(medurl FROM '\.([A-Za-z0-9]+)(?:\?.*)?$'))
+FROM pictures WHERE medurl IS NOT NULL
+UNION ALL
+SELECT id, 'high', highurl, highwidth, highheight, LOWER(SUBSTRING(highurl FROM '\.([A-Za-z0-9]+)(?:\?.*)?$'))
+FROM pictures WHERE highurl IS NOT NULL
+UNION ALL
+SELECT id, 'raw', url, width, height, LOWER(SUBSTRING(url FROM '\.([A-Za-z0-9]+)(?:\?.*)?$'))
+FROM pictures WHERE url IS NOT NULL
+ON CONFLICT (post_id, label) DO NOTHING;
+
+-- width and height stay on pictures as the dimensions of the original photo
+ALTER TABLE pictures
+DROP COLUMN url,
+DROP COLUMN lowurl,
+DROP COLUMN lowwidth,
+DROP COLUMN lowheight,
+DROP COLUMN medurl,
+DROP COLUMN medwidth,
+DROP COLUMN medheight,
+DROP COLUMN highurl,
+DROP COLUMN highwidth,
+DROP COLUMN highheight,
+DROP COLUMN IF EXISTS c1_hex,
+DROP COLUMN IF EXISTS c1_css,
+DROP COLUMN IF EXISTS c1_percent,
+DROP COLUMN IF EXISTS c2_hex,
+DROP COLUMN IF EXISTS c2_css,
+DROP COLUMN IF EXISTS c2_percent,
+DROP COLUMN IF EXISTS c3_hex,
+DROP COLUMN IF EXISTS c3_css,
+DROP COLUMN IF EXISTS c3_percent,
+DROP COLUMN IF EXISTS c4_hex,
+DROP COLUMN IF EXISTS c4_css,
+DROP COLUMN IF EXISTS c4_percent,
+DROP COLUMN IF EXISTS c5_hex,
+DROP COLUMN IF EXISTS c5_css,
+DROP COLUMN IF EXISTS c5_percent;
+
+-- each original image belongs to a single post, as pictures.url was unique
+CREATE UNIQUE INDEX IF NOT EXISTS images_raw_url_key ON images (url) WHERE label = 'raw';
+
+COMMIT;
@@ -0,0 +1 @@
+ALTER TABLE pictures ADD COLUMN IF NOT EXISTS image_hash BIGINT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS post_metadata(
+post_id INT PRIMARY KEY,
+camera_make TEXT,
+camera_model TEXT,
+lens TEXT,
+focal_length REAL,
+exposure_time TEXT,
+f_number REAL,
+iso INT,
+software TEXT,
+color_profile TEXT,
+file_size BIGINT,
+has_exif BOOLEAN NOT NULL DEFAULT false,
+CONSTRAINT fk_post_id
+	FOREIGN KEY(post_id)
+		REFERENCES pictures(id)
+			ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS post_metadata_camera_make_idx ON post_metadata (LOWER(camera_make));
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS encodings(
+post_id INT PRIMARY KEY,
+status VARCHAR(16) NOT NULL DEFAULT 'pending',
+error TEXT,
+schema_version INT,
+encoded_at TIMESTAMPTZ,
+CONSTRAINT fk_post_id
+	FOREIGN KEY(post_id)
+		REFERENCES pictures(id)
+			ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS encodings_status_idx ON encodings (status);
+
+-- existing posts were encoded as they were created, at schema version 2,
+-- so they aren't all encoded again. seeded rows have no encoded_at, and
+-- posts missing from the vector DB are found by analogdb reconcile
+INSERT INTO encodings (post_id, status, schema_version)
+SELECT id, 'encoded', 2 FROM pictures
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+-- pairs of posts with near identical images, the lower id first.
+-- new posts are linked when created, existing posts by analogdb duplicates link
+CREATE TABLE IF NOT EXISTS duplicates(
+post_id INT NOT NULL,
+duplicate_id INT NOT NULL,
+distance INT NOT NULL,
+PRIMARY KEY (post_id, duplicate_id),
+CONSTRAINT ordered_pair
+	CHECK (post_id < duplicate_id),
+CONSTRAINT fk_post_id
+	FOREIGN KEY(post_id)
+		REFERENCES pictures(id)
+			ON DELETE CASCADE,
+CONSTRAINT fk_duplicate_id
+	FOREIGN KEY(duplicate_id)
+		REFERENCES pictures(id)
+			ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS duplicates_duplicate_id_idx ON duplicates (duplicate_id);
+
+COMMIT;
@@ -3,8 +3,10 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
 	"strconv"
 	"strings"
 	goTime "time"
@@ -17,32 +19,25 @@ var _ analogdb.PostService = (*PostService)(nil)
 
 // rawPostCreate corresponds to the columns as a post is inserted in DB
 type rawCreatePost struct {
-	url        string
-	title      string
-	author     string
-	permalink  string
-	score      int
-	nsfw       bool
-	grayscale  bool
-	time       int
-	width      int
-	height     int
-	sprocket   bool
-	lowUrl     string
-	lowWidth   int
-	lowHeight  int
-	medUrl     string
-	medWidth   int
-	medHeight  int
-	highUrl    string
-	highWidth  int
-	highHeight int
-	hexes      NullString
-	csses      NullString
-	htmls      NullString
-	percents   NullString
-	words      NullString
-	weights    NullString
+	title     string
+	author    string
+	permalink string
+	score     int
+	nsfw      bool
+	grayscale bool
+	time      int
+	width     int
+	height    int
+	sprocket  bool
+	hash      sql.NullInt64
+	images    NullString
+	hexes     NullString
+	csses     NullString
+	htmls     NullString
+	percents  NullString
+	words     NullString
+	weights   NullString
+	metadata  NullString
 }
 
 // rawPost corresponds to the columns as a post is selected from the DB
@@ -155,8 +150,8 @@ func (db *DB) insertPost(ctx context.Context, tx *sql.Tx, post *analogdb.CreateP
 	query :=
 		`
 	INSERT INTO pictures
-	(url, title, author, permalink, score, nsfw, greyscale, time, width, height, sprocket, lowUrl, lowWidth, lowHeight, medUrl, medWidth, medHeight, highUrl, highWidth, highHeight)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+	(title, author, permalink, score, nsfw, greyscale, time, width, height, sprocket, image_hash)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	ON CONFLICT (permalink) DO NOTHING
 	RETURNING id
 	`
@@ -172,7 +167,6 @@ func (db *DB) insertPost(ctx context.Context, tx *sql.Tx, post *analogdb.CreateP
 
 	err = stmt.QueryRowContext(
 		ctx,
-		create.url,
 		create.title,
 		create.author,
 		create.permalink,
@@ -183,15 +177,7 @@ func (db *DB) insertPost(ctx context.Context, tx *sql.Tx, post *analogdb.CreateP
 		create.width,
 		create.height,
 		create.sprocket,
-		create.lowUrl,
-		create.lowWidth,
-		create.lowHeight,
-		create.medUrl,
-		create.medWidth,
-		create.medHeight,
-		create.highUrl,
-		create.highWidth,
-		create.highHeight).Scan(&id)
+		create.hash).Scan(&id)
 
 	if err != nil {
 		db.logger.Error().Err(err).Ctx(ctx).Int64("postID", id).Msg("Failed to insert post")
@@ -203,11 +189,20 @@ func (db *DB) insertPost(ctx context.Context, tx *sql.Tx, post *analogdb.CreateP
 	return &id, nil
 }
 
-// insertKeywords inserts a post's keywords into the DB
-func (db *DB) insertKeywords(ctx context.Context, tx *sql.Tx, keywords []analogdb.Keyword, postID int64) error {
+// insertKeywords normalizes a post's keywords and inserts them into the DB,
+// returning the keywords that were inserted
+func (db *DB) insertKeywords(ctx context.Context, tx *sql.Tx, keywords []analogdb.Keyword, postID int64) ([]analogdb.Keyword, error) {
 
 	db.logger.Debug().Ctx(ctx).Int64("postID", postID).Msg("Starting insert keywords")
 
+	keywords = db.keywordRules(ctx).NormalizeKeywords(keywords)
+
+	// every keyword may have been blacklisted
+	if len(keywords) == 0 {
+		db.logger.Info().Ctx(ctx).Int64("postID", postID).Msg("Finished inserting keywords (all keywords dropped)")
+		return keywords, nil
+	}
+
 	first := 1
 	second := 2
 	third := 3
@@ -234,7 +229,7 @@ func (db *DB) insertKeywords(ctx context.Context, tx *sql.Tx, keywords []analogd
 
 	if err != nil {
 		db.logger.Error().Err(err).Ctx(ctx).Int64("postID", postID).Msg("Failed to insert keywords")
-		return err
+		return nil, err
 	}
 
 	defer stmt.Close()
@@ -243,12 +238,12 @@ func (db *DB) insertKeywords(ctx context.Context, tx *sql.Tx, keywords []analogd
 
 	if err != nil {
 		db.logger.Error().Err(err).Ctx(ctx).Int64("postID", postID).Msg("Failed to insert keywords")
-		return err
+		return nil, err
 	}
 
 	db.logger.Info().Ctx(ctx).Int64("postID", postID).Msg("Finished inserting keywords")
 
-	return nil
+	return keywords, nil
 }
 
 // deleteKeywords deletes all keywords for a given post
@@ -322,6 +317,47 @@ func (db *DB) insertColors(ctx context.Context, tx *sql.Tx, colors []analogdb.Co
 	return nil
 }
 
+// insertImages inserts every rendition of a post's image into the DB
+func (db *DB) insertImages(ctx context.Context, tx *sql.Tx, images []analogdb.Image, postID int64) error {
+
+	db.logger.Debug().Ctx(ctx).Int64("postID", postID).Msg("Starting insert images")
+
+	vals := []any{}
+	inserts := []string{}
+
+	query :=
+		`
+	INSERT INTO images
+	(post_id, label, url, width, height, format, bytes)
+	VALUES `
+
+	index := 1
+	for _, image := range images {
+		inserts = append(inserts, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)", index, index+1, index+2, index+3, index+4, index+5, index+6))
+		format := image.Format
+		if format == "" {
+			format = imageFormat(image.Url)
+		}
+		var bytes *int64
+		if image.Bytes > 0 {
+			bytes = &image.Bytes
+		}
+		vals = append(vals, postID, image.Label, image.Url, image.Width, image.Height, sql.NullString{String: format, Valid: format != ""}, bytes)
+		index += 7
+	}
+
+	query += strings.Join(inserts, ",")
+
+	if _, err := tx.ExecContext(ctx, query, vals...); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Int64("postID", postID).Msg("Failed to insert images")
+		return err
+	}
+
+	db.logger.Info().Ctx(ctx).Int64("postID", postID).Msg("Finished inserting images")
+
+	return nil
+}
+
 // deleteKeywords deletes all keywords for a given post
 func (db *DB) deleteColors(ctx context.Context, tx *sql.Tx, postID int64) error {
 
@@ -341,18 +377,22 @@ func (db *DB) deleteColors(ctx context.Context, tx *sql.Tx, postID int64) error
 	return nil
 }
 
-func (db *DB) createPost(ctx context.Context, tx *sql.Tx, post *analogdb.CreatePost) (*analogdb.Post, error) {
-
-	db.logger.Debug().Ctx(ctx).Msg("Starting create post")
+// insertPostAndRelations inserts a post along with its images, keywords,
+// colors and gear, without committing the transaction
+func (db *DB) insertPostAndRelations(ctx context.Context, tx *sql.Tx, post *analogdb.CreatePost) (*int64, error) {
 
 	id, err := db.insertPost(ctx, tx, post)
 	if err != nil {
 		return nil, err
 	}
 
+	if err = db.insertImages(ctx, tx, post.Images, *id); err != nil {
+		return nil, err
+	}
+
 	// insert keywords if they are provided
 	if len(post.Keywords) != 0 {
-		err = db.insertKeywords(ctx, tx, post.Keywords, *id)
+		post.Keywords, err = db.insertKeywords(ctx, tx, post.Keywords, *id)
 		if err != nil {
 			return nil, err
 		}
@@ -366,6 +406,47 @@ func (db *DB) createPost(ctx context.Context, tx *sql.Tx, post *analogdb.CreateP
 		}
 	}
 
+	// insert any film stocks and cameras named in the title
+	if _, _, err = db.insertGear(ctx, tx, post.Title, *id); err != nil {
+		return nil, err
+	}
+
+	// insert metadata if it was read from the image
+	if post.Metadata != nil {
+		if err = db.upsertMetadata(ctx, tx, post.Metadata, *id); err != nil {
+			return nil, err
+		}
+	}
+
+	// the post is encoded into the vector DB once created
+	if _, err = tx.ExecContext(ctx, `INSERT INTO encodings (post_id, status) VALUES ($1, $2)`, *id, analogdb.EncodingPending); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Int64("postID", *id).Msg("Failed to insert encoding")
+		return nil, err
+	}
+
+	return id, nil
+}
+
+func (db *DB) createPost(ctx context.Context, tx *sql.Tx, post *analogdb.CreatePost) (*analogdb.Post, error) {
+
+	db.logger.Debug().Ctx(ctx).Msg("Starting create post")
+
+	matches, err := db.checkDuplicate(ctx, tx, post)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := db.insertPostAndRelations(ctx, tx, post)
+	if err != nil {
+		return nil, err
+	}
+
+	// flag the post for moderation along with the posts it duplicates
+	if err := db.insertDuplicates(ctx, tx, int(*id), matches); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Int64("postID", *id).Msg("Failed to insert duplicates")
+		return nil, err
+	}
+
 	// commit transaction if all inserts are ok
 	err = tx.Commit()
 	if err != nil {
@@ -380,12 +461,13 @@ func (db *DB) createPost(ctx context.Context, tx *sql.Tx, post *analogdb.CreateP
 		Permalink: post.Permalink,
 		Score:     post.Score,
 		Nsfw:      post.Nsfw,
-		Grayscale: post.Grayscale,
+		Grayscale: boolValue(post.Grayscale),
 		Time:      post.Time,
-		Sprocket:  post.Sprocket,
+		Sprocket:  boolValue(post.Sprocket),
 		Images:    post.Images,
 		Colors:    post.Colors,
 		Keywords:  post.Keywords,
+		Metadata:  post.Metadata,
 	}
 
 	createdPost := &analogdb.Post{
@@ -408,12 +490,63 @@ func (db *DB) findPosts(ctx context.Context, tx *sql.Tx, filter *analogdb.PostFi
 	db.logger.Debug().Ctx(ctx).Str("filter", filterFmt).Msg("Starting find posts")
 	defer db.logger.Debug().Ctx(ctx).Str("filter", filterFmt).Msg("Finished find posts")
 
+	query, args, index := db.selectPostsQuery(ctx, filter, true)
+
+	order, orderArgs, _ := filterToOrder(filter, index)
+	args = append(args, orderArgs...)
+	query += order + formatLimit(filter)
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find posts")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	posts := make([]*analogdb.Post, 0)
+	var count int
+	var p *rawPost
+	for rows.Next() {
+		p, count, err = scanRowToRawPostCount(rows)
+		if err != nil {
+			db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find posts")
+			return nil, 0, err
+		}
+		post, err := rawPostToPost(*p)
+
+		// strip `u/` prefix from author (modifies in place)
+		stripAuthorPrefix(post)
+
+		if err != nil {
+			db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find posts")
+			return nil, 0, err
+		}
+		posts = append(posts, post)
+
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find posts")
+		return nil, 0, err
+	}
+
+	db.logger.Info().Ctx(ctx).Msg("Finished finding posts")
+
+	return posts, count, nil
+}
+
+// selectPostsQuery builds the query selecting every post matching a filter,
+// without ordering or a limit. Returns the query, its args, and the next arg index.
+func (db *DB) selectPostsQuery(ctx context.Context, filter *analogdb.PostFilter, withCount bool) (string, []any, int) {
+
 	var colorArgs, keywordArgs, postArgs []any
 	index := 1
 	var colorWhere, keywordWhere, postWhere string
 
 	colorWhere, colorArgs, index = filterToWhereColor(filter, index)
-	keywordWhere, keywordArgs, index = filterToWhereKeyword(filter, index)
+	keywordWhere, keywordArgs, index = filterToWhereKeyword(filter, db.keywordRules(ctx), index)
 	postWhere, postArgs, index = filterToWherePost(filter, index)
 
 	keywordJoin := "LEFT OUTER"
@@ -429,12 +562,14 @@ func (db *DB) findPosts(ctx context.Context, tx *sql.Tx, filter *analogdb.PostFi
 	args := append(colorArgs, keywordArgs...)
 	args = append(args, postArgs...)
 
-	order := filterToOrder(filter)
-	limit := formatLimit(filter)
+	count := ""
+	if withCount {
+		count = ", COUNT(*) OVER()"
+	}
+
 	query := fmt.Sprintf(`
 			SELECT
 				p.id,
-				p.url,
 				p.title,
 				p.author,
 				p.permalink,
@@ -445,24 +580,30 @@ func (db *DB) findPosts(ctx context.Context, tx *sql.Tx, filter *analogdb.PostFi
 				p.width,
 				p.height,
 				p.sprocket,
-				p.lowUrl,
-				p.lowWidth,
-				p.lowHeight,
-				p.medUrl,
-				p.medWidth,
-				p.medHeight,
-				p.highUrl,
-				p.highWidth,
-				p.highHeight,
+				i.images,
 				c.hexes,
 				c.csses,
 				c.htmls,
 				c.percents,
 				k.words,
 				k.weights,
-				COUNT(*) OVER()
+				to_jsonb(m) - 'post_id' as metadata%s
 			FROM
 				pictures p
+				LEFT OUTER JOIN (
+					SELECT
+						post_id,
+						JSON_AGG(JSON_BUILD_OBJECT(
+							'resolution', label,
+							'url', url,
+							'width', width,
+							'height', height,
+							'format', format,
+							'bytes', bytes
+						) ORDER BY %s, width) as images
+					FROM images
+					GROUP BY post_id
+				) i on i.post_id = p.id
 				%s JOIN (
 					SELECT
 						post_id,
@@ -483,53 +624,36 @@ func (db *DB) findPosts(ctx context.Context, tx *sql.Tx, filter *analogdb.PostFi
 					WHERE %s
 					GROUP BY post_id
 				) k on k.post_id = p.id
+				LEFT OUTER JOIN post_metadata m on m.post_id = p.id
 			WHERE %s
-	`, colorJoin, colorWhere, keywordJoin, keywordWhere, postWhere) + order + limit
-
-	rows, err := tx.QueryContext(ctx, query, args...)
+	`, count, imageRankExpr, colorJoin, colorWhere, keywordJoin, keywordWhere, postWhere)
 
-	if err != nil {
-		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find posts")
-		return nil, 0, err
-	}
-	defer rows.Close()
-
-	posts := make([]*analogdb.Post, 0)
-	var count int
-	var p *rawPost
-	for rows.Next() {
-		p, count, err = scanRowToRawPostCount(rows)
-		if err != nil {
-			db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find posts")
-			return nil, 0, err
-		}
-		post, err := rawPostToPost(*p)
+	return query, args, index
+}
 
-		// strip `u/` prefix from author (modifies in place)
-		stripAuthorPrefix(post)
+func (db *DB) patchPost(ctx context.Context, tx *sql.Tx, patch *analogdb.PatchPost, id int) error {
 
-		if err != nil {
-			db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find posts")
-			return nil, 0, err
-		}
-		posts = append(posts, post)
+	db.logger.Debug().Ctx(ctx).Int("postID", id).Msg("Starting patch post")
 
+	if err := db.applyPatch(ctx, tx, patch, id); err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to patch post")
+		return err
 	}
 
-	err = tx.Commit()
+	err := tx.Commit()
 	if err != nil {
-		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to find posts")
-		return nil, 0, err
+		db.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to patch post")
+		return err
 	}
 
-	db.logger.Info().Ctx(ctx).Msg("Finished finding posts")
+	db.logger.Info().Ctx(ctx).Int("postID", id).Msg("Finished patching post")
 
-	return posts, count, nil
+	return nil
 }
 
-func (db *DB) patchPost(ctx context.Context, tx *sql.Tx, patch *analogdb.PatchPost, id int) error {
-
-	db.logger.Debug().Ctx(ctx).Int("postID", id).Msg("Starting patch post")
+// applyPatch updates a post with each field of a patch,
+// without committing the transaction
+func (db *DB) applyPatch(ctx context.Context, tx *sql.Tx, patch *analogdb.PatchPost, id int) error {
 
 	hasPatchFields := false
 
@@ -537,7 +661,6 @@ func (db *DB) patchPost(ctx context.Context, tx *sql.Tx, patch *analogdb.PatchPo
 	if patch.Nsfw != nil || patch.Sprocket != nil || patch.Grayscale != nil || patch.Score != nil {
 		hasPatchFields = true
 		if err := db.updatePostGeneral(ctx, tx, patch, id); err != nil {
-			db.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to patch post")
 			return err
 		}
 	}
@@ -546,7 +669,6 @@ func (db *DB) patchPost(ctx context.Context, tx *sql.Tx, patch *analogdb.PatchPo
 	if patch.Keywords != nil {
 		hasPatchFields = true
 		if err := db.updateKeywords(ctx, tx, *patch.Keywords, id); err != nil {
-			db.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to patch post")
 			return err
 		}
 	}
@@ -555,32 +677,16 @@ func (db *DB) patchPost(ctx context.Context, tx *sql.Tx, patch *analogdb.PatchPo
 	if patch.Colors != nil {
 		hasPatchFields = true
 		if err := db.updateColors(ctx, tx, *patch.Colors, id); err != nil {
-			db.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to patch post")
 			return err
 		}
 	}
 
 	if !hasPatchFields {
-		err := errors.New("must include patch parameters")
-		db.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to patch post")
-		return err
+		return errors.New("must include patch parameters")
 	}
 
 	// always insert the updated timestamp
-	if err := db.insertPostUpdateTimes(ctx, tx, patch, id); err != nil {
-		db.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to patch post")
-		return err
-	}
-
-	err := tx.Commit()
-	if err != nil {
-		db.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to patch post")
-		return err
-	}
-
-	db.logger.Info().Ctx(ctx).Int("postID", id).Msg("Finished patching post")
-
-	return nil
+	return db.insertPostUpdateTimes(ctx, tx, patch, id)
 }
 
 func (db *DB) updateKeywords(ctx context.Context, tx *sql.Tx, keywords []analogdb.Keyword, id int) error {
@@ -600,7 +706,7 @@ func (db *DB) updateKeywords(ctx context.Context, tx *sql.Tx, keywords []analogd
 	}
 
 	// then insert all new keywords
-	if err := db.insertKeywords(ctx, tx, keywords, int64(id)); err != nil {
+	if _, err := db.insertKeywords(ctx, tx, keywords, int64(id)); err != nil {
 		db.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to update keywords")
 		return err
 	}
@@ -788,22 +894,88 @@ func (db *DB) allPostIDs(ctx context.Context, tx *sql.Tx) ([]int, error) {
 	return ids, nil
 }
 
+// trendingExpr ranks a post by its score decayed by age in hours,
+// so newer posts with fewer votes can outrank older popular ones.
+func trendingExpr(alias string) string {
+	return fmt.Sprintf("(%[1]s.score / POWER((EXTRACT(EPOCH FROM NOW()) - %[1]s.time) / 3600 + 2, 1.8))", alias)
+}
+
+// resolutionExpr is the number of pixels in the high resolution image
+func resolutionExpr(alias string) string {
+	return fmt.Sprintf("(SELECT width * height FROM images WHERE post_id = %s.id AND label = '%s')", alias, analogdb.ImageHigh)
+}
+
+// imageRankExpr orders the images of a post with the renditions every post
+// has first, smallest to largest, followed by any others
+var imageRankExpr = fmt.Sprintf("CASE label WHEN '%s' THEN 0 WHEN '%s' THEN 1 WHEN '%s' THEN 2 WHEN '%s' THEN 3 ELSE 4 END",
+	analogdb.ImageLow, analogdb.ImageMedium, analogdb.ImageHigh, analogdb.ImageRaw)
+
+// imageFormat is the format of an image from the extension of its url
+func imageFormat(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	ext := path.Ext(url)
+	if ext == "" || strings.Contains(ext, "/") {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// colorExpr is the summed percent of a single html color in a post,
+// where the color is passed as a query parameter at colorIndex
+func colorExpr(alias string, colorIndex int) string {
+	return fmt.Sprintf("(SELECT COALESCE(SUM(percent), 0) FROM colors WHERE post_id = %s.id AND html = $%d)", alias, colorIndex)
+}
+
+// sortByColor returns the color to order posts by, which is the first
+// filtered color. Sorting by color is only possible if a color is filtered.
+func sortByColor(filter *analogdb.PostFilter) (string, bool) {
+	if sort := filter.Sort; sort == nil || *sort != analogdb.SortColor {
+		return "", false
+	}
+	if colors := filter.Colors; colors != nil && len(*colors) > 0 {
+		return (*colors)[0], true
+	}
+	return "", false
+}
+
 // filterToOrder converts filter into an SQL "ORDER BY" statement
-func filterToOrder(filter *analogdb.PostFilter) string {
+func filterToOrder(filter *analogdb.PostFilter, startIndex int) (string, []any, int) {
+
+	index := startIndex
+	args := []any{}
+
 	if sort := filter.Sort; sort != nil {
 		switch *sort {
 		case analogdb.SortTime:
-			return " ORDER BY p.time DESC"
+			return " ORDER BY p.time DESC", args, index
+		case analogdb.SortOldest:
+			return " ORDER BY p.time ASC", args, index
 		case analogdb.SortScore:
-			return " ORDER BY p.score DESC"
+			return " ORDER BY p.score DESC", args, index
 		case analogdb.SortRandom:
 			if filter.Seed == nil {
 				filter.SetSeed()
 			}
-			return fmt.Sprintf(" ORDER BY MOD(p.time, %d), p.time DESC", *filter.Seed)
+			return fmt.Sprintf(" ORDER BY MOD(p.time, %d), p.time DESC", *filter.Seed), args, index
+		case analogdb.SortTrending:
+			return fmt.Sprintf(" ORDER BY %s DESC, p.id DESC", trendingExpr("p")), args, index
+		case analogdb.SortResolution:
+			return fmt.Sprintf(" ORDER BY %s DESC, p.id DESC", resolutionExpr("p")), args, index
+		case analogdb.SortColor:
+			color, ok := sortByColor(filter)
+			if !ok {
+				// nothing to sort by, default to latest
+				return " ORDER BY p.time DESC", args, index
+			}
+			args = append(args, color)
+			order := fmt.Sprintf(" ORDER BY %s DESC, p.id DESC", colorExpr("p", index))
+			index += 1
+			return order, args, index
 		}
 	}
-	return ""
+	return "", args, index
 }
 
 // formatLimit turns the limit into an SQL limit statement
@@ -872,7 +1044,7 @@ func filterToWhereColor(filter *analogdb.PostFilter, startIndex int) (string, []
 	return whereQuery, args, index
 }
 
-func filterToWhereKeyword(filter *analogdb.PostFilter, startIndex int) (string, []any, int) {
+func filterToWhereKeyword(filter *analogdb.PostFilter, rules *analogdb.KeywordRules, startIndex int) (string, []any, int) {
 
 	index := startIndex
 	base := "1=1"
@@ -900,9 +1072,12 @@ func filterToWhereKeyword(filter *analogdb.PostFilter, startIndex int) (string,
 	inner := ""
 	// must do one intersection for each keyword.
 	for _, keyword := range *filter.Keywords {
+		// query with the same form keywords are stored in. blacklisted
+		// words are never stored, so they are left to match nothing.
+		word, _ := rules.NormalizeWord(keyword)
 		inner += fmt.Sprintf("SELECT post_id from keywords WHERE word = $%d INTERSECT ", index)
 		index += 1
-		args = append(args, keyword)
+		args = append(args, word)
 	}
 
 	// strip off the trailing intersect
@@ -927,6 +1102,10 @@ func filterToWherePost(filter *analogdb.PostFilter, startIndex int) (string, []a
 			where = append(where, fmt.Sprintf("p.time < $%d", index))
 			args = append(args, *keyset)
 			index += 1
+		case analogdb.SortOldest:
+			where = append(where, fmt.Sprintf("p.time > $%d", index))
+			args = append(args, *keyset)
+			index += 1
 		case analogdb.SortScore:
 			where = append(where, fmt.Sprintf("p.score < $%d", index))
 			args = append(args, *keyset)
@@ -937,6 +1116,37 @@ func filterToWherePost(filter *analogdb.PostFilter, startIndex int) (string, []a
 				args = append(args, *seed, *keyset%*seed)
 				index += 2
 			}
+
+		// the following sort keys are not unique, so the keyset is the ID
+		// of the last post seen. compare against that post's sort key,
+		// breaking ties with the ID.
+		//
+		// i.e.
+		//
+		// WHERE (p.width * p.height, p.id) < (
+		// 	SELECT k.width * k.height, k.id
+		// 	FROM pictures k
+		// 	WHERE k.id = $1
+		// )
+
+		case analogdb.SortTrending:
+			where = append(where, fmt.Sprintf("(%s, p.id) < (SELECT %s, k.id FROM pictures k WHERE k.id = $%d)", trendingExpr("p"), trendingExpr("k"), index))
+			args = append(args, *keyset)
+			index += 1
+		case analogdb.SortResolution:
+			where = append(where, fmt.Sprintf("(%s, p.id) < (SELECT %s, k.id FROM pictures k WHERE k.id = $%d)", resolutionExpr("p"), resolutionExpr("k"), index))
+			args = append(args, *keyset)
+			index += 1
+		case analogdb.SortColor:
+			if color, ok := sortByColor(filter); ok {
+				where = append(where, fmt.Sprintf("(%s, p.id) < (SELECT %s, k.id FROM pictures k WHERE k.id = $%d)", colorExpr("p", index), colorExpr("k", index), index+1))
+				args = append(args, color, *keyset)
+				index += 2
+			} else {
+				where = append(where, fmt.Sprintf("p.time < $%d", index))
+				args = append(args, *keyset)
+				index += 1
+			}
 		}
 	}
 
@@ -986,48 +1196,82 @@ func filterToWherePost(filter *analogdb.PostFilter, startIndex int) (string, []a
 
 	// if query does not prefix author with 'u/' we need to add it
 	if author := filter.Author; author != nil {
-		var matchAuthor string
-		if pre := (*author)[0:2]; pre != "u/" {
-			matchAuthor = "u/" + *author
-		} else {
-			matchAuthor = *author
-		}
 		where = append(where, fmt.Sprintf("p.author = $%d", index))
-		args = append(args, matchAuthor)
+		args = append(args, addAuthorPrefix(*author))
+		index += 1
+	}
+
+	// films and cameras are matched by their catalog name,
+	// so resolve aliases like 'portra400' before querying.
+	// names match case insensitively, but never as a pattern.
+	if film := filter.Film; film != nil {
+		where = append(where, fmt.Sprintf("p.id IN (SELECT post_id FROM films WHERE name ILIKE $%d)", index))
+		args = append(args, escapeLike(analogdb.LookupFilm(*film)))
+		index += 1
+	}
+
+	if camera := filter.Camera; camera != nil {
+		where = append(where, fmt.Sprintf("p.id IN (SELECT post_id FROM cameras WHERE name ILIKE $%d)", index))
+		args = append(args, escapeLike(analogdb.LookupCamera(*camera)))
+		index += 1
+	}
+
+	if cameraMake := filter.CameraMake; cameraMake != nil {
+		where = append(where, fmt.Sprintf("p.id IN (SELECT post_id FROM post_metadata WHERE camera_make ILIKE $%d)", index))
+		args = append(args, escapeLike(*cameraMake))
+		index += 1
+	}
+
+	if encoded := filter.Encoded; encoded != nil {
+		in := "IN"
+		if !*encoded {
+			in = "NOT IN"
+		}
+		where = append(where, fmt.Sprintf("p.id %s (SELECT post_id FROM encodings WHERE status = $%d)", in, index))
+		args = append(args, analogdb.EncodingEncoded)
+		index += 1
+	}
+
+	if hasExif := filter.HasExif; hasExif != nil {
+		where = append(where, fmt.Sprintf("COALESCE((SELECT has_exif FROM post_metadata WHERE post_id = p.id), false) = $%d", index))
+		args = append(args, *hasExif)
 		index += 1
 	}
 
-	if minWidth := filter.Width.Min; minWidth != nil {
+	// a filter not built with NewPostFilter has no dimensions
+	width, height, aspectRatio := dimensionOrAny(filter.Width), dimensionOrAny(filter.Height), dimensionOrAny(filter.AspectRatio)
+
+	if minWidth := width.Min; minWidth != nil {
 		where = append(where, fmt.Sprintf("p.width >= $%d", index))
 		args = append(args, *minWidth)
 		index += 1
 	}
 
-	if maxWidth := filter.Width.Max; maxWidth != nil {
+	if maxWidth := width.Max; maxWidth != nil {
 		where = append(where, fmt.Sprintf("p.width <= $%d", index))
 		args = append(args, *maxWidth)
 		index += 1
 	}
 
-	if minHeight := filter.Height.Min; minHeight != nil {
+	if minHeight := height.Min; minHeight != nil {
 		where = append(where, fmt.Sprintf("p.height >= $%d", index))
 		args = append(args, *minHeight)
 		index += 1
 	}
 
-	if maxHeight := filter.Height.Max; maxHeight != nil {
+	if maxHeight := height.Max; maxHeight != nil {
 		where = append(where, fmt.Sprintf("p.height <= $%d", index))
 		args = append(args, *maxHeight)
 		index += 1
 	}
 
-	if minRatio := filter.AspectRatio.Min; minRatio != nil {
+	if minRatio := aspectRatio.Min; minRatio != nil {
 		where = append(where, fmt.Sprintf("p.width::decimal / p.height::decimal >= $%d::decimal", index))
 		args = append(args, *minRatio)
 		index += 1
 	}
 
-	if maxRatio := filter.AspectRatio.Max; maxRatio != nil {
+	if maxRatio := aspectRatio.Max; maxRatio != nil {
 		where = append(where, fmt.Sprintf("p.width::decimal / p.height::decimal <= $%d::decimal", index))
 		args = append(args, *maxRatio)
 		index += 1
@@ -1038,6 +1282,14 @@ func filterToWherePost(filter *analogdb.PostFilter, startIndex int) (string, []a
 	return whereQuery, args, index
 }
 
+// dimensionOrAny is d, or a dimension matching any size if d is nil
+func dimensionOrAny(d *analogdb.Dimension) analogdb.Dimension {
+	if d == nil {
+		return analogdb.Dimension{}
+	}
+	return *d
+}
+
 // Converts a patch to an SQL set statement
 func patchToSet(patch *analogdb.PatchPost) (string, []any, error) {
 
@@ -1075,54 +1327,32 @@ func patchToSet(patch *analogdb.PatchPost) (string, []any, error) {
 }
 
 func createPostToRawPostCreate(p *analogdb.CreatePost) (*rawCreatePost, error) {
-	if len(p.Images) != 4 {
-		return nil, &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "Unable to create post, expected 4 images (low, medium, high, raw)"}
+	raw, ok := analogdb.FindImage(p.Images, analogdb.ImageRaw)
+	if !ok {
+		return nil, &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "Unable to create post, expected a raw image"}
 	}
-	low := p.Images[0]
-	med := p.Images[1]
-	high := p.Images[2]
-	raw := p.Images[3]
 
 	if len(p.Colors) != 5 {
 		return nil, &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "Unable to create post, expected 5 colors"}
 	}
 
 	// we don't actually use these when creating the post here
-	// keywords are handled with seperate function
-	hexes := NullString{}
-	csses := NullString{}
-	htmls := NullString{}
-	percents := NullString{}
-	words := NullString{}
-	weights := NullString{}
-
+	// images, colors and keywords are handled with seperate functions
 	post := &rawCreatePost{
-		url:        raw.Url,
-		title:      p.Title,
-		author:     p.Author,
-		permalink:  p.Permalink,
-		score:      p.Score,
-		nsfw:       p.Nsfw,
-		grayscale:  p.Grayscale,
-		time:       p.Time,
-		width:      raw.Width,
-		height:     raw.Height,
-		sprocket:   p.Sprocket,
-		lowUrl:     low.Url,
-		lowWidth:   low.Width,
-		lowHeight:  low.Height,
-		medUrl:     med.Url,
-		medWidth:   med.Width,
-		medHeight:  med.Height,
-		highUrl:    high.Url,
-		highWidth:  high.Width,
-		highHeight: high.Height,
-		hexes:      hexes,
-		csses:      csses,
-		htmls:      htmls,
-		percents:   percents,
-		words:      words,
-		weights:    weights,
+		title:     p.Title,
+		author:    addAuthorPrefix(p.Author),
+		permalink: p.Permalink,
+		score:     p.Score,
+		nsfw:      p.Nsfw,
+		grayscale: boolValue(p.Grayscale),
+		time:      p.Time,
+		width:     raw.Width,
+		height:    raw.Height,
+		sprocket:  boolValue(p.Sprocket),
+	}
+	if p.Hash != nil {
+		// stored as the signed bigint with the same bits
+		post.hash = sql.NullInt64{Int64: int64(*p.Hash), Valid: true}
 	}
 	return post, nil
 
@@ -1130,12 +1360,13 @@ func createPostToRawPostCreate(p *analogdb.CreatePost) (*rawCreatePost, error) {
 
 func rawPostToPost(p rawPost) (*analogdb.Post, error) {
 
-	// grab the images from raw
-	lowImage := analogdb.Image{Label: "low", Url: p.lowUrl, Width: p.lowWidth, Height: p.lowHeight}
-	medImage := analogdb.Image{Label: "medium", Url: p.medUrl, Width: p.medWidth, Height: p.medHeight}
-	highImage := analogdb.Image{Label: "high", Url: p.highUrl, Width: p.highWidth, Height: p.highHeight}
-	rawImage := analogdb.Image{Label: "raw", Url: p.url, Width: p.width, Height: p.height}
-	images := []analogdb.Image{lowImage, medImage, highImage, rawImage}
+	// grab the images, aggregated as json
+	var images = []analogdb.Image{}
+	if p.images.Valid {
+		if err := json.Unmarshal([]byte(p.images.String), &images); err != nil {
+			return nil, err
+		}
+	}
 
 	// grab the colors
 	var hexes, csses, htmls, percents []string
@@ -1206,6 +1437,15 @@ func rawPostToPost(p rawPost) (*analogdb.Post, error) {
 		keywords = append(keywords, analogdb.Keyword{Word: words[i], Weight: weight})
 	}
 
+	// grab the metadata, selected as json
+	var metadata *analogdb.PostMetadata
+	if p.metadata.Valid {
+		metadata = &analogdb.PostMetadata{}
+		if err := json.Unmarshal([]byte(p.metadata.String), metadata); err != nil {
+			return nil, err
+		}
+	}
+
 	post := &analogdb.Post{Id: p.id,
 		DisplayPost: analogdb.DisplayPost{
 			Title:     p.title,
@@ -1218,16 +1458,32 @@ func rawPostToPost(p rawPost) (*analogdb.Post, error) {
 			Sprocket:  p.sprocket,
 			Images:    images,
 			Colors:    colors,
-			Keywords:  keywords}}
+			Keywords:  keywords,
+			Metadata:  metadata}}
 	return post, nil
 }
 
 func scanRowToRawPostCount(rows *sql.Rows) (*rawPost, int, error) {
 	var p rawPost
 	var count int
-	if err := rows.Scan(
+	if err := rows.Scan(append(rawPostDest(&p), &count)...); err != nil {
+		return nil, 0, err
+	}
+	return &p, count, nil
+}
+
+func scanRowToRawPost(rows *sql.Rows) (*rawPost, error) {
+	var p rawPost
+	if err := rows.Scan(rawPostDest(&p)...); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// rawPostDest are the scan destinations of the columns selected by selectPostsQuery
+func rawPostDest(p *rawPost) []any {
+	return []any{
 		&p.id,
-		&p.rawCreatePost.url,
 		&p.rawCreatePost.title,
 		&p.rawCreatePost.author,
 		&p.rawCreatePost.permalink,
@@ -1238,29 +1494,33 @@ func scanRowToRawPostCount(rows *sql.Rows) (*rawPost, int, error) {
 		&p.rawCreatePost.width,
 		&p.rawCreatePost.height,
 		&p.rawCreatePost.sprocket,
-		&p.rawCreatePost.lowUrl,
-		&p.rawCreatePost.lowWidth,
-		&p.rawCreatePost.lowHeight,
-		&p.rawCreatePost.medUrl,
-		&p.rawCreatePost.medWidth,
-		&p.rawCreatePost.medHeight,
-		&p.rawCreatePost.highUrl,
-		&p.rawCreatePost.highWidth,
-		&p.rawCreatePost.highHeight,
+		&p.rawCreatePost.images,
 		&p.rawCreatePost.hexes,
 		&p.rawCreatePost.csses,
 		&p.rawCreatePost.htmls,
 		&p.rawCreatePost.percents,
 		&p.rawCreatePost.words,
 		&p.rawCreatePost.weights,
-		&count); err != nil {
-		return nil, 0, err
+		&p.rawCreatePost.metadata,
 	}
-	return &p, count, nil
 }
 
 // Strip the `u/` prefix from author
 // Modifies the post in place
 func stripAuthorPrefix(post *analogdb.Post) {
-	post.Author = strings.TrimPrefix(post.Author, "u/")
+	post.Author = strings.TrimPrefix(post.Author, authorPrefix)
+}
+
+// Add the `u/` prefix to author if it is missing,
+// authors are stored in the DB with the prefix
+func addAuthorPrefix(author string) string {
+	if strings.HasPrefix(author, authorPrefix) {
+		return author
+	}
+	return authorPrefix + author
+}
+
+// boolValue is false for an unknown bool
+func boolValue(b *bool) bool {
+	return b != nil && *b
 }
@@ -334,6 +334,134 @@ func TestRandomPost(t *testing.T) {
 	})
 }
 
+func TestOldestPost(t *testing.T) {
+	t.Run("PostSequential", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ps := NewPostService(db)
+
+		sort := analogdb.SortOldest
+		filter := &analogdb.PostFilter{Limit: &limit, Sort: &sort}
+
+		posts, _, err := ps.FindPosts(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		oldest := posts[0].Time
+		newest := posts[limit-1].Time
+		for _, p := range posts {
+			if p.Time < oldest {
+				t.Fatalf("posts not sorted oldest to newest")
+			}
+		}
+
+		filter.Keyset = &newest
+		posts, _, err = ps.FindPosts(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, p := range posts {
+			if p.Time < newest {
+				t.Fatalf("posts not sorted oldest to newest with keyset")
+			}
+		}
+	})
+}
+
+func TestResolutionPost(t *testing.T) {
+	t.Run("PostResolution", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ps := NewPostService(db)
+
+		sort := analogdb.SortResolution
+		filter := &analogdb.PostFilter{Limit: &limit, Sort: &sort}
+
+		posts, _, err := ps.FindPosts(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resolution := func(p *analogdb.Post) int {
+			high, _ := analogdb.FindImage(p.Images, analogdb.ImageHigh)
+			return high.Width * high.Height
+		}
+
+		seen := make(map[int]bool)
+		for i, p := range posts {
+			seen[p.Id] = true
+			if i > 0 && resolution(p) > resolution(posts[i-1]) {
+				t.Fatalf("posts not sorted highest to lowest resolution")
+			}
+		}
+
+		last := posts[limit-1]
+		filter.Keyset = &last.Id
+		posts, _, err = ps.FindPosts(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, p := range posts {
+			if seen[p.Id] {
+				t.Fatal("paginated posts must not repeat")
+			}
+			if resolution(p) > resolution(last) {
+				t.Fatalf("posts not sorted highest to lowest resolution with keyset")
+			}
+		}
+	})
+}
+
+func TestColorPost(t *testing.T) {
+	t.Run("PostColor", func(t *testing.T) {
+		db := mustOpen(t)
+		defer mustClose(t, db)
+		ps := NewPostService(db)
+
+		sort := analogdb.SortColor
+		colors := []string{"black"}
+		filter := &analogdb.PostFilter{Limit: &limit, Sort: &sort, Colors: &colors}
+		filter.SetMinColorPercent()
+
+		posts, _, err := ps.FindPosts(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		percent := func(p *analogdb.Post) float64 {
+			total := 0.0
+			for _, c := range p.Colors {
+				if c.Html == colors[0] {
+					total += c.Percent
+				}
+			}
+			return total
+		}
+
+		for i, p := range posts {
+			if i > 0 && percent(p) > percent(posts[i-1]) {
+				t.Fatalf("posts not sorted by color percent")
+			}
+		}
+
+		last := posts[limit-1]
+		filter.Keyset = &last.Id
+		posts, _, err = ps.FindPosts(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, p := range posts {
+			if percent(p) > percent(last) {
+				t.Fatalf("posts not sorted by color percent with keyset")
+			}
+		}
+	})
+}
+
 func TestFindPost(t *testing.T) {
 	t.Run("ErrNoPost", func(t *testing.T) {
 		db := mustOpen(t)
@@ -372,7 +500,12 @@ func TestCreateAndDeletePost(t *testing.T) {
 			Width:  0,
 			Height: 0,
 		}
-		fourImages := []analogdb.Image{testImage, testImage, testImage, testImage}
+		fourImages := []analogdb.Image{}
+		for _, label := range []string{analogdb.ImageLow, analogdb.ImageMedium, analogdb.ImageHigh, analogdb.ImageRaw} {
+			image := testImage
+			image.Label = label
+			fourImages = append(fourImages, image)
+		}
 
 		testColor := analogdb.Color{
 			Hex:     "#000000",
@@ -392,9 +525,9 @@ func TestCreateAndDeletePost(t *testing.T) {
 			Permalink: "test.permalink.com",
 			Score:     0,
 			Nsfw:      false,
-			Grayscale: false,
+			Grayscale: new(bool),
 			Time:      0,
-			Sprocket:  false,
+			Sprocket:  new(bool),
 			Images:    fourImages,
 			Colors:    fiveColors,
 			Keywords:  keywords,
@@ -446,9 +579,9 @@ func TestCreateAndDeletePost(t *testing.T) {
 			Permalink: "test.permalink.com",
 			Score:     0,
 			Nsfw:      false,
-			Grayscale: false,
+			Grayscale: new(bool),
 			Time:      0,
-			Sprocket:  false,
+			Sprocket:  new(bool),
 			Images:    threeImages,
 			Colors:    fiveColors,
 		}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/evanofslack/analogdb/logger"
 	_ "github.com/lib/pq"
@@ -19,9 +20,35 @@ type DB struct {
 	cancel         func()
 	logger         *logger.Logger
 	tracingEnabled bool
+	migrateOnOpen  bool
+	rulesCache     keywordRulesCache
+
+	// posts within this hamming distance of an existing image hash
+	// are duplicates, negative to skip checking
+	duplicateDistance int
+	rejectDuplicates  bool
 }
 
-func NewDB(dsn string, logger *logger.Logger, tracingEnabled bool) *DB {
+// Option configures a DB instance
+type Option func(*DB)
+
+// WithMigrateOnOpen applies pending migrations when the DB is opened
+func WithMigrateOnOpen() Option {
+	return func(db *DB) {
+		db.migrateOnOpen = true
+	}
+}
+
+// WithDuplicateCheck checks new posts against the image hashes of existing
+// posts, rejecting near duplicates if reject is set and logging them otherwise
+func WithDuplicateCheck(maxDistance int, reject bool) Option {
+	return func(db *DB) {
+		db.duplicateDistance = maxDistance
+		db.rejectDuplicates = reject
+	}
+}
+
+func NewDB(dsn string, logger *logger.Logger, tracingEnabled bool, opts ...Option) *DB {
 
 	logger.Debug().Msg("Initializing DB instance")
 
@@ -33,6 +60,11 @@ func NewDB(dsn string, logger *logger.Logger, tracingEnabled bool) *DB {
 		cancel:         cancel,
 		logger:         logger,
 		tracingEnabled: tracingEnabled,
+
+		duplicateDistance: -1,
+	}
+	for _, opt := range opts {
+		opt(db)
 	}
 
 	db.logger.Info().Msg("Initialized DB instance")
@@ -69,7 +101,17 @@ func (db *DB) Open() error {
 
 	db.logger.Info().Msg("Opened new DB instance")
 
-	return db.db.PingContext(db.ctx)
+	if err := db.db.PingContext(db.ctx); err != nil {
+		return err
+	}
+
+	if db.migrateOnOpen {
+		if _, err := db.MigrateUp(db.ctx); err != nil {
+			err = fmt.Errorf("Failed to migrate DB: %w", err)
+			return err
+		}
+	}
+	return nil
 }
 
 func (db *DB) Close() error {
@@ -105,3 +147,10 @@ func (ns *NullString) Scan(value interface{}) error {
 
 	return nil
 }
+
+// escapeLike escapes the wildcards of a LIKE pattern so s matches literally
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
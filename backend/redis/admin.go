@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errRedisNotConfigured = errors.New("redis is not configured")
+
+// Stats is a summary of the redis server backing the caches
+type Stats struct {
+	Keys       int64
+	UsedMemory string
+	Hits       int64
+	Misses     int64
+	Evictions  int64
+	Uptime     int64
+}
+
+// Flush removes every key from the redis database. Items held in the local
+// cache of running replicas are not removed, and expire within their ttl.
+func (rdb *RDB) Flush(ctx context.Context) error {
+	if rdb.db == nil {
+		return errRedisNotConfigured
+	}
+
+	rdb.logger.Debug().Ctx(ctx).Msg("Starting redis flush")
+
+	if err := rdb.db.FlushDB(ctx).Err(); err != nil {
+		rdb.logger.Error().Err(err).Ctx(ctx).Msg("Failed to flush redis")
+		return err
+	}
+
+	rdb.logger.Info().Ctx(ctx).Msg("Flushed redis")
+	return nil
+}
+
+// Stats reads the size and hit rate of the redis database
+func (rdb *RDB) Stats(ctx context.Context) (*Stats, error) {
+	if rdb.db == nil {
+		return nil, errRedisNotConfigured
+	}
+
+	keys, err := rdb.db.DBSize(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rdb.db.Info(ctx, "server", "memory", "stats").Result()
+	if err != nil {
+		return nil, err
+	}
+	info := parseInfo(raw)
+
+	stats := &Stats{
+		Keys:       keys,
+		UsedMemory: info["used_memory_human"],
+	}
+	stats.Hits, _ = strconv.ParseInt(info["keyspace_hits"], 10, 64)
+	stats.Misses, _ = strconv.ParseInt(info["keyspace_misses"], 10, 64)
+	stats.Evictions, _ = strconv.ParseInt(info["evicted_keys"], 10, 64)
+	stats.Uptime, _ = strconv.ParseInt(info["uptime_in_seconds"], 10, 64)
+	return stats, nil
+}
+
+// parseInfo reads the key:value lines of an INFO reply
+func parseInfo(raw string) map[string]string {
+	info := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			info[key] = value
+		}
+	}
+	return info
+}
@@ -2,10 +2,12 @@ package redis
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/evanofslack/analogdb"
 	"github.com/go-redis/cache/v9"
+	"github.com/mitchellh/hashstructure/v2"
 )
 
 const (
@@ -13,6 +15,9 @@ const (
 	authorsLocalSize = 1000
 	authorsTTL       = time.Hour * 4
 	authorsKey       = "authors"
+
+	// prefix of keys for individual author profiles
+	authorKeyPrefix = "author"
 )
 
 // ensure interface is implemented
@@ -78,3 +83,112 @@ func (s *AuthorService) FindAuthors(ctx context.Context) ([]string, error) {
 
 	return authors, nil
 }
+
+func (s *AuthorService) FindAuthorByName(ctx context.Context, name string) (*analogdb.Author, error) {
+
+	s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.cache.instance).Str("author", name).Msg("Starting find author by name with cache")
+	defer func() {
+		s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.cache.instance).Str("author", name).Msg("Finished find author by name with cache")
+	}()
+
+	var author *analogdb.Author
+	authorKey := fmt.Sprintf("%s-%s", authorKeyPrefix, name)
+
+	// try to get from the cache
+	err := s.cache.get(ctx, authorKey, &author)
+
+	// no error means we found it
+	if err == nil {
+		return author, nil
+	}
+
+	// fallback to postgres if not in cache
+	author, err = s.dbService.FindAuthorByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// add to cache
+	// do this async so response is returned quicker
+	go func() {
+
+		s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.cache.instance).Str("author", name).Msg("Adding author to cache")
+
+		// create a new context; orignal one will be canceled when request is closed
+		ctx, cancel := context.WithTimeout(context.Background(), cacheOpTimeout)
+		defer cancel()
+
+		s.cache.set(ctx, &cache.Item{
+			Ctx:   ctx,
+			Key:   authorKey,
+			Value: &author,
+			TTL:   authorsTTL,
+		})
+	}()
+
+	return author, nil
+}
+
+func (s *AuthorService) FindAuthorSummaries(ctx context.Context, filter *analogdb.AuthorFilter) ([]*analogdb.AuthorSummary, int, error) {
+
+	s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.cache.instance).Msg("Starting find author summaries with cache")
+	defer s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.cache.instance).Msg("Finished find author summaries with cache")
+
+	// generate a unique hash from the filter struct
+	hash, err := hashstructure.Hash(filter, hashstructure.FormatV2, nil)
+	if err != nil {
+		s.rdb.logger.Error().Err(err).Ctx(ctx).Str("instance", s.cache.instance).Msg("Failed to hash author filter")
+
+		// if we failed, fallback to db
+		return s.dbService.FindAuthorSummaries(ctx, filter)
+	}
+
+	summariesHash := fmt.Sprintf("%s-%d", authorsKey, hash)
+	summariesCountHash := fmt.Sprintf("%s-%s", summariesHash, "count")
+
+	var summaries []*analogdb.AuthorSummary
+	var count int
+
+	// try to get summaries from cache
+	summariesErr := s.cache.get(ctx, summariesHash, &summaries)
+
+	// try to get summaries count from cache
+	countErr := s.cache.get(ctx, summariesCountHash, &count)
+
+	// no error means we found in cache
+	if summariesErr == nil && countErr == nil {
+		return summaries, count, nil
+	}
+
+	// fallback to db
+	summaries, count, err = s.dbService.FindAuthorSummaries(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// add to cache
+	// do this async so response is returned quicker
+	go func() {
+
+		s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.cache.instance).Msg("Adding author summaries and count to cache")
+
+		// create a new context; orignal one will be canceled when request is closed
+		ctx, cancel := context.WithTimeout(context.Background(), cacheOpTimeout)
+		defer cancel()
+
+		s.cache.set(ctx, &cache.Item{
+			Ctx:   ctx,
+			Key:   summariesHash,
+			Value: &summaries,
+			TTL:   authorsTTL,
+		})
+		s.cache.set(ctx, &cache.Item{
+			Ctx:   ctx,
+			Key:   summariesCountHash,
+			Value: &count,
+			TTL:   authorsTTL,
+		})
+	}()
+
+	return summaries, count, nil
+}
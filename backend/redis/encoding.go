@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/evanofslack/analogdb"
+)
+
+// ensure interface is implemented
+var _ analogdb.EncodingService = (*EncodingService)(nil)
+
+// EncodingService records encodings in the DB, invalidating cached lists
+// of posts since they can be filtered by whether posts are encoded.
+// Encodings are recorded once per batch, and the command line exits right
+// after, so the cache is invalidated before returning.
+type EncodingService struct {
+	posts     *PostService
+	dbService analogdb.EncodingService
+}
+
+func NewCacheEncodingService(posts *PostService, dbService analogdb.EncodingService) *EncodingService {
+	return &EncodingService{
+		posts:     posts,
+		dbService: dbService,
+	}
+}
+
+func (s *EncodingService) FindEncodingByID(ctx context.Context, postID int) (*analogdb.Encoding, error) {
+	return s.dbService.FindEncodingByID(ctx, postID)
+}
+
+func (s *EncodingService) MarkEncoded(ctx context.Context, postIDs []int, schemaVersion int) error {
+	if err := s.dbService.MarkEncoded(ctx, postIDs, schemaVersion); err != nil {
+		return err
+	}
+	if len(postIDs) > 0 {
+		s.posts.invalidatePosts(ctx)
+	}
+	return nil
+}
+
+func (s *EncodingService) MarkFailed(ctx context.Context, failures []analogdb.EncodeFailure, schemaVersion int) error {
+	if err := s.dbService.MarkFailed(ctx, failures, schemaVersion); err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		s.posts.invalidatePosts(ctx)
+	}
+	return nil
+}
+
+func (s *EncodingService) OutdatedPostIDs(ctx context.Context, schemaVersion int) ([]int, error) {
+	return s.dbService.OutdatedPostIDs(ctx, schemaVersion)
+}
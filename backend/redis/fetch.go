@@ -0,0 +1,185 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/cache/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// timeout for loading an item from the db on a cache miss. Not tied
+	// to a request, since the result is shared with other waiting requests
+	loadTimeout = time.Second * 30
+
+	// how long a replica holds the lock while loading an item
+	lockTTL = time.Second * 10
+
+	// how often a replica waiting on another's lock checks the cache
+	lockPollInterval = time.Millisecond * 50
+)
+
+// deletes a lock only if it holds the given token, so a replica
+// can't release a lock that expired and was taken by another
+var releaseLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// cacheEntry wraps a cached value with the time it goes stale,
+// so stale entries can still be served while they are refreshed
+type cacheEntry[T any] struct {
+	Value      T
+	FreshUntil int64
+}
+
+func (entry *cacheEntry[T]) fresh() bool {
+	return time.Now().UnixNano() < entry.FreshUntil
+}
+
+// findWithCache tries to get an item from the cache, falling back to find
+// if it is missing. Items found with find are added to the cache.
+//
+// Concurrent misses for the same key are coalesced, so only one request per
+// replica calls find, and a redis lock ensures only one replica does at a time.
+// If the cache serves stale entries, an expired item is returned immediately
+// while it is refreshed in the background.
+func findWithCache[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, find func(ctx context.Context) (T, error)) (T, error) {
+
+	c.logger.Debug().Ctx(ctx).Str("instance", c.instance).Str("key", key).Msg("Starting find with cache")
+	defer c.logger.Debug().Ctx(ctx).Str("instance", c.instance).Str("key", key).Msg("Finished find with cache")
+
+	var entry cacheEntry[T]
+
+	// try to get from the cache
+	err := c.get(ctx, key, &entry)
+
+	// no error means we found it
+	if err == nil {
+		if !entry.fresh() {
+			c.logger.Debug().Ctx(ctx).Str("instance", c.instance).Str("key", key).Msg("Serving stale item while revalidating")
+			go c.group.DoChan(key, func() (any, error) {
+				return load(c, key, ttl, find)
+			})
+		}
+		return entry.Value, nil
+	}
+
+	// fallback to db, sharing the result with concurrent misses
+	v, err, shared := c.group.Do(key, func() (any, error) {
+		return load(c, key, ttl, find)
+	})
+	if shared {
+		c.logger.Debug().Ctx(ctx).Str("instance", c.instance).Str("key", key).Msg("Shared result of concurrent cache miss")
+	}
+	if err != nil {
+		var item T
+		return item, err
+	}
+	return v.(T), nil
+}
+
+// load gets an item with find and adds it to the cache. If another
+// replica is already loading the item, wait for it to be cached instead.
+func load[T any](c *Cache, key string, ttl time.Duration, find func(ctx context.Context) (T, error)) (T, error) {
+
+	// create a new context; the request that triggered the load
+	// may be canceled before others waiting on the result
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+
+	locked, release := c.lock(ctx, key)
+	if locked {
+		defer release()
+	} else if item, ok := waitForItem[T](ctx, c, key); ok {
+		return item, nil
+	}
+
+	item, err := find(ctx)
+	if err != nil {
+		return item, err
+	}
+
+	entry := cacheEntry[T]{
+		Value:      item,
+		FreshUntil: time.Now().Add(ttl).UnixNano(),
+	}
+
+	// add to cache
+	setCtx, setCancel := context.WithTimeout(context.Background(), cacheOpTimeout)
+	defer setCancel()
+
+	c.logger.Debug().Ctx(setCtx).Str("instance", c.instance).Str("key", key).Msg("Adding item to cache")
+	c.set(setCtx, &cache.Item{
+		Ctx:   setCtx,
+		Key:   key,
+		Value: &entry,
+		// keep the item around past its ttl so it can be served stale
+		TTL: ttl + c.staleTTL,
+	})
+
+	return item, nil
+}
+
+// waitForItem polls the cache until another replica has added the
+// item, giving up once the other replica's lock would have expired
+func waitForItem[T any](ctx context.Context, c *Cache, key string) (T, bool) {
+
+	c.logger.Debug().Ctx(ctx).Str("instance", c.instance).Str("key", key).Msg("Waiting for another replica to load item")
+
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	deadline := time.After(lockTTL)
+
+	var entry cacheEntry[T]
+	for {
+		select {
+		case <-ctx.Done():
+			return entry.Value, false
+		case <-deadline:
+			return entry.Value, false
+		case <-ticker.C:
+			// skip the local cache, it can't have been updated by another replica
+			if err := c.backend().GetSkippingLocalCache(ctx, key, &entry); err == nil && entry.fresh() {
+				return entry.Value, true
+			}
+		}
+	}
+}
+
+// lock tries to take a lock on loading key across all replicas, returning
+// whether it was taken and a function to release it. Failing to reach
+// redis is treated as taking the lock, so the item is still loaded.
+func (c *Cache) lock(ctx context.Context, key string) (bool, func()) {
+
+	// only this replica is loading into its memory cache
+	if !c.redisAvailable() {
+		return true, func() {}
+	}
+
+	lockKey := fmt.Sprintf("lock:%s:%s", c.instance, key)
+	token := newReplicaID()
+
+	ok, err := c.rdb.db.SetNX(ctx, lockKey, token, lockTTL).Result()
+	if err != nil {
+		c.logger.Error().Err(err).Ctx(ctx).Str("instance", c.instance).Str("key", key).Msg("Failed to take cache lock")
+		return true, func() {}
+	}
+	if !ok {
+		return false, func() {}
+	}
+
+	release := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cacheOpTimeout)
+		defer cancel()
+		// only delete the lock if we still hold it
+		if err := releaseLock.Run(ctx, c.rdb.db, []string{lockKey}, token).Err(); err != nil {
+			c.logger.Error().Err(err).Ctx(ctx).Str("instance", c.instance).Str("key", key).Msg("Failed to release cache lock")
+		}
+	}
+	return true, release
+}
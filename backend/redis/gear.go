@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+)
+
+const (
+	gearInstance  = "gear"
+	gearLocalSize = 100
+	gearTTL       = time.Hour * 4
+)
+
+// ensure interface is implemented
+var _ analogdb.GearService = (*GearService)(nil)
+
+type GearService struct {
+	rdb       *RDB
+	cache     *Cache
+	dbService analogdb.GearService
+}
+
+func NewCacheGearService(rdb *RDB, dbService analogdb.GearService) *GearService {
+
+	cache := rdb.NewCache(gearInstance, gearLocalSize, gearTTL)
+
+	return &GearService{
+		rdb:       rdb,
+		cache:     cache,
+		dbService: dbService,
+	}
+}
+
+func (s *GearService) FindFilms(ctx context.Context) (*[]analogdb.GearSummary, error) {
+	return findWithCache(ctx, s.cache, "films", gearTTL, func(ctx context.Context) (*[]analogdb.GearSummary, error) {
+		return s.dbService.FindFilms(ctx)
+	})
+}
+
+func (s *GearService) FindCameras(ctx context.Context) (*[]analogdb.GearSummary, error) {
+	return findWithCache(ctx, s.cache, "cameras", gearTTL, func(ctx context.Context) (*[]analogdb.GearSummary, error) {
+		return s.dbService.FindCameras(ctx)
+	})
+}
+
+func (s *GearService) ExtractGear(ctx context.Context) (*analogdb.ExtractGearResult, error) {
+	return s.dbService.ExtractGear(ctx)
+}
@@ -0,0 +1,383 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// channel cache invalidations are published on so all replicas see them
+	invalidationChannel = "analogdb:cache:invalidations"
+
+	// how long a replica trusts its local copy of a generation before
+	// rereading it from redis, in case an invalidation message was missed
+	generationRefresh = time.Second * 30
+)
+
+// invalidation is a message broadcast to all replicas when cached data goes stale.
+// Either a set of keys were deleted, or the generation of a query class was bumped.
+type invalidation struct {
+	Origin     string   `json:"origin"`
+	Instance   string   `json:"instance"`
+	Keys       []string `json:"keys,omitempty"`
+	Class      string   `json:"class,omitempty"`
+	Generation int64    `json:"generation,omitempty"`
+}
+
+// generations tracks the current generation of each query class in a cache.
+// Generations are part of the cache key, so bumping a generation makes
+// every entry cached under the previous one unreachable, both in redis
+// and in each replica's local cache.
+type generations struct {
+	mu      sync.Mutex
+	current map[string]int64
+	fetched map[string]time.Time
+}
+
+func newGenerations() *generations {
+	return &generations{
+		current: make(map[string]int64),
+		fetched: make(map[string]time.Time),
+	}
+}
+
+// set updates a generation, never moving it backwards
+func (g *generations) set(class string, gen int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if gen > g.current[class] {
+		g.current[class] = gen
+	}
+	g.fetched[class] = time.Now()
+}
+
+func (g *generations) get(class string) (int64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	gen, ok := g.current[class]
+	if !ok || time.Since(g.fetched[class]) > generationRefresh {
+		return gen, false
+	}
+	return gen, true
+}
+
+func generationKey(instance, class string) string {
+	return fmt.Sprintf("gen:%s:%s", instance, class)
+}
+
+func tagKey(instance, tag string) string {
+	return fmt.Sprintf("tag:%s:%s", instance, tag)
+}
+
+// incr bumps a generation locally, used while redis is unreachable
+func (g *generations) incr(class string) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.current[class] += 1
+	g.fetched[class] = time.Now()
+	return g.current[class]
+}
+
+// missedInvalidations are invalidations made while redis was unreachable,
+// applied to redis once it is reachable again
+type missedInvalidations struct {
+	mu      sync.Mutex
+	keys    map[string]bool
+	tags    map[string]bool
+	classes map[string]bool
+}
+
+func newMissedInvalidations() *missedInvalidations {
+	return &missedInvalidations{
+		keys:    make(map[string]bool),
+		tags:    make(map[string]bool),
+		classes: make(map[string]bool),
+	}
+}
+
+func (m *missedInvalidations) addKey(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key] = true
+}
+
+func (m *missedInvalidations) addTag(tag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tags[tag] = true
+}
+
+func (m *missedInvalidations) addClass(class string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.classes[class] = true
+}
+
+// drain returns all missed invalidations and resets them
+func (m *missedInvalidations) drain() (keys, tags, classes []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.keys {
+		keys = append(keys, key)
+	}
+	for tag := range m.tags {
+		tags = append(tags, tag)
+	}
+	for class := range m.classes {
+		classes = append(classes, class)
+	}
+	m.keys = make(map[string]bool)
+	m.tags = make(map[string]bool)
+	m.classes = make(map[string]bool)
+	return keys, tags, classes
+}
+
+// replayMissed applies invalidations made while redis was unreachable.
+// Called before the cache switches back to redis.
+func (cache *Cache) replayMissed(ctx context.Context) {
+
+	keys, tags, classes := cache.missed.drain()
+	if len(keys) == 0 && len(tags) == 0 && len(classes) == 0 {
+		return
+	}
+
+	cache.logger.Info().Ctx(ctx).Str("instance", cache.instance).Int("keys", len(keys)).Int("tags", len(tags)).Int("classes", len(classes)).Msg("Replaying cache invalidations missed while redis was unreachable")
+
+	for _, key := range keys {
+		if err := cache.cache.Delete(ctx, key); err != nil {
+			cache.logger.Error().Err(err).Ctx(ctx).Str("instance", cache.instance).Msg("Failed to replay cache delete")
+		}
+	}
+	if len(keys) > 0 {
+		cache.broadcastDelete(ctx, keys...)
+	}
+
+	for _, tag := range tags {
+		tk := tagKey(cache.instance, tag)
+		tagged, err := cache.rdb.db.SMembers(ctx, tk).Result()
+		if err != nil {
+			cache.logger.Error().Err(err).Ctx(ctx).Str("instance", cache.instance).Str("tag", tag).Msg("Failed to replay tag invalidation")
+			continue
+		}
+		for _, key := range tagged {
+			cache.cache.Delete(ctx, key)
+		}
+		if len(tagged) > 0 {
+			cache.broadcastDelete(ctx, tagged...)
+		}
+		cache.rdb.db.Del(ctx, tk)
+	}
+
+	for _, class := range classes {
+		// the local generation moved on while redis was unreachable,
+		// so move the shared generation past it
+		local, _ := cache.generations.get(class)
+		gen, err := cache.rdb.db.Incr(ctx, generationKey(cache.instance, class)).Result()
+		if err == nil && gen <= local {
+			gen, err = cache.rdb.db.IncrBy(ctx, generationKey(cache.instance, class), local-gen+1).Result()
+		}
+		if err != nil {
+			cache.logger.Error().Err(err).Ctx(ctx).Str("instance", cache.instance).Str("class", class).Msg("Failed to replay generation bump")
+			continue
+		}
+		cache.generations.set(class, gen)
+		cache.publish(ctx, invalidation{Instance: cache.instance, Class: class, Generation: gen})
+	}
+}
+
+// generation gets the current generation of a query class
+func (cache *Cache) generation(ctx context.Context, class string) int64 {
+
+	if gen, ok := cache.generations.get(class); ok || !cache.redisAvailable() {
+		return gen
+	}
+
+	gen, err := cache.rdb.db.Get(ctx, generationKey(cache.instance, class)).Int64()
+	if err != nil && err != redis.Nil {
+		cache.logger.Error().Err(err).Ctx(ctx).Str("instance", cache.instance).Str("class", class).Msg("Failed to get cache generation")
+		// use what we have, it is only used to build keys
+		gen, _ = cache.generations.get(class)
+		return gen
+	}
+
+	cache.generations.set(class, gen)
+	return gen
+}
+
+// bumpGeneration invalidates every entry of a query class on all replicas
+func (cache *Cache) bumpGeneration(ctx context.Context, class string) error {
+
+	cache.logger.Debug().Ctx(ctx).Str("instance", cache.instance).Str("class", class).Msg("Bumping cache generation")
+
+	if !cache.redisAvailable() {
+		cache.generations.incr(class)
+		cache.missed.addClass(class)
+		return nil
+	}
+
+	gen, err := cache.rdb.db.Incr(ctx, generationKey(cache.instance, class)).Result()
+	if err != nil {
+		cache.logger.Error().Err(err).Ctx(ctx).Str("instance", cache.instance).Str("class", class).Msg("Failed to bump cache generation")
+		return err
+	}
+	cache.generations.set(class, gen)
+
+	msg := invalidation{Instance: cache.instance, Class: class, Generation: gen}
+	return cache.publish(ctx, msg)
+}
+
+// broadcastDelete tells other replicas to evict keys from their local cache
+func (cache *Cache) broadcastDelete(ctx context.Context, keys ...string) error {
+	msg := invalidation{Instance: cache.instance, Keys: keys}
+	return cache.publish(ctx, msg)
+}
+
+// publish broadcasts an invalidation of this cache to all replicas
+func (cache *Cache) publish(ctx context.Context, msg invalidation) error {
+	// nothing to publish to without redis
+	if cache.cache == nil {
+		return nil
+	}
+	msg.Origin = cache.rdb.id
+	if err := cache.rdb.publish(ctx, msg); err != nil {
+		return err
+	}
+	cache.stats.incInvalidationsSent()
+	return nil
+}
+
+// tag records that a cached key contains data for each of tags,
+// so the key can be removed when any of that data changes
+func (cache *Cache) tag(ctx context.Context, key string, tags []string, ttl time.Duration) error {
+
+	// keys aren't tagged in memory; while redis is unreachable,
+	// lists are invalidated by bumping their generation instead
+	if !cache.redisAvailable() {
+		return nil
+	}
+
+	pipe := cache.rdb.db.Pipeline()
+	for _, tag := range tags {
+		tk := tagKey(cache.instance, tag)
+		pipe.SAdd(ctx, tk, key)
+		// the tag set only needs to live as long as the entries in it
+		pipe.Expire(ctx, tk, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		cache.logger.Error().Err(err).Ctx(ctx).Str("instance", cache.instance).Msg("Failed to tag cache key")
+		return err
+	}
+	return nil
+}
+
+// invalidateTags deletes every cached key tagged with any of tags
+func (cache *Cache) invalidateTags(ctx context.Context, tags []string) error {
+
+	cache.logger.Debug().Ctx(ctx).Str("instance", cache.instance).Strs("tags", tags).Msg("Invalidating cache tags")
+
+	if !cache.redisAvailable() {
+		for _, tag := range tags {
+			cache.missed.addTag(tag)
+		}
+		return nil
+	}
+
+	for _, tag := range tags {
+		tk := tagKey(cache.instance, tag)
+		keys, err := cache.rdb.db.SMembers(ctx, tk).Result()
+		if err != nil {
+			cache.logger.Error().Err(err).Ctx(ctx).Str("instance", cache.instance).Str("tag", tag).Msg("Failed to get tagged keys")
+			return err
+		}
+		for _, key := range keys {
+			cache.delete(ctx, key)
+		}
+		if err := cache.rdb.db.Del(ctx, tk).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish broadcasts an invalidation to all replicas
+func (rdb *RDB) publish(ctx context.Context, msg invalidation) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := rdb.db.Publish(ctx, invalidationChannel, payload).Err(); err != nil {
+		rdb.logger.Error().Err(err).Ctx(ctx).Str("instance", msg.Instance).Msg("Failed to publish cache invalidation")
+		return err
+	}
+	return nil
+}
+
+// subscribe listens for invalidations from other replicas until the RDB is closed
+func (rdb *RDB) subscribe() {
+
+	sub := rdb.db.Subscribe(rdb.ctx, invalidationChannel)
+	defer sub.Close()
+
+	rdb.logger.Info().Str("channel", invalidationChannel).Msg("Subscribed to cache invalidations")
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-rdb.ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var msg invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				rdb.logger.Error().Err(err).Msg("Failed to decode cache invalidation")
+				continue
+			}
+			rdb.receive(msg)
+		}
+	}
+}
+
+func (rdb *RDB) receive(msg invalidation) {
+
+	// we already applied our own invalidations
+	if msg.Origin == rdb.id {
+		return
+	}
+
+	rdb.mu.RLock()
+	cache, ok := rdb.caches[msg.Instance]
+	rdb.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	rdb.logger.Debug().Str("instance", msg.Instance).Str("origin", msg.Origin).Msg("Received cache invalidation")
+	cache.stats.incInvalidationsReceived()
+
+	// the shared redis entries are already deleted,
+	// only this replica's local copies are stale
+	for _, key := range msg.Keys {
+		cache.cache.DeleteFromLocalCache(key)
+	}
+	if msg.Class != "" {
+		cache.generations.set(msg.Class, msg.Generation)
+	}
+}
+
+// newReplicaID generates an id to identify invalidations sent by this replica
+func newReplicaID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprint(time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,139 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/hashstructure/v2"
+
+	"github.com/evanofslack/analogdb"
+)
+
+const (
+	keywordsInstance  = "keywords"
+	keywordsLocalSize = 1000
+	keywordsTTL       = time.Hour * 4
+
+	// query class of all cached keyword data; bumped when synonyms or
+	// the blacklist change, since that changes how keywords are grouped
+	keywordsClass = "keywords"
+)
+
+// ensure interface is implemented
+var _ analogdb.KeywordService = (*KeywordService)(nil)
+
+type KeywordService struct {
+	rdb       *RDB
+	cache     *Cache
+	dbService analogdb.KeywordService
+}
+
+func NewCacheKeywordService(rdb *RDB, dbService analogdb.KeywordService) *KeywordService {
+
+	cache := rdb.NewCache(keywordsInstance, keywordsLocalSize, keywordsTTL)
+
+	return &KeywordService{
+		rdb:       rdb,
+		cache:     cache,
+		dbService: dbService,
+	}
+}
+
+func (s *KeywordService) FindKeywords(ctx context.Context, filter *analogdb.KeywordFilter) (*[]analogdb.KeywordSummary, error) {
+
+	// generate a unique hash from the filter struct
+	hash, err := hashstructure.Hash(filter, hashstructure.FormatV2, nil)
+	if err != nil {
+		s.rdb.logger.Error().Err(err).Ctx(ctx).Str("instance", s.cache.instance).Msg("Failed to hash keyword filter")
+
+		// if we failed, fallback to db
+		return s.dbService.FindKeywords(ctx, filter)
+	}
+
+	key := fmt.Sprintf("keywords-%d-g%d", hash, s.cache.generation(ctx, keywordsClass))
+	return findWithCache(ctx, s.cache, key, keywordsTTL, func(ctx context.Context) (*[]analogdb.KeywordSummary, error) {
+		return s.dbService.FindKeywords(ctx, filter)
+	})
+}
+
+func (s *KeywordService) FindKeywordByWord(ctx context.Context, word string) (*analogdb.KeywordDetail, error) {
+	key := fmt.Sprintf("keyword-%s-g%d", word, s.cache.generation(ctx, keywordsClass))
+	return findWithCache(ctx, s.cache, key, keywordsTTL, func(ctx context.Context) (*analogdb.KeywordDetail, error) {
+		return s.dbService.FindKeywordByWord(ctx, word)
+	})
+}
+
+func (s *KeywordService) FindRelatedKeywords(ctx context.Context, word string, limit int) (*[]analogdb.RelatedKeyword, error) {
+	key := fmt.Sprintf("related-%s-%d-g%d", word, limit, s.cache.generation(ctx, keywordsClass))
+	return findWithCache(ctx, s.cache, key, keywordsTTL, func(ctx context.Context) (*[]analogdb.RelatedKeyword, error) {
+		return s.dbService.FindRelatedKeywords(ctx, word, limit)
+	})
+}
+
+func (s *KeywordService) GetKeywordSummary(ctx context.Context, limit int) (*[]analogdb.KeywordSummary, error) {
+	key := fmt.Sprintf("summary-%d-g%d", limit, s.cache.generation(ctx, keywordsClass))
+	return findWithCache(ctx, s.cache, key, keywordsTTL, func(ctx context.Context) (*[]analogdb.KeywordSummary, error) {
+		return s.dbService.GetKeywordSummary(ctx, limit)
+	})
+}
+
+func (s *KeywordService) FindKeywordSynonyms(ctx context.Context) (*[]analogdb.KeywordSynonym, error) {
+	return s.dbService.FindKeywordSynonyms(ctx)
+}
+
+func (s *KeywordService) CreateKeywordSynonym(ctx context.Context, synonym *analogdb.KeywordSynonym) error {
+	if err := s.dbService.CreateKeywordSynonym(ctx, synonym); err != nil {
+		return err
+	}
+	s.invalidateKeywords(ctx)
+	return nil
+}
+
+func (s *KeywordService) DeleteKeywordSynonym(ctx context.Context, synonym string) error {
+	if err := s.dbService.DeleteKeywordSynonym(ctx, synonym); err != nil {
+		return err
+	}
+	s.invalidateKeywords(ctx)
+	return nil
+}
+
+func (s *KeywordService) FindBlacklistedKeywords(ctx context.Context) (*[]string, error) {
+	return s.dbService.FindBlacklistedKeywords(ctx)
+}
+
+func (s *KeywordService) CreateBlacklistedKeyword(ctx context.Context, word string) error {
+	if err := s.dbService.CreateBlacklistedKeyword(ctx, word); err != nil {
+		return err
+	}
+	s.invalidateKeywords(ctx)
+	return nil
+}
+
+func (s *KeywordService) DeleteBlacklistedKeyword(ctx context.Context, word string) error {
+	if err := s.dbService.DeleteBlacklistedKeyword(ctx, word); err != nil {
+		return err
+	}
+	s.invalidateKeywords(ctx)
+	return nil
+}
+
+func (s *KeywordService) RenormalizeKeywords(ctx context.Context) (*analogdb.RenormalizeResult, error) {
+	result, err := s.dbService.RenormalizeKeywords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateKeywords(ctx)
+	return result, nil
+}
+
+// invalidateKeywords marks all cached keyword data as stale
+func (s *KeywordService) invalidateKeywords(ctx context.Context) {
+
+	s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.cache.instance).Msg("Invalidating keywords in cache")
+
+	// create a new context
+	ctx, cancel := context.WithTimeout(context.Background(), cacheOpTimeout)
+	defer cancel()
+	s.cache.bumpGeneration(ctx, keywordsClass)
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/go-redis/cache/v9"
+)
+
+// ensure interface is implemented
+var _ cache.LocalCache = (*memoryLRU)(nil)
+
+// memoryLRU is an in-process least recently used cache with a fixed ttl.
+// It backs caches when redis is not configured or cannot be reached.
+type memoryLRU struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List
+}
+
+type memoryItem struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+func newMemoryLRU(size int, ttl time.Duration) *memoryLRU {
+	return &memoryLRU{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+func (lru *memoryLRU) Set(key string, data []byte) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	expires := time.Now().Add(lru.ttl)
+
+	if elem, ok := lru.items[key]; ok {
+		item := elem.Value.(*memoryItem)
+		item.data = data
+		item.expires = expires
+		lru.order.MoveToFront(elem)
+		return
+	}
+
+	lru.items[key] = lru.order.PushFront(&memoryItem{key: key, data: data, expires: expires})
+
+	// evict the least recently used
+	for lru.order.Len() > lru.size {
+		lru.remove(lru.order.Back())
+	}
+}
+
+func (lru *memoryLRU) Get(key string) ([]byte, bool) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	elem, ok := lru.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*memoryItem)
+	if time.Now().After(item.expires) {
+		lru.remove(elem)
+		return nil, false
+	}
+	lru.order.MoveToFront(elem)
+	return item.data, true
+}
+
+func (lru *memoryLRU) Del(key string) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	if elem, ok := lru.items[key]; ok {
+		lru.remove(elem)
+	}
+}
+
+// Clear removes every item
+func (lru *memoryLRU) Clear() {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	lru.items = make(map[string]*list.Element, lru.size)
+	lru.order.Init()
+}
+
+func (lru *memoryLRU) remove(elem *list.Element) {
+	lru.order.Remove(elem)
+	delete(lru.items, elem.Value.(*memoryItem).key)
+}
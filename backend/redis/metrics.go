@@ -9,16 +9,20 @@ import (
 )
 
 type cacheStats struct {
-	hits   uint64
-	misses uint64
-	errors uint64
+	hits                  uint64
+	misses                uint64
+	errors                uint64
+	invalidationsSent     uint64
+	invalidationsReceived uint64
 }
 
 func newCacheStats() *cacheStats {
 	stats := &cacheStats{
-		hits:   0,
-		misses: 0,
-		errors: 0,
+		hits:                  0,
+		misses:                0,
+		errors:                0,
+		invalidationsSent:     0,
+		invalidationsReceived: 0,
 	}
 	return stats
 }
@@ -47,11 +51,30 @@ func (stats *cacheStats) getErrors() uint64 {
 	return atomic.LoadUint64(&stats.errors)
 }
 
+func (stats *cacheStats) incInvalidationsSent() {
+	atomic.AddUint64(&stats.invalidationsSent, 1)
+}
+
+func (stats *cacheStats) getInvalidationsSent() uint64 {
+	return atomic.LoadUint64(&stats.invalidationsSent)
+}
+
+func (stats *cacheStats) incInvalidationsReceived() {
+	atomic.AddUint64(&stats.invalidationsReceived, 1)
+}
+
+func (stats *cacheStats) getInvalidationsReceived() uint64 {
+	return atomic.LoadUint64(&stats.invalidationsReceived)
+}
+
 type cacheCollector struct {
-	caches      []*Cache
-	cacheHits   *prometheus.Desc
-	cacheMisses *prometheus.Desc
-	cacheErrors *prometheus.Desc
+	caches                     []*Cache
+	cacheHits                  *prometheus.Desc
+	cacheMisses                *prometheus.Desc
+	cacheErrors                *prometheus.Desc
+	cacheInvalidationsSent     *prometheus.Desc
+	cacheInvalidationsReceived *prometheus.Desc
+	cacheMemoryFallback        *prometheus.Desc
 }
 
 func newCacheCollector() *cacheCollector {
@@ -59,12 +82,18 @@ func newCacheCollector() *cacheCollector {
 	fqNameHits := prometheus.BuildFQName(metrics.AnalogdbNamespace, metrics.CacheSubsystem, "hits")
 	fqNameMisses := prometheus.BuildFQName(metrics.AnalogdbNamespace, metrics.CacheSubsystem, "misses")
 	fqNameErrors := prometheus.BuildFQName(metrics.AnalogdbNamespace, metrics.CacheSubsystem, "errors")
+	fqNameInvalidationsSent := prometheus.BuildFQName(metrics.AnalogdbNamespace, metrics.CacheSubsystem, "invalidations_sent")
+	fqNameInvalidationsReceived := prometheus.BuildFQName(metrics.AnalogdbNamespace, metrics.CacheSubsystem, "invalidations_received")
+	fqNameMemoryFallback := prometheus.BuildFQName(metrics.AnalogdbNamespace, metrics.CacheSubsystem, "memory_fallback")
 	variableLabels := []string{"instance"}
 
 	return &cacheCollector{
-		cacheHits:   prometheus.NewDesc(fqNameHits, "Number of cache hits", variableLabels, nil),
-		cacheMisses: prometheus.NewDesc(fqNameMisses, "Number of cache misses", variableLabels, nil),
-		cacheErrors: prometheus.NewDesc(fqNameErrors, "Number of cache errors", variableLabels, nil),
+		cacheHits:                  prometheus.NewDesc(fqNameHits, "Number of cache hits", variableLabels, nil),
+		cacheMisses:                prometheus.NewDesc(fqNameMisses, "Number of cache misses", variableLabels, nil),
+		cacheErrors:                prometheus.NewDesc(fqNameErrors, "Number of cache errors", variableLabels, nil),
+		cacheInvalidationsSent:     prometheus.NewDesc(fqNameInvalidationsSent, "Number of cache invalidations sent to other replicas", variableLabels, nil),
+		cacheInvalidationsReceived: prometheus.NewDesc(fqNameInvalidationsReceived, "Number of cache invalidations received from other replicas", variableLabels, nil),
+		cacheMemoryFallback:        prometheus.NewDesc(fqNameMemoryFallback, "Whether the cache is kept in memory instead of redis", variableLabels, nil),
 	}
 }
 
@@ -76,6 +105,9 @@ func (collector *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- collector.cacheHits
 	ch <- collector.cacheMisses
 	ch <- collector.cacheErrors
+	ch <- collector.cacheInvalidationsSent
+	ch <- collector.cacheInvalidationsReceived
+	ch <- collector.cacheMemoryFallback
 }
 
 func (collector *cacheCollector) Collect(ch chan<- prometheus.Metric) {
@@ -86,11 +118,20 @@ func (collector *cacheCollector) Collect(ch chan<- prometheus.Metric) {
 		hits := float64(cache.stats.getHits())
 		misses := float64(cache.stats.getMisses())
 		errors := float64(cache.stats.getErrors())
+		sent := float64(cache.stats.getInvalidationsSent())
+		received := float64(cache.stats.getInvalidationsReceived())
+		fallback := 0.0
+		if !cache.redisAvailable() {
+			fallback = 1.0
+		}
 		instance := cache.instance
 
 		ch <- prometheus.MustNewConstMetric(collector.cacheHits, prometheus.CounterValue, hits, instance)
 		ch <- prometheus.MustNewConstMetric(collector.cacheMisses, prometheus.CounterValue, misses, instance)
 		ch <- prometheus.MustNewConstMetric(collector.cacheErrors, prometheus.CounterValue, errors, instance)
+		ch <- prometheus.MustNewConstMetric(collector.cacheInvalidationsSent, prometheus.CounterValue, sent, instance)
+		ch <- prometheus.MustNewConstMetric(collector.cacheInvalidationsReceived, prometheus.CounterValue, received, instance)
+		ch <- prometheus.MustNewConstMetric(collector.cacheMemoryFallback, prometheus.GaugeValue, fallback, instance)
 	}
 }
 
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/go-redis/cache/v9"
 	"github.com/mitchellh/hashstructure/v2"
 
 	"github.com/evanofslack/analogdb"
@@ -28,6 +27,13 @@ const (
 	postsTTL = time.Hour * 1
 	// in memory cache size all other post service data
 	postsLocalSize = 100
+
+	// how long a list of posts can be served stale while it is refreshed
+	postsStaleTTL = time.Minute * 10
+
+	// query class of all cached post lists; bumped on every write
+	// since any create or patch can change which posts a list holds
+	postsClass = "list"
 )
 
 // ensure interface is implemented
@@ -38,12 +44,13 @@ type PostService struct {
 	postCache  *Cache
 	postsCache *Cache
 	dbService  analogdb.PostService
+	warmer     *Warmer
 }
 
 func NewCachePostService(rdb *RDB, dbService analogdb.PostService) *PostService {
 
 	postCache := rdb.NewCache(postInstance, postLocalSize, postTTL)
-	postsCache := rdb.NewCache(postsInstance, postsLocalSize, postsTTL)
+	postsCache := rdb.NewCache(postsInstance, postsLocalSize, postsTTL, WithStaleWhileRevalidate(postsStaleTTL))
 
 	return &PostService{
 		rdb:        rdb,
@@ -54,7 +61,16 @@ func NewCachePostService(rdb *RDB, dbService analogdb.PostService) *PostService
 }
 
 func (s *PostService) CreatePost(ctx context.Context, post *analogdb.CreatePost) (*analogdb.Post, error) {
-	return s.dbService.CreatePost(ctx, post)
+
+	created, err := s.dbService.CreatePost(ctx, post)
+	if err != nil {
+		return nil, err
+	}
+
+	// a new post can show up in any list of posts
+	go s.invalidatePosts(ctx)
+
+	return created, nil
 }
 
 func (s *PostService) FindPosts(ctx context.Context, filter *analogdb.PostFilter) ([]*analogdb.Post, int, error) {
@@ -71,56 +87,30 @@ func (s *PostService) FindPosts(ctx context.Context, filter *analogdb.PostFilter
 		return s.dbService.FindPosts(ctx, filter)
 	}
 
-	postsHash := fmt.Sprint(hash)
-	postsCountHash := fmt.Sprintf("%s-%s", postsHash, "count")
-
-	var posts []*analogdb.Post
-	var count int
+	// lists cached under an older generation are stale
+	gen := s.postsCache.generation(ctx, postsClass)
 
-	// try to get posts from cache
-	postsErr := s.postsCache.get(ctx, postsHash, &posts)
+	postsHash := fmt.Sprintf("%d-g%d", hash, gen)
 
-	// try to get posts count from cache
-	countErr := s.postsCache.get(ctx, postsCountHash, &count)
+	result, err := findWithCache(ctx, s.postsCache, postsHash, postsTTL, func(ctx context.Context) (*postsResult, error) {
+		posts, count, err := s.dbService.FindPosts(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
 
-	// no error means we found in cache
-	if postsErr == nil && countErr == nil {
-		return posts, count, nil
-	}
+		// tag the list with each post it holds
+		tags := make([]string, 0, len(posts))
+		for _, post := range posts {
+			tags = append(tags, postTag(post.Id))
+		}
+		s.postsCache.tag(ctx, postsHash, tags, postsTTL+postsStaleTTL)
 
-	// fallback to db
-	posts, count, err = s.dbService.FindPosts(ctx, filter)
+		return &postsResult{Posts: posts, Count: count}, nil
+	})
 	if err != nil {
 		return nil, 0, err
 	}
-
-	// add posts to cache
-	// do this async so response is returned quicker
-	go func() {
-
-		s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.postsCache.instance).Msg("Adding posts and posts counts to cache")
-
-		// create a new context; orignal one will be canceled when request is closed
-		ctx, cancel := context.WithTimeout(context.Background(), cacheOpTimeout)
-		defer cancel()
-
-		// add posts to cache
-		s.postsCache.set(ctx, &cache.Item{
-			Ctx:   ctx,
-			Key:   postsHash,
-			Value: &posts,
-			TTL:   postsTTL,
-		})
-		// add posts count to cache
-		s.postsCache.set(ctx, &cache.Item{
-			Ctx:   ctx,
-			Key:   postsCountHash,
-			Value: &count,
-			TTL:   postsTTL,
-		})
-	}()
-
-	return posts, count, nil
+	return result.Posts, result.Count, nil
 }
 
 func (s *PostService) FindPostByID(ctx context.Context, id int) (*analogdb.Post, error) {
@@ -130,41 +120,10 @@ func (s *PostService) FindPostByID(ctx context.Context, id int) (*analogdb.Post,
 		s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.postCache.instance).Int("postID", id).Msg("Finished find post by id with cache")
 	}()
 
-	var post *analogdb.Post
 	postKey := fmt.Sprint(id)
-
-	// try to get post from the cache
-	err := s.postCache.get(ctx, postKey, &post)
-
-	// no error means we found in cache
-	if err == nil {
-		return post, nil
-	}
-
-	// error means we must fallback to db
-	post, err = s.dbService.FindPostByID(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-
-	// add post to cache
-	// do this async so response is returned quicker
-	go func() {
-
-		s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.postCache.instance).Int("postID", id).Msg("Adding post to cache")
-		// create a new context; orignal one will be canceled when request is closed
-		ctx, cancel := context.WithTimeout(context.Background(), cacheOpTimeout)
-		defer cancel()
-
-		// add to cache
-		s.postCache.set(ctx, &cache.Item{
-			Ctx:   ctx,
-			Key:   postKey,
-			Value: &post,
-			TTL:   postTTL,
-		})
-	}()
-	return post, nil
+	return findWithCache(ctx, s.postCache, postKey, postTTL, func(ctx context.Context) (*analogdb.Post, error) {
+		return s.dbService.FindPostByID(ctx, id)
+	})
 }
 
 func (s *PostService) PatchPost(ctx context.Context, patch *analogdb.PatchPost, id int) error {
@@ -174,10 +133,14 @@ func (s *PostService) PatchPost(ctx context.Context, patch *analogdb.PatchPost,
 		s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.postCache.instance).Int("postID", id).Msg("Finished patch post with cache")
 	}()
 
-	// remove post from the cache
+	if err := s.dbService.PatchPost(ctx, patch, id); err != nil {
+		return err
+	}
+
+	// cache is now stale, delete old entries
 	go s.removePostFromCache(ctx, id)
 
-	return s.dbService.PatchPost(ctx, patch, id)
+	return nil
 }
 
 func (s *PostService) DeletePost(ctx context.Context, id int) error {
@@ -187,18 +150,62 @@ func (s *PostService) DeletePost(ctx context.Context, id int) error {
 		s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.postCache.instance).Int("postID", id).Msg("Finished delete post with cache")
 	}()
 
+	if err := s.dbService.DeletePost(ctx, id); err != nil {
+		return err
+	}
+
 	// cache is now stale, delete old entries
-	go func() {
-		s.removePostFromCache(ctx, id)
-	}()
+	go s.removePostFromCache(ctx, id)
 
-	return s.dbService.DeletePost(ctx, id)
+	return nil
 }
 
 func (s *PostService) AllPostIDs(ctx context.Context) ([]int, error) {
 	return s.dbService.AllPostIDs(ctx)
 }
 
+// exports are streamed straight from the db, they are too large to cache
+func (s *PostService) ExportPosts(ctx context.Context, filter *analogdb.PostFilter, after int, fn func(*analogdb.Post) error) error {
+	return s.dbService.ExportPosts(ctx, filter, after, fn)
+}
+
+func (s *PostService) ImportPosts(ctx context.Context, posts []*analogdb.CreatePost, update bool) (*analogdb.ImportResult, error) {
+
+	result, err := s.dbService.ImportPosts(ctx, posts, update)
+	if err != nil {
+		return nil, err
+	}
+
+	// updated posts are removed like a patch, then all lists are
+	// marked stale once for the whole batch. done before returning,
+	// since an import often exits as soon as it is finished.
+	if len(result.Created) > 0 || len(result.Updated) > 0 {
+		s.invalidateImported(ctx, result.Updated)
+	}
+	return result, nil
+}
+
+// invalidateImported removes updated posts from the cache and
+// marks every cached list of posts as stale
+func (s *PostService) invalidateImported(ctx context.Context, updated []int) {
+
+	s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.postsCache.instance).Int("updated", len(updated)).Msg("Invalidating imported posts in cache")
+
+	if len(updated) > 0 {
+		// create a new context
+		ctx, cancel := context.WithTimeout(context.Background(), cacheOpTimeout)
+		defer cancel()
+
+		tags := make([]string, 0, len(updated))
+		for _, id := range updated {
+			s.postCache.delete(ctx, fmt.Sprint(id))
+			tags = append(tags, postTag(id))
+		}
+		s.postsCache.invalidateTags(ctx, tags)
+	}
+	s.invalidatePosts(ctx)
+}
+
 func (s *PostService) removePostFromCache(ctx context.Context, id int) {
 
 	s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.postCache.instance).Int("postID", id).Msg("Removing post from cache")
@@ -209,4 +216,36 @@ func (s *PostService) removePostFromCache(ctx context.Context, id int) {
 	ctx, cancel := context.WithTimeout(context.Background(), cacheOpTimeout)
 	defer cancel()
 	s.postCache.delete(ctx, postKey)
+
+	// remove every list holding the post, then
+	// bump the generation since the post may now
+	// belong in lists it was not in before
+	s.postsCache.invalidateTags(ctx, []string{postTag(id)})
+	s.postsCache.bumpGeneration(ctx, postsClass)
+}
+
+// invalidatePosts marks every cached list of posts as stale
+func (s *PostService) invalidatePosts(ctx context.Context) {
+
+	s.rdb.logger.Debug().Ctx(ctx).Str("instance", s.postsCache.instance).Msg("Invalidating posts in cache")
+
+	// create a new context
+	ctx, cancel := context.WithTimeout(context.Background(), cacheOpTimeout)
+	defer cancel()
+	s.postsCache.bumpGeneration(ctx, postsClass)
+
+	// the first pages of each sort are now cold
+	if s.warmer != nil {
+		s.warmer.Warm()
+	}
+}
+
+// postsResult is a cached page of posts along with the total count
+type postsResult struct {
+	Posts []*analogdb.Post
+	Count int
+}
+
+func postTag(id int) string {
+	return fmt.Sprintf("post:%d", id)
 }
@@ -3,12 +3,15 @@ package redis
 import (
 	"context"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/evanofslack/analogdb/logger"
 	"github.com/evanofslack/analogdb/metrics"
 	"github.com/go-redis/cache/v9"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/redis/go-redis/extra/redisotel/v9"
 )
@@ -19,13 +22,24 @@ const (
 	decodeArrayErr2 = "msgpack: number of fields in array-encoded struct has changed"
 )
 
+const (
+	// how often to check if redis is reachable
+	healthCheckInterval = time.Second * 5
+)
+
 type RDB struct {
+	id        string
 	db        *redis.Client
+	available atomic.Bool
 	ctx       context.Context
 	cancel    func()
 	logger    *logger.Logger
 	metrics   *metrics.Metrics
 	collector *cacheCollector
+
+	// caches by instance name, to route invalidations
+	mu     sync.RWMutex
+	caches map[string]*Cache
 }
 
 // create a new redis database
@@ -33,35 +47,44 @@ func NewRDB(url string, logger *logger.Logger, metrics *metrics.Metrics, tracing
 
 	logger.Debug().Msg("Initializing cache instance")
 
-	opt, err := redis.ParseURL(url)
-	if err != nil {
-		return nil, err
-	}
-
-	db := redis.NewClient(opt)
-	logger.Debug().Msg("Created new redis client")
-
-	// prometheus metrics for redis
-	redisCollector := newRedisCollector(db)
-	metrics.Registry.MustRegister(redisCollector)
-	logger.Info().Msg("Registered redis collector with prometheus")
-
 	ctx, cancel := context.WithCancel(context.Background())
 	collector := newCacheCollector()
 
 	rdb := &RDB{
-		db:        db,
+		id:        newReplicaID(),
 		ctx:       ctx,
 		cancel:    cancel,
 		logger:    logger,
 		metrics:   metrics,
 		collector: collector,
+		caches:    make(map[string]*Cache),
 	}
 
 	// prometheus metrics for redis based caches
 	rdb.metrics.Registry.MustRegister(rdb.collector)
 	rdb.logger.Info().Msg("Registered cache collector with prometheus")
 
+	// without redis, all caches are kept in memory
+	if url == "" {
+		rdb.logger.Warn().Msg("Redis not configured, using in-memory cache")
+		rdb.logger.Info().Msg("Initialized cache instance")
+		return rdb, nil
+	}
+
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	db := redis.NewClient(opt)
+	rdb.db = db
+	logger.Debug().Msg("Created new redis client")
+
+	// prometheus metrics for redis
+	redisCollector := newRedisCollector(db)
+	metrics.Registry.MustRegister(redisCollector)
+	logger.Info().Msg("Registered redis collector with prometheus")
+
 	// otel instrumentation of redis
 	if tracingEnabled {
 		if err := redisotel.InstrumentTracing(db); err != nil {
@@ -71,18 +94,89 @@ func NewRDB(url string, logger *logger.Logger, metrics *metrics.Metrics, tracing
 		}
 	}
 
+	// listen for invalidations published by other replicas
+	go rdb.subscribe()
+
+	// fallback to memory while redis is unreachable
+	go rdb.monitor()
+
 	rdb.logger.Info().Msg("Initialized cache instance")
 
 	return rdb, nil
 }
 
+// Open checks the connection to redis. If redis can't be reached,
+// caches are kept in memory until it can.
 func (rdb *RDB) Open() error {
+	if rdb.db == nil {
+		return nil
+	}
 	if err := rdb.db.Ping(rdb.ctx).Err(); err != nil {
 		return err
 	}
+	rdb.available.Store(true)
 	return nil
 }
 
+// Available reports if caches are currently backed by redis
+func (rdb *RDB) Available() bool {
+	return rdb.available.Load()
+}
+
+// monitor periodically checks if redis is reachable,
+// switching caches between redis and memory
+func (rdb *RDB) monitor() {
+
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rdb.ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(rdb.ctx, cacheOpTimeout)
+			err := rdb.db.Ping(ctx).Err()
+			cancel()
+
+			wasAvailable := rdb.Available()
+			switch {
+			case err != nil && wasAvailable:
+				rdb.available.Store(false)
+				rdb.logger.Error().Err(err).Msg("Lost connection to redis, falling back to in-memory cache")
+			case err == nil && !wasAvailable:
+				rdb.reconnect()
+			}
+		}
+	}
+}
+
+// reconnect switches caches back to redis, first applying
+// any invalidations made while redis was unreachable
+func (rdb *RDB) reconnect() {
+
+	rdb.logger.Info().Msg("Reconnected to redis")
+
+	ctx, cancel := context.WithTimeout(rdb.ctx, cacheOpTimeout)
+	defer cancel()
+
+	rdb.mu.RLock()
+	caches := make([]*Cache, 0, len(rdb.caches))
+	for _, cache := range rdb.caches {
+		caches = append(caches, cache)
+	}
+	rdb.mu.RUnlock()
+
+	for _, cache := range caches {
+		cache.replayMissed(ctx)
+		// deletes and invalidations skip the memory cache while redis
+		// is reachable, so its items would be stale by the next outage
+		cache.memoryLRU.Clear()
+	}
+
+	rdb.available.Store(true)
+}
+
 func (rdb *RDB) Close() error {
 
 	rdb.logger.Debug().Msg("Starting redis server close")
@@ -98,39 +192,74 @@ func (rdb *RDB) Close() error {
 }
 
 type Cache struct {
-	cache    *cache.Cache
-	instance string
-	stats    *cacheStats
-	logger   *logger.Logger
+	cache       *cache.Cache
+	memory      *cache.Cache
+	memoryLRU   *memoryLRU
+	missed      *missedInvalidations
+	instance    string
+	stats       *cacheStats
+	logger      *logger.Logger
+	rdb         *RDB
+	generations *generations
+	group       singleflight.Group
+	staleTTL    time.Duration
 }
 
-// create a new cache backed by redis
-func (rdb *RDB) NewCache(instance string, size int, ttl time.Duration) *Cache {
+// CacheOption configures a single cache instance
+type CacheOption func(*Cache)
 
-	rdb.logger.Debug().Str("instance", instance).Msg("Initializing new cache")
+// WithStaleWhileRevalidate serves items for up to stale past their
+// ttl while a single request refreshes them in the background
+func WithStaleWhileRevalidate(stale time.Duration) CacheOption {
+	return func(c *Cache) {
+		c.staleTTL = stale
+	}
+}
 
-	inner := cache.New(&cache.Options{
-		Redis:        rdb.db,
-		LocalCache:   cache.NewTinyLFU(size, ttl),
-		StatsEnabled: true,
-	})
+// create a new cache backed by redis, or by memory if redis is unavailable
+func (rdb *RDB) NewCache(instance string, size int, ttl time.Duration, opts ...CacheOption) *Cache {
+
+	rdb.logger.Debug().Str("instance", instance).Msg("Initializing new cache")
 
 	stats := newCacheStats()
 
-	cache := &Cache{
-		cache:    inner,
-		instance: instance,
-		stats:    stats,
-		logger:   rdb.logger,
+	c := &Cache{
+		instance:    instance,
+		stats:       stats,
+		logger:      rdb.logger,
+		rdb:         rdb,
+		generations: newGenerations(),
+		missed:      newMissedInvalidations(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 
+	if rdb.db != nil {
+		c.cache = cache.New(&cache.Options{
+			Redis:        rdb.db,
+			LocalCache:   cache.NewTinyLFU(size, ttl),
+			StatsEnabled: true,
+		})
+	}
+
+	// the memory cache is the only copy, so it must hold items as long as redis would
+	c.memoryLRU = newMemoryLRU(size, ttl+c.staleTTL)
+	c.memory = cache.New(&cache.Options{
+		LocalCache: c.memoryLRU,
+	})
+
+	rdb.mu.Lock()
+	rdb.caches[instance] = c
+	rdb.mu.Unlock()
+
 	// register this cache instance with the collector
-	rdb.collector.registerCache(cache)
+	rdb.collector.registerCache(c)
 	rdb.logger.Info().Str("instance", instance).Msg("Registered cache instance with prometheus")
 
 	rdb.logger.Info().Str("instance", instance).Msg("Initialized new cache")
 
-	return cache
+	return c
 }
 
 func (cache *Cache) get(ctx context.Context, key string, item interface{}) error {
@@ -138,7 +267,7 @@ func (cache *Cache) get(ctx context.Context, key string, item interface{}) error
 	cache.logger.Debug().Ctx(ctx).Str("instance", cache.instance).Msg("Getting item from cache")
 
 	// do the lookup on the inner cache
-	err := cache.cache.Get(ctx, key, item)
+	err := cache.backend().Get(ctx, key, item)
 
 	// we got an error
 	if err != nil {
@@ -171,7 +300,7 @@ func (cache *Cache) set(ctx context.Context, item *cache.Item) error {
 
 	cache.logger.Debug().Ctx(ctx).Str("instance", cache.instance).Msg("Setting item in cache")
 
-	err := cache.cache.Set(item)
+	err := cache.backend().Set(item)
 	if err != nil {
 		cache.logger.Error().Err(err).Ctx(ctx).Str("instance", cache.instance).Msg("Failed to set item")
 	}
@@ -184,11 +313,34 @@ func (cache *Cache) delete(ctx context.Context, key string) error {
 
 	cache.logger.Debug().Ctx(ctx).Str("instance", cache.instance).Msg("Deleting item from cache")
 
-	err := cache.cache.Delete(ctx, key)
+	err := cache.backend().Delete(ctx, key)
 	if err != nil {
 		cache.logger.Error().Err(err).Ctx(ctx).Str("instance", cache.instance).Msg("Failed to delete item")
 	}
 
+	if !cache.redisAvailable() {
+		// delete from redis once it is reachable again
+		cache.missed.addKey(key)
+		if cache.cache != nil {
+			cache.cache.DeleteFromLocalCache(key)
+		}
+	} else {
+		// other replicas may hold the item in their local cache
+		cache.broadcastDelete(ctx, key)
+	}
+
 	cache.logger.Debug().Ctx(ctx).Str("instance", cache.instance).Msg("Deleted item from cache")
 	return err
 }
+
+// backend is the cache currently in use
+func (cache *Cache) backend() *cache.Cache {
+	if cache.redisAvailable() {
+		return cache.cache
+	}
+	return cache.memory
+}
+
+func (cache *Cache) redisAvailable() bool {
+	return cache.cache != nil && cache.rdb.Available()
+}
@@ -61,8 +61,12 @@ func (s *SimilarityService) EncodePost(ctx context.Context, id int) error {
 	return s.dbService.EncodePost(ctx, id)
 }
 
-func (s *SimilarityService) BatchEncodePosts(ctx context.Context, ids []int, batchSize int) error {
-	return s.dbService.BatchEncodePosts(ctx, ids, batchSize)
+func (s *SimilarityService) BatchEncodePosts(ctx context.Context, ids []int, batchSize int, progress analogdb.EncodeProgressFunc) (*analogdb.EncodeResult, error) {
+	return s.dbService.BatchEncodePosts(ctx, ids, batchSize, progress)
+}
+
+func (s *SimilarityService) EncodeOutdatedPosts(ctx context.Context, batchSize int, progress analogdb.EncodeProgressFunc) (*analogdb.EncodeResult, error) {
+	return s.dbService.EncodeOutdatedPosts(ctx, batchSize, progress)
 }
 
 func (s *SimilarityService) FindSimilarPosts(ctx context.Context, filter *analogdb.PostSimilarityFilter) ([]*analogdb.Post, error) {
@@ -0,0 +1,237 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// matches the default page size of the http server,
+	// so warmed pages share cache keys with requests
+	warmPageSize = 20
+
+	// wait for writes to settle before warming, since
+	// posts are usually created in bursts by the scraper
+	warmDebounce = time.Second * 10
+
+	// upper bound on how long a single warm can take
+	warmTimeout = time.Minute * 5
+)
+
+// sorts warmed; these are the sorts of the home page
+var warmSorts = []analogdb.PostSort{analogdb.SortTime, analogdb.SortScore, analogdb.SortRandom}
+
+// Warmer precomputes the first pages of the most requested
+// post queries, so they are served from cache after a deploy
+// or after new posts invalidate cached lists.
+type Warmer struct {
+	posts  *PostService
+	pages  int
+	rdb    *RDB
+	stats  *warmStats
+	ctx    context.Context
+	cancel func()
+
+	mu      sync.Mutex
+	running bool
+	pending bool
+	timer   *time.Timer
+}
+
+func NewCacheWarmer(rdb *RDB, posts *PostService, pages int) *Warmer {
+
+	ctx, cancel := context.WithCancel(rdb.ctx)
+
+	w := &Warmer{
+		posts:  posts,
+		pages:  pages,
+		rdb:    rdb,
+		stats:  newWarmStats(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	w.stats.register(rdb.metrics.Registry)
+
+	// warm again whenever posts are created
+	posts.warmer = w
+
+	return w
+}
+
+// Start warms the cache in the background
+func (w *Warmer) Start() {
+	w.rdb.logger.Info().Int("pages", w.pages).Msg("Starting cache warmer")
+	go w.run()
+}
+
+func (w *Warmer) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.cancel()
+}
+
+// Warm schedules warming the cache once writes have settled
+func (w *Warmer) Warm() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Reset(warmDebounce)
+		return
+	}
+	w.timer = time.AfterFunc(warmDebounce, w.run)
+}
+
+// run warms the cache, making sure only one warm runs at a time.
+// If asked to warm while running, warm again once finished.
+func (w *Warmer) run() {
+
+	w.mu.Lock()
+	if w.running {
+		w.pending = true
+		w.mu.Unlock()
+		return
+	}
+	w.running = true
+	w.mu.Unlock()
+
+	for {
+		w.warm()
+
+		w.mu.Lock()
+		if !w.pending {
+			w.running = false
+			w.mu.Unlock()
+			return
+		}
+		w.pending = false
+		w.mu.Unlock()
+	}
+}
+
+func (w *Warmer) warm() {
+
+	ctx, cancel := context.WithTimeout(w.ctx, warmTimeout)
+	defer cancel()
+
+	w.rdb.logger.Debug().Ctx(ctx).Msg("Starting cache warm")
+	start := time.Now()
+
+	pages := 0
+	for _, sort := range warmSorts {
+		// random order is only the same across pages for a fixed seed
+		seeds := []*int{nil}
+		if sort == analogdb.SortRandom {
+			seeds = []*int{}
+			for _, seed := range analogdb.RandomSeeds() {
+				seed := seed
+				seeds = append(seeds, &seed)
+			}
+		}
+		for _, seed := range seeds {
+			n, err := w.warmSort(ctx, sort, seed)
+			pages += n
+			if err != nil {
+				w.rdb.logger.Error().Err(err).Ctx(ctx).Str("sort", sort.String()).Msg("Failed to warm cache")
+				w.stats.errors.Inc()
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}
+
+	duration := time.Since(start)
+	w.stats.runs.Inc()
+	w.stats.pages.Add(float64(pages))
+	w.stats.duration.Observe(duration.Seconds())
+
+	w.rdb.logger.Info().Ctx(ctx).Int("pages", pages).Dur("duration", duration).Msg("Finished cache warm")
+}
+
+// warmSort loads the first pages of a sort into the cache,
+// returning the number of pages warmed
+func (w *Warmer) warmSort(ctx context.Context, sort analogdb.PostSort, seed *int) (int, error) {
+
+	limit := warmPageSize
+	var keyset *int
+
+	for page := 0; page < w.pages; page++ {
+
+		// built the same way as the http server so the cache keys match
+		filter := analogdb.NewPostFilter(&limit, &sort, keyset, nil, nil, nil, seed, nil, nil, nil, nil, nil, nil)
+
+		posts, _, err := w.posts.FindPosts(ctx, filter)
+		if err != nil {
+			return page, err
+		}
+		if len(posts) < limit {
+			return page + 1, nil
+		}
+
+		next := nextKeyset(sort, posts[len(posts)-1])
+		keyset = &next
+	}
+	return w.pages, nil
+}
+
+// nextKeyset is the page id of the page after the one ending with last
+func nextKeyset(sort analogdb.PostSort, last *analogdb.Post) int {
+	switch sort {
+	case analogdb.SortScore:
+		return last.Score
+	case analogdb.SortTrending, analogdb.SortResolution, analogdb.SortColor:
+		return last.Id
+	default:
+		return last.Time
+	}
+}
+
+type warmStats struct {
+	runs     prometheus.Counter
+	pages    prometheus.Counter
+	errors   prometheus.Counter
+	duration prometheus.Histogram
+}
+
+func newWarmStats() *warmStats {
+	return &warmStats{
+		runs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metrics.AnalogdbNamespace,
+			Subsystem: metrics.CacheSubsystem,
+			Name:      "warm_runs_total",
+			Help:      "Number of completed cache warms",
+		}),
+		pages: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metrics.AnalogdbNamespace,
+			Subsystem: metrics.CacheSubsystem,
+			Name:      "warm_pages_total",
+			Help:      "Number of pages of posts warmed",
+		}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metrics.AnalogdbNamespace,
+			Subsystem: metrics.CacheSubsystem,
+			Name:      "warm_errors_total",
+			Help:      "Number of errors while warming the cache",
+		}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metrics.AnalogdbNamespace,
+			Subsystem: metrics.CacheSubsystem,
+			Name:      "warm_duration_seconds",
+			Help:      "Time taken to warm the cache",
+			Buckets:   []float64{1, 5, 10, 30, 60, 120, 300},
+		}),
+	}
+}
+
+func (stats *warmStats) register(registry *prometheus.Registry) {
+	registry.MustRegister(stats.runs, stats.pages, stats.errors, stats.duration)
+}
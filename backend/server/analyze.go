@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/imaging"
+)
+
+// analyzePost hashes the image of a post to find duplicates, reads its
+// metadata, and computes its colors, grayscale and sprocket when the client
+// left them out
+func (s *Server) analyzePost(ctx context.Context, post *analogdb.CreatePost) error {
+
+	// a client that analyzed the image itself can still create the post
+	// if the image can't be analyzed, only without a hash or metadata
+	required := imaging.PostNeedsAnalysis(post)
+
+	s.logger.Debug().Ctx(ctx).Str("permalink", post.Permalink).Msg("Starting post image analysis")
+
+	if err := s.Downloader.AnalyzePost(ctx, post); err != nil {
+		s.logger.Error().Err(err).Ctx(ctx).Str("permalink", post.Permalink).Msg("Failed to analyze post image")
+		if !required {
+			return nil
+		}
+		return &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "failed to analyze post image to compute its colors, grayscale and sprocket"}
+	}
+
+	s.logger.Info().Ctx(ctx).Str("permalink", post.Permalink).Bool("grayscale", *post.Grayscale).Bool("sprocket", *post.Sprocket).Bool("exif", post.Metadata != nil && post.Metadata.HasExif).Msg("Analyzed post image")
+	return nil
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/imaging"
+	"github.com/evanofslack/analogdb/logger"
+)
+
+func TestAnalyzePost(t *testing.T) {
+	t.Run("Downloads image once", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 64, 48))
+		for y := 0; y < 48; y++ {
+			for x := 0; x < 64; x++ {
+				img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 4), G: uint8(y * 5), B: 128, A: 255})
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+
+		var requests atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(buf.Bytes())
+		}))
+		defer srv.Close()
+
+		logger, err := logger.New("debug", "debug", "analogdb-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Server{logger: logger, Downloader: imaging.NewDownloader()}
+
+		post := &analogdb.CreatePost{}
+		for _, label := range []string{analogdb.ImageLow, analogdb.ImageMedium, analogdb.ImageHigh, analogdb.ImageRaw} {
+			post.Images = append(post.Images, analogdb.Image{Label: label, Url: srv.URL + "/" + label, Width: 64, Height: 48})
+		}
+
+		if err := s.analyzePost(context.Background(), post); err != nil {
+			t.Fatal(err)
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("want image downloaded once, got %d downloads", got)
+		}
+		if post.Hash == nil || post.Metadata == nil {
+			t.Error("want post hashed and its metadata read")
+		}
+		if len(post.Colors) == 0 || post.Grayscale == nil || post.Sprocket == nil {
+			t.Error("want post colors, grayscale and sprocket computed")
+		}
+	})
+}
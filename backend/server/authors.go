@@ -1,8 +1,11 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
 
+	"github.com/evanofslack/analogdb"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -10,23 +13,208 @@ type AuthorsResponse struct {
 	Authors []string `json:"authors"`
 }
 
+type AuthorsMeta struct {
+	TotalAuthors int    `json:"total_authors"`
+	PageSize     int    `json:"page_size"`
+	PageID       string `json:"next_page_id"`
+	PageURL      string `json:"next_page_url"`
+}
+
+type AuthorSummariesResponse struct {
+	Meta    AuthorsMeta              `json:"meta"`
+	Authors []analogdb.AuthorSummary `json:"authors"`
+}
+
+// AuthorResponse is an author's profile with a page of their posts.
+// It is not a list, so it is only encoded as json or msgpack; the
+// posts alone are a list under /posts?author=.
+type AuthorResponse struct {
+	Author analogdb.Author `json:"author"`
+	Meta   Meta            `json:"meta"`
+	Posts  []analogdb.Post `json:"posts"`
+}
+
 const authorsPath = "/authors"
 
+// default limit on number of authors returned
+var defaultAuthorLimit = 20
+
+// default to sorting by number of posts
+var defaultAuthorSort = analogdb.AuthorSortPosts
+
+// query parameters that request a paginated list of author summaries
+// instead of the list of all authors
+var authorSummaryParams = []string{"sort", "page_size", "page_id", "prefix"}
+
 func (s *Server) mountAuthorHandlers() {
 	s.router.Route(authorsPath, func(r chi.Router) {
 		r.Get("/", s.getAuthors)
+		r.Get("/{name}", s.getAuthor)
 	})
 }
 
 func (s *Server) getAuthors(w http.ResponseWriter, r *http.Request) {
+
+	// list author summaries if requested
+	for _, param := range authorSummaryParams {
+		if r.URL.Query().Has(param) {
+			s.getAuthorSummaries(w, r)
+			return
+		}
+	}
+
 	authors, err := s.AuthorService.FindAuthors(r.Context())
 	if err != nil {
 		s.writeError(w, r, err)
+		return
 	}
 	authorsResponse := AuthorsResponse{
 		Authors: authors,
 	}
+	setCacheControl(w, cacheList)
 	if err := encodeResponse(w, r, http.StatusOK, authorsResponse); err != nil {
 		s.writeError(w, r, err)
 	}
 }
+
+func (s *Server) getAuthorSummaries(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseToAuthorFilter(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	summaries, count, err := s.AuthorService.FindAuthorSummaries(r.Context(), filter)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	resp := AuthorSummariesResponse{
+		Meta:    setAuthorsMeta(filter, summaries, count),
+		Authors: []analogdb.AuthorSummary{},
+	}
+	for _, summary := range summaries {
+		resp.Authors = append(resp.Authors, *summary)
+	}
+	setCacheControl(w, cacheList)
+	if err := encodeResponse(w, r, http.StatusOK, resp); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) getAuthor(w http.ResponseWriter, r *http.Request) {
+
+	var name string
+	if name = chi.URLParam(r, "name"); name == "" {
+		err := &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "Must provide author name as parameter"}
+		s.writeError(w, r, err)
+		return
+	}
+
+	author, err := s.AuthorService.FindAuthorByName(r.Context(), name)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// the author's posts are paginated just like any other posts
+	filter, err := parseToFilter(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	filter.Author = &name
+
+	posts, err := s.makePostResponse(r, filter)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// the next page is of the author, not of every post filtered by author
+	if posts.Meta.PageURL != "" {
+		pageFilter := *filter
+		pageFilter.Author = nil
+		posts.Meta.PageURL = pageURL(authorsPath+"/"+url.PathEscape(name), &pageFilter, posts.Meta.PageID)
+	}
+
+	resp := AuthorResponse{
+		Author: *author,
+		Meta:   posts.Meta,
+		Posts:  posts.Posts,
+	}
+	setCacheControl(w, cacheList)
+	if err := encodeResponse(w, r, http.StatusOK, resp); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+// setAuthorsMeta computes the metadata from a query of author summaries
+func setAuthorsMeta(filter *analogdb.AuthorFilter, summaries []*analogdb.AuthorSummary, count int) AuthorsMeta {
+
+	meta := AuthorsMeta{TotalAuthors: count}
+
+	if limit := filter.Limit; limit != nil {
+		meta.PageSize = *limit
+		if len(summaries) != *limit || len(summaries) == 0 {
+			// reached the end of pagination
+			return meta
+		}
+	}
+
+	meta.PageID = summaries[len(summaries)-1].Name
+
+	path := authorsPath
+	numParams := 0
+	if sort := filter.Sort; sort != nil {
+		path += fmt.Sprintf("%ssort=%s", paramJoiner(&numParams), sort)
+	}
+	if limit := filter.Limit; limit != nil {
+		path += fmt.Sprintf("%spage_size=%d", paramJoiner(&numParams), *limit)
+	}
+	path += fmt.Sprintf("%spage_id=%s", paramJoiner(&numParams), url.QueryEscape(meta.PageID))
+	if prefix := filter.Prefix; prefix != nil {
+		path += fmt.Sprintf("%sprefix=%s", paramJoiner(&numParams), url.QueryEscape(*prefix))
+	}
+	meta.PageURL = path
+
+	return meta
+}
+
+// parse URL for query parameters and convert to AuthorFilter
+func parseToAuthorFilter(r *http.Request) (*analogdb.AuthorFilter, error) {
+
+	filter := &analogdb.AuthorFilter{Limit: &defaultAuthorLimit, Sort: &defaultAuthorSort}
+
+	values := r.URL.Query()
+
+	if sort := values.Get("sort"); sort != "" {
+		authorSort := analogdb.AuthorSortFromString(sort)
+		if authorSort == analogdb.AuthorSortUnknown {
+			return nil, fmt.Errorf("invalid sort parameter %s, valid options are 'posts', 'score'", sort)
+		}
+		filter.Sort = &authorSort
+	}
+
+	if limit := values.Get("page_size"); limit != "" {
+		if intLimit, err := stringToInt(limit); err != nil {
+			return nil, err
+		} else {
+			// ensure limit is less than configured max
+			if intLimit <= maxLimit {
+				filter.Limit = &intLimit
+			} else {
+				filter.Limit = &maxLimit
+			}
+		}
+	}
+
+	if keyset := values.Get("page_id"); keyset != "" {
+		filter.Keyset = &keyset
+	}
+
+	if prefix := values.Get("prefix"); prefix != "" {
+		filter.Prefix = &prefix
+	}
+
+	return filter, nil
+}
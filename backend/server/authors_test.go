@@ -1,11 +1,15 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/go-chi/chi/v5"
 )
 
 const totalAuthors = 4864
@@ -43,3 +47,145 @@ func TestFindAuthors(t *testing.T) {
 		}
 	})
 }
+
+func TestFindAuthor(t *testing.T) {
+	t.Run("Author profile", func(t *testing.T) {
+		s, db := mustOpen(t)
+		defer mustClose(t, s, db)
+
+		r := httptest.NewRequest(http.MethodGet, "/authors/sunnyintheoffice", nil)
+		w := httptest.NewRecorder()
+
+		s.router.ServeHTTP(w, r)
+
+		if want, got := http.StatusOK, w.Code; got != want {
+			t.Errorf("want status %d, got %d", want, got)
+		}
+
+		var author AuthorResponse
+		if err := json.NewDecoder(w.Result().Body).Decode(&author); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := author.Author.PostCount, 1; got != want {
+			t.Errorf("invalid number of author posts, want %d, got %d", want, got)
+		}
+		if got, want := len(author.Posts), 1; got != want {
+			t.Errorf("invalid number of posts, want %d, got %d", want, got)
+		}
+	})
+	t.Run("Nonexisting author", func(t *testing.T) {
+		s, db := mustOpen(t)
+		defer mustClose(t, s, db)
+
+		r := httptest.NewRequest(http.MethodGet, "/authors/not-a-real-author", nil)
+		w := httptest.NewRecorder()
+
+		s.router.ServeHTTP(w, r)
+
+		if want, got := http.StatusNotFound, w.Code; got != want {
+			t.Errorf("want status %d, got %d", want, got)
+		}
+	})
+}
+
+func TestFindAuthorSummaries(t *testing.T) {
+	t.Run("Paginated authors", func(t *testing.T) {
+		s, db := mustOpen(t)
+		defer mustClose(t, s, db)
+
+		r := httptest.NewRequest(http.MethodGet, "/authors?sort=score&page_size=10", nil)
+		w := httptest.NewRecorder()
+
+		s.router.ServeHTTP(w, r)
+
+		if want, got := http.StatusOK, w.Code; got != want {
+			t.Errorf("want status %d, got %d", want, got)
+		}
+
+		var authors AuthorSummariesResponse
+		if err := json.NewDecoder(w.Result().Body).Decode(&authors); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := len(authors.Authors), 10; got != want {
+			t.Errorf("invalid number of authors, want %d, got %d", want, got)
+		}
+		if authors.Meta.PageID != authors.Authors[9].Name {
+			t.Errorf("next page id must be last author, want %s, got %s", authors.Authors[9].Name, authors.Meta.PageID)
+		}
+	})
+}
+
+func TestSetAuthorsMeta(t *testing.T) {
+	t.Run("Escapes page URL", func(t *testing.T) {
+		limit := 1
+		prefix := "a&b"
+		filter := &analogdb.AuthorFilter{Limit: &limit, Prefix: &prefix}
+		summaries := []*analogdb.AuthorSummary{{Name: "c d"}}
+
+		meta := setAuthorsMeta(filter, summaries, 1)
+		if got, want := meta.PageURL, authorsPath+"?page_size=1&page_id=c+d&prefix=a%26b"; got != want {
+			t.Errorf("wrong page URL, want %s, got %s", want, got)
+		}
+	})
+}
+
+func TestAuthorResponseFormat(t *testing.T) {
+	t.Run("Not a list", func(t *testing.T) {
+		resp := AuthorResponse{Meta: Meta{PageURL: "/authors/a?page_id=1"}}
+
+		for _, accept := range []string{mediaCSV, mediaNDJSON} {
+			if got, want := negotiateFormat(accept, resp).contentType, responseFormats[mediaJSON].contentType; got != want {
+				t.Errorf("wrong format for %s, want %s, got %s", accept, want, got)
+			}
+		}
+		if _, ok := any(resp).(pagedResponse); ok {
+			t.Error("author response must not link to the next page of posts")
+		}
+	})
+}
+
+// pagedAuthorService finds any author
+type pagedAuthorService struct {
+	analogdb.AuthorService
+}
+
+func (as *pagedAuthorService) FindAuthorByName(ctx context.Context, name string) (*analogdb.Author, error) {
+	return &analogdb.Author{Name: name}, nil
+}
+
+// pagedPostService finds a full page of posts
+type pagedPostService struct {
+	analogdb.PostService
+}
+
+func (ps *pagedPostService) FindPosts(ctx context.Context, filter *analogdb.PostFilter) ([]*analogdb.Post, int, error) {
+	var posts []*analogdb.Post
+	for i := 0; i < *filter.Limit; i++ {
+		posts = append(posts, &analogdb.Post{Id: i + 1, DisplayPost: analogdb.DisplayPost{Time: 100 - i}})
+	}
+	return posts, 100, nil
+}
+
+func TestAuthorNextPageURL(t *testing.T) {
+
+	s := &Server{AuthorService: &pagedAuthorService{}, PostService: &pagedPostService{}}
+	router := chi.NewRouter()
+	router.Get(authorsPath+"/{name}", s.getAuthor)
+
+	r := httptest.NewRequest(http.MethodGet, "/authors/test?sort=latest&page_size=2&grayscale=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if want, got := http.StatusOK, w.Code; got != want {
+		t.Fatalf("want status %d, got %d", want, got)
+	}
+	var resp AuthorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "/authors/test?sort=latest&page_size=2&page_id=99&grayscale=true", resp.Meta.PageURL; got != want {
+		t.Errorf("want next page url %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+)
+
+// cache policies sent to browsers and the CDN with each response
+const (
+	// never cache, the response differs for every request
+	cacheNever = "no-store"
+
+	// latest posts change whenever a post is scraped, keep briefly
+	// and let the CDN serve stale pages while it revalidates
+	cacheLatest = "public, max-age=60, stale-while-revalidate=300"
+
+	// lists that rarely change between requests
+	cacheList = "public, max-age=300, stale-while-revalidate=600"
+
+	// individual resources, only changed by an admin patch
+	cacheResource = "public, max-age=3600, stale-while-revalidate=86400"
+
+	// resized images, the same url always renders the same image
+	cacheImmutable = "public, max-age=31536000, immutable"
+)
+
+// setCacheControl sets the cache policy of a response
+func setCacheControl(w http.ResponseWriter, policy string) {
+	w.Header().Set("Cache-Control", policy)
+}
+
+// setLastModified sets when the content of a response last changed
+func setLastModified(w http.ResponseWriter, modified time.Time) {
+	if modified.IsZero() {
+		return
+	}
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+}
+
+// postsCachePolicy picks the cache policy for a list of posts
+func postsCachePolicy(filter *analogdb.PostFilter, seeded bool) string {
+	if filter.Sort == nil {
+		return cacheList
+	}
+	switch *filter.Sort {
+	case analogdb.SortRandom:
+		// without a seed, each request is shuffled differently
+		if !seeded {
+			return cacheNever
+		}
+		return cacheList
+	case analogdb.SortTime:
+		return cacheLatest
+	default:
+		return cacheList
+	}
+}
+
+// postsLastModified is the time of the most recent post in a list
+func postsLastModified(posts []analogdb.Post) time.Time {
+	var latest int
+	for _, p := range posts {
+		if p.Time > latest {
+			latest = p.Time
+		}
+	}
+	if latest == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(latest), 0)
+}
+
+// computeETag returns a strong etag of a response body
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+}
+
+// etagMatches reports if the etag of a response is in the
+// If-None-Match header, meaning the client already has it
+func etagMatches(r *http.Request, etag string) bool {
+	return matchedETag(r.Header.Get("If-None-Match"), etag) != ""
+}
+
+// matchedETag is the value of an If-None-Match header that matches
+// the etag of a response, as the client sent it, or empty if none does
+func matchedETag(header, etag string) string {
+	if header == "" {
+		return ""
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		// weak comparison is allowed for If-None-Match
+		candidate = strings.TrimPrefix(candidate, "W/")
+		// the same response, compressed
+		if decodedETag(candidate) == etag || candidate == "*" {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// encodedETag is the etag of a response after compressing it
+func encodedETag(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return fmt.Sprintf(`%s-%s"`, strings.TrimSuffix(etag, `"`), encoding)
+}
+
+// decodedETag is the etag of a response before it was compressed
+func decodedETag(etag string) string {
+	for _, encoding := range compressEncodings {
+		suffix := fmt.Sprintf(`-%s"`, encoding)
+		if strings.HasSuffix(etag, suffix) {
+			return strings.TrimSuffix(etag, suffix) + `"`
+		}
+	}
+	return etag
+}
+
+// cacheable reports if a response can be validated with an etag
+func cacheable(r *http.Request, status int) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return status == http.StatusOK
+}
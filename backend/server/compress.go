@@ -0,0 +1,209 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// responses smaller than this aren't worth compressing
+const compressMinSize = 1024
+
+// content encodings offered, in order of preference
+var compressEncodings = []string{"br", "zstd", "gzip"}
+
+// content types worth compressing; images are already compressed
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/x-ndjson",
+	"application/msgpack",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// compressor is implemented by each of the encoders
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoders are expensive to create, so they are reused across responses
+var compressorPools = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	"zstd": {New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return enc
+	}},
+	"gzip": {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+}
+
+// compress encodes responses with the encoding most preferred by Accept-Encoding
+func (s *Server) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		// upgraded connections are hijacked, head requests have no body,
+		// and ranges are of the uncompressed content
+		if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// only compress for clients that ask for it
+		accept := r.Header.Get("Accept-Encoding")
+		if accept == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		encoding := negotiate(accept, compressEncodings)
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, status: http.StatusOK, ifNoneMatch: r.Header.Get("If-None-Match")}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter buffers the start of a response to decide if it is
+// worth compressing, then streams the rest through the encoder
+type compressWriter struct {
+	http.ResponseWriter
+	encoding   string
+	status     int
+	buf        []byte
+	decided    bool
+	compressor compressor
+	// validators of the representations the client has
+	ifNoneMatch string
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		return
+	}
+	cw.status = status
+	// a not modified response stands for the representation the client
+	// has, which is only the compressed one if its etag says so
+	if status == http.StatusNotModified {
+		etag := cw.Header().Get("ETag")
+		if matched := matchedETag(cw.ifNoneMatch, etag); etag != "" && matched != "" && matched != "*" {
+			cw.Header().Set("ETag", matched)
+		}
+	}
+	// responses without a body are passed through
+	if status == http.StatusNotModified || status == http.StatusNoContent || status < http.StatusOK {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.decided {
+		if cw.compressor != nil {
+			return cw.compressor.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= compressMinSize {
+		if err := cw.start(cw.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends what has been written so far, so streamed responses
+// aren't held back until they reach the minimum size
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.start(cw.compressible())
+	}
+	if cw.compressor != nil {
+		cw.compressor.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Close() error {
+	// the whole response was smaller than the minimum
+	if !cw.decided {
+		if err := cw.start(false); err != nil {
+			return err
+		}
+	}
+	if cw.compressor == nil {
+		return nil
+	}
+	err := cw.compressor.Close()
+	cw.compressor.Reset(nil)
+	compressorPools[cw.encoding].Put(cw.compressor)
+	cw.compressor = nil
+	return err
+}
+
+// start writes the header and buffered body, compressing them if asked
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+
+	header := cw.Header()
+	if compress {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+		// each encoding is a different representation of the resource
+		if etag := header.Get("ETag"); etag != "" {
+			header.Set("ETag", encodedETag(etag, cw.encoding))
+		}
+		cw.compressor = compressorPools[cw.encoding].Get().(compressor)
+		cw.compressor.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.compressor != nil {
+		_, err := cw.compressor.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf)
+	}
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
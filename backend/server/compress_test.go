@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressETag(t *testing.T) {
+
+	body := strings.Repeat(`{"title":"compressible"}`, 100)
+	etag := computeETag([]byte(body))
+
+	s := &Server{}
+	handler := s.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if etagMatches(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+		wantETag    string
+	}{
+		{name: "compressed", wantStatus: http.StatusOK, wantETag: encodedETag(etag, "gzip")},
+		{name: "has compressed", ifNoneMatch: encodedETag(etag, "gzip"), wantStatus: http.StatusNotModified, wantETag: encodedETag(etag, "gzip")},
+		{name: "has uncompressed", ifNoneMatch: etag, wantStatus: http.StatusNotModified, wantETag: etag},
+		{name: "has weak", ifNoneMatch: "W/" + etag, wantStatus: http.StatusNotModified, wantETag: etag},
+		{name: "has any", ifNoneMatch: "*", wantStatus: http.StatusNotModified, wantETag: etag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/posts", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Code; got != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, got)
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("want etag %s, got %s", tt.wantETag, got)
+			}
+		})
+	}
+}
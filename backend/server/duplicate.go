@@ -0,0 +1,170 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/go-chi/chi/v5"
+)
+
+const duplicatesPath = "/duplicates"
+
+// default limit on number of clusters returned
+var defaultDuplicateLimit = 20
+
+type DuplicateClusterResponse struct {
+	Distance int             `json:"distance"`
+	Posts    []analogdb.Post `json:"posts"`
+}
+
+type DuplicatesMeta struct {
+	TotalClusters int    `json:"total_clusters"`
+	PageSize      int    `json:"page_size"`
+	PageID        int    `json:"next_page_id"`
+	PageURL       string `json:"next_page_url"`
+}
+
+type DuplicatesResponse struct {
+	Meta        DuplicatesMeta             `json:"meta"`
+	MaxDistance int                        `json:"max_distance"`
+	Clusters    []DuplicateClusterResponse `json:"clusters"`
+}
+
+func (resp DuplicatesResponse) nextPageURL() string { return resp.Meta.PageURL }
+
+func (s *Server) mountDuplicateHandlers() {
+	s.router.Route(duplicatesPath, func(r chi.Router) {
+		r.With(s.auth).Get("/", s.getDuplicates)
+	})
+}
+
+// getDuplicates lists clusters of posts with near identical images for moderation.
+// Posts are linked as duplicates when created, or by analogdb duplicates link,
+// so clusters can only be listed within the configured distance.
+func (s *Server) getDuplicates(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	filter, err := s.parseToDuplicateFilter(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	clusters, count, err := s.DuplicateService.FindDuplicateClusters(ctx, filter)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// fetch every post of every cluster at once
+	var ids []int
+	for _, cluster := range clusters {
+		ids = append(ids, cluster.PostIDs...)
+	}
+	posts := make(map[int]analogdb.Post, len(ids))
+	if len(ids) > 0 {
+		found, _, err := s.PostService.FindPosts(ctx, analogdb.NewPostFilterWithIDs(ids))
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		for _, post := range found {
+			posts[post.Id] = *post
+		}
+	}
+
+	response := DuplicatesResponse{
+		Meta:        setDuplicatesMeta(filter, clusters, count),
+		MaxDistance: filter.MaxDistance,
+		Clusters:    []DuplicateClusterResponse{},
+	}
+	for _, cluster := range clusters {
+		c := DuplicateClusterResponse{Distance: cluster.Distance}
+		for _, id := range cluster.PostIDs {
+			if post, ok := posts[id]; ok {
+				c.Posts = append(c.Posts, post)
+			}
+		}
+		// a post may be deleted between the queries
+		if len(c.Posts) > 1 {
+			response.Clusters = append(response.Clusters, c)
+		}
+	}
+
+	setCacheControl(w, cacheNever)
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+// parse URL for query parameters and convert to DuplicateFilter
+func (s *Server) parseToDuplicateFilter(r *http.Request) (*analogdb.DuplicateFilter, error) {
+
+	limit := defaultDuplicateLimit
+	filter := &analogdb.DuplicateFilter{MaxDistance: s.config.Duplicates.MaxDistance, Limit: &limit}
+
+	values := r.URL.Query()
+
+	// pairs are only linked up to the configured distance
+	if query := values.Get("distance"); query != "" {
+		distance, err := strconv.Atoi(query)
+		if err != nil || distance < 0 || distance > s.config.Duplicates.MaxDistance {
+			return nil, &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: fmt.Sprintf("distance must be a number between 0 and %d", s.config.Duplicates.MaxDistance)}
+		}
+		filter.MaxDistance = distance
+	}
+
+	if query := values.Get("page_size"); query != "" {
+		pageSize, err := strconv.Atoi(query)
+		if err != nil || pageSize <= 0 {
+			return nil, &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "page_size must be a positive number"}
+		}
+		// ensure limit is less than configured max
+		if pageSize > maxLimit {
+			pageSize = maxLimit
+		}
+		filter.Limit = &pageSize
+	}
+
+	if query := values.Get("page_id"); query != "" {
+		keyset, err := strconv.Atoi(query)
+		if err != nil {
+			return nil, &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "page_id must be a post id"}
+		}
+		filter.Keyset = &keyset
+	}
+
+	return filter, nil
+}
+
+// setDuplicatesMeta computes the metadata from a page of duplicate clusters
+func setDuplicatesMeta(filter *analogdb.DuplicateFilter, clusters []analogdb.DuplicateCluster, count int) DuplicatesMeta {
+
+	meta := DuplicatesMeta{TotalClusters: count}
+
+	if limit := filter.Limit; limit != nil {
+		meta.PageSize = *limit
+		if len(clusters) != *limit || len(clusters) == 0 {
+			// reached the end of pagination
+			return meta
+		}
+	}
+
+	// clusters are ordered by their newest post
+	last := clusters[len(clusters)-1]
+	meta.PageID = last.PostIDs[len(last.PostIDs)-1]
+
+	path := duplicatesPath
+	numParams := 0
+	path += fmt.Sprintf("%sdistance=%d", paramJoiner(&numParams), filter.MaxDistance)
+	if limit := filter.Limit; limit != nil {
+		path += fmt.Sprintf("%spage_size=%d", paramJoiner(&numParams), *limit)
+	}
+	path += fmt.Sprintf("%spage_id=%d", paramJoiner(&numParams), meta.PageID)
+	meta.PageURL = path
+
+	return meta
+}
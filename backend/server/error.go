@@ -13,6 +13,7 @@ var codes = map[string]int{
 	analogdb.ERRNOTFOUND:      http.StatusNotFound,
 	analogdb.ERRUNAVAILABLE:   http.StatusServiceUnavailable,
 	analogdb.ERRUNAUTHORIZED:  http.StatusUnauthorized,
+	analogdb.ERRCONFLICT:      http.StatusConflict,
 }
 
 func errorStatusCode(code string) int {
@@ -0,0 +1,330 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/go-chi/chi/v5"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	exportPath = "/export"
+
+	// how many posts are written between flushes to the client
+	exportFlushInterval = 200
+
+	// size of a parquet row group, which is buffered until full
+	parquetRowGroupSize = 4 * 1024 * 1024
+)
+
+func (s *Server) mountExportHandlers() {
+	s.router.Route(exportPath, func(r chi.Router) {
+		r.Get("/", s.exportPosts)
+	})
+}
+
+// postExporter writes a stream of posts in a single format
+type postExporter interface {
+	write(post *analogdb.Post) error
+	close() error
+}
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	new         func(w io.Writer) (postExporter, error)
+}
+
+var exportFormats = map[string]exportFormat{
+	"ndjson":  {contentType: "application/x-ndjson; charset=UTF-8", extension: "ndjson", new: newNDJSONExporter},
+	"csv":     {contentType: "text/csv; charset=UTF-8", extension: "csv", new: newCSVExporter},
+	"parquet": {contentType: "application/vnd.apache.parquet", extension: "parquet", new: newParquetExporter},
+}
+
+// exportPosts streams every post matching the filter. Posts are written in
+// order of id, so an interrupted export is resumed by passing the id of the
+// last post received as the cursor. Ndjson and csv exports hold CreatePost
+// records, so they can be loaded back with analogdb import.
+func (s *Server) exportPosts(w http.ResponseWriter, r *http.Request) {
+
+	filter, err := parseToFilter(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	values := r.URL.Query()
+
+	name := values.Get("format")
+	if name == "" {
+		name = "ndjson"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		err := &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: fmt.Sprintf("invalid format parameter %s, valid options are 'ndjson', 'csv', 'parquet'", name)}
+		s.writeError(w, r, err)
+		return
+	}
+
+	var after int
+	if cursor := values.Get("cursor"); cursor != "" {
+		if after, err = stringToInt(cursor); err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+	}
+
+	var exporter postExporter
+	written := 0
+
+	// headers are only sent once the first post is found,
+	// so errors before then are still returned as errors
+	start := func() error {
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="analogdb.%s"`, format.extension))
+		setCacheControl(w, cacheNever)
+		w.WriteHeader(http.StatusOK)
+		exporter, err = format.new(w)
+		return err
+	}
+
+	err = s.PostService.ExportPosts(r.Context(), filter, after, func(post *analogdb.Post) error {
+		if exporter == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := exporter.write(post); err != nil {
+			return err
+		}
+		written += 1
+		if written%exportFlushInterval == 0 {
+			flush(w)
+		}
+		return nil
+	})
+
+	if err != nil {
+		if exporter == nil {
+			s.writeError(w, r, err)
+			return
+		}
+		// too late to send an error, the client sees a truncated export
+		s.logger.Error().Err(err).Ctx(r.Context()).Int("written", written).Msg("Failed to export posts")
+		return
+	}
+
+	// nothing matched, still send a valid empty export
+	if exporter == nil {
+		if err := start(); err != nil {
+			s.logger.Error().Err(err).Ctx(r.Context()).Msg("Failed to export posts")
+			return
+		}
+	}
+	if err := exporter.close(); err != nil {
+		s.logger.Error().Err(err).Ctx(r.Context()).Msg("Failed to finish export")
+	}
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// exportPost is a post as a CreatePost record, keeping its id
+// so an export can be resumed from the last post received
+type exportPost struct {
+	Id int `json:"id"`
+	analogdb.CreatePost
+}
+
+func newExportPost(post *analogdb.Post) exportPost {
+	grayscale, sprocket := post.Grayscale, post.Sprocket
+	return exportPost{
+		Id: post.Id,
+		CreatePost: analogdb.CreatePost{
+			Title:     post.Title,
+			Author:    post.Author,
+			Permalink: post.Permalink,
+			Score:     post.Score,
+			Nsfw:      post.Nsfw,
+			Grayscale: &grayscale,
+			Time:      post.Time,
+			Sprocket:  &sprocket,
+			Images:    post.Images,
+			Colors:    post.Colors,
+			Keywords:  post.Keywords,
+		},
+	}
+}
+
+// csv columns are named after the json fields of a CreatePost,
+// with images, colors and keywords held as json arrays
+var exportCSVHeader = []string{"id", "title", "author", "permalink", "upvotes", "nsfw", "grayscale", "unix_time", "sprocket", "images", "colors", "keywords"}
+
+func (p exportPost) record() ([]string, error) {
+	images, err := json.Marshal(p.Images)
+	if err != nil {
+		return nil, err
+	}
+	colors, err := json.Marshal(p.Colors)
+	if err != nil {
+		return nil, err
+	}
+	keywords, err := json.Marshal(p.Keywords)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		strconv.Itoa(p.Id),
+		p.Title,
+		p.Author,
+		p.Permalink,
+		strconv.Itoa(p.Score),
+		strconv.FormatBool(p.Nsfw),
+		strconv.FormatBool(*p.Grayscale),
+		strconv.Itoa(p.Time),
+		strconv.FormatBool(*p.Sprocket),
+		string(images),
+		string(colors),
+		string(keywords),
+	}, nil
+}
+
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONExporter(w io.Writer) (postExporter, error) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &ndjsonExporter{enc: enc}, nil
+}
+
+func (e *ndjsonExporter) write(post *analogdb.Post) error {
+	return e.enc.Encode(newExportPost(post))
+}
+
+func (e *ndjsonExporter) close() error {
+	return nil
+}
+
+type csvExporter struct {
+	w       *csv.Writer
+	written int
+}
+
+func newCSVExporter(w io.Writer) (postExporter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportCSVHeader); err != nil {
+		return nil, err
+	}
+	return &csvExporter{w: cw}, nil
+}
+
+func (e *csvExporter) write(post *analogdb.Post) error {
+	record, err := newExportPost(post).record()
+	if err != nil {
+		return err
+	}
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+	// the csv writer buffers, flush along with the response
+	e.written += 1
+	if e.written%exportFlushInterval == 0 {
+		e.w.Flush()
+	}
+	return e.w.Error()
+}
+
+func (e *csvExporter) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// parquet schema of an exported post
+type parquetPost struct {
+	Id        int64            `parquet:"name=id, type=INT64"`
+	Title     string           `parquet:"name=title, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Author    string           `parquet:"name=author, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Permalink string           `parquet:"name=permalink, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Score     int64            `parquet:"name=score, type=INT64"`
+	Nsfw      bool             `parquet:"name=nsfw, type=BOOLEAN"`
+	Grayscale bool             `parquet:"name=grayscale, type=BOOLEAN"`
+	Timestamp int64            `parquet:"name=timestamp, type=INT64"`
+	Sprocket  bool             `parquet:"name=sprocket, type=BOOLEAN"`
+	Images    []parquetImage   `parquet:"name=images, type=LIST"`
+	Colors    []parquetColor   `parquet:"name=colors, type=LIST"`
+	Keywords  []parquetKeyword `parquet:"name=keywords, type=LIST"`
+}
+
+type parquetImage struct {
+	Resolution string `parquet:"name=resolution, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Url        string `parquet:"name=url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Width      int64  `parquet:"name=width, type=INT64"`
+	Height     int64  `parquet:"name=height, type=INT64"`
+}
+
+type parquetColor struct {
+	Hex     string  `parquet:"name=hex, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Css     string  `parquet:"name=css, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Html    string  `parquet:"name=html, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Percent float64 `parquet:"name=percent, type=DOUBLE"`
+}
+
+type parquetKeyword struct {
+	Word   string  `parquet:"name=word, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Weight float64 `parquet:"name=weight, type=DOUBLE"`
+}
+
+type parquetExporter struct {
+	w *writer.ParquetWriter
+}
+
+func newParquetExporter(w io.Writer) (postExporter, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(parquetPost), 1)
+	if err != nil {
+		return nil, err
+	}
+	// row groups are buffered in memory until full
+	pw.RowGroupSize = parquetRowGroupSize
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	return &parquetExporter{w: pw}, nil
+}
+
+func (e *parquetExporter) write(post *analogdb.Post) error {
+	row := parquetPost{
+		Id:        int64(post.Id),
+		Title:     post.Title,
+		Author:    post.Author,
+		Permalink: post.Permalink,
+		Score:     int64(post.Score),
+		Nsfw:      post.Nsfw,
+		Grayscale: post.Grayscale,
+		Timestamp: int64(post.Time),
+		Sprocket:  post.Sprocket,
+	}
+	for _, image := range post.Images {
+		row.Images = append(row.Images, parquetImage{Resolution: image.Label, Url: image.Url, Width: int64(image.Width), Height: int64(image.Height)})
+	}
+	for _, color := range post.Colors {
+		row.Colors = append(row.Colors, parquetColor{Hex: color.Hex, Css: color.Css, Html: color.Html, Percent: color.Percent})
+	}
+	for _, keyword := range post.Keywords {
+		row.Keywords = append(row.Keywords, parquetKeyword{Word: keyword.Word, Weight: keyword.Weight})
+	}
+	return e.w.Write(row)
+}
+
+func (e *parquetExporter) close() error {
+	return e.w.WriteStop()
+}
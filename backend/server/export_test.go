@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/evanofslack/analogdb"
+)
+
+func TestExportFormats(t *testing.T) {
+	post := &analogdb.Post{Id: 7, DisplayPost: analogdb.DisplayPost{
+		Title:     "Tri-X at dusk",
+		Author:    "u/test",
+		Permalink: "https://reddit.com/r/analog/1",
+		Score:     42,
+		Grayscale: true,
+		Time:      1700000000,
+		Images:    []analogdb.Image{{Label: "raw", Url: "https://i.redd.it/1.jpg", Width: 10, Height: 20}},
+		Keywords:  []analogdb.Keyword{{Word: "dusk", Weight: 0.5}},
+	}}
+
+	t.Run("Ndjson is CreatePost", func(t *testing.T) {
+		var buf bytes.Buffer
+		exporter, _ := newNDJSONExporter(&buf)
+		if err := exporter.write(post); err != nil {
+			t.Fatal(err)
+		}
+
+		var created analogdb.CreatePost
+		if err := json.Unmarshal(buf.Bytes(), &created); err != nil {
+			t.Fatal(err)
+		}
+		if created.Score != post.Score || created.Time != post.Time {
+			t.Errorf("score and time must round trip, got %d and %d", created.Score, created.Time)
+		}
+		if created.Grayscale == nil || !*created.Grayscale {
+			t.Error("grayscale must round trip")
+		}
+		if len(created.Images) != 1 || created.Images[0].Url != post.Images[0].Url {
+			t.Error("images must round trip")
+		}
+	})
+
+	t.Run("Csv columns are CreatePost fields", func(t *testing.T) {
+		var buf bytes.Buffer
+		exporter, _ := newCSVExporter(&buf)
+		if err := exporter.write(post); err != nil {
+			t.Fatal(err)
+		}
+		if err := exporter.close(); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns := make(map[string]string)
+		for i, name := range rows[0] {
+			columns[name] = rows[1][i]
+		}
+		if got, want := columns["upvotes"], "42"; got != want {
+			t.Errorf("wrong upvotes, want %s, got %s", want, got)
+		}
+		if got, want := columns["unix_time"], "1700000000"; got != want {
+			t.Errorf("wrong unix_time, want %s, got %s", want, got)
+		}
+		var keywords []analogdb.Keyword
+		if err := json.Unmarshal([]byte(columns["keywords"]), &keywords); err != nil {
+			t.Fatalf("keywords must be a json array: %v", err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	filmsPath   = "/films"
+	camerasPath = "/cameras"
+	gearPath    = "/gear"
+)
+
+type FilmsResponse struct {
+	Films []analogdb.GearSummary `json:"films"`
+}
+
+type CamerasResponse struct {
+	Cameras []analogdb.GearSummary `json:"cameras"`
+}
+
+type ExtractGearResponse struct {
+	Message string                     `json:"message"`
+	Result  analogdb.ExtractGearResult `json:"result"`
+}
+
+func (s *Server) mountGearHandlers() {
+	s.router.Route(filmsPath, func(r chi.Router) {
+		r.Get("/", s.getFilms)
+	})
+	s.router.Route(camerasPath, func(r chi.Router) {
+		r.Get("/", s.getCameras)
+	})
+	s.router.Route(gearPath, func(r chi.Router) {
+		r.With(s.auth).Put("/extract", s.extractGear)
+	})
+}
+
+func (s *Server) getFilms(w http.ResponseWriter, r *http.Request) {
+	films, err := s.GearService.FindFilms(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := FilmsResponse{Films: *films}
+	setCacheControl(w, cacheList)
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) getCameras(w http.ResponseWriter, r *http.Request) {
+	cameras, err := s.GearService.FindCameras(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := CamerasResponse{Cameras: *cameras}
+	setCacheControl(w, cacheList)
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+// extractGear re-extracts film stocks and cameras from all post titles
+func (s *Server) extractGear(w http.ResponseWriter, r *http.Request) {
+	result, err := s.GearService.ExtractGear(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := ExtractGearResponse{
+		Message: "success, gear extracted",
+		Result:  *result,
+	}
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
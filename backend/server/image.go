@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/imaging"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	imagePath = "/image"
+
+	// source images are slow to download, but not that slow
+	imageFetchTimeout = 30 * time.Second
+)
+
+// imageProxy serves resized renditions of post images
+type imageProxy struct {
+	// nil if the disk cache couldn't be opened
+	cache *imaging.DiskCache
+	// sizes that may be requested, anything else could fill the cache
+	sizes map[string]bool
+	// concurrent requests for the same rendition are rendered once
+	group singleflight.Group
+}
+
+func (s *Server) mountImageHandlers() {
+
+	proxy := &imageProxy{
+		sizes: make(map[string]bool),
+	}
+	for _, size := range s.config.Image.Sizes {
+		proxy.sizes[size] = true
+	}
+	if dir := s.config.Image.CacheDir; dir != "" {
+		cache, err := imaging.NewDiskCache(dir, s.config.Image.CacheSizeMB<<20)
+		if err != nil {
+			s.logger.Error().Err(err).Str("dir", dir).Msg("Failed to open image cache, serving images uncached")
+		}
+		proxy.cache = cache
+	}
+	s.images = proxy
+
+	s.router.Route(imagePath, func(r chi.Router) {
+		r.Get("/{id}/{size}", s.getImage)
+	})
+}
+
+func (s *Server) getImage(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		err = &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "invalid post id"}
+		s.writeError(w, r, err)
+		return
+	}
+
+	// {w}x{h}.{fmt}
+	size, ext, ok := strings.Cut(chi.URLParam(r, "size"), ".")
+	if !ok || !s.images.sizes[size] {
+		err = &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: fmt.Sprintf("image size must be one of %s", s.allowedImageSizes())}
+		s.writeError(w, r, err)
+		return
+	}
+	width, height, err := imaging.ParseSize(size)
+	if err != nil {
+		s.writeError(w, r, &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: err.Error()})
+		return
+	}
+	format, err := imaging.ParseFormat(ext)
+	if err != nil {
+		s.writeError(w, r, &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: err.Error()})
+		return
+	}
+
+	key := fmt.Sprintf("%d/%s.%s", id, size, format)
+	data, err := s.renderImage(ctx, key, id, width, height, format)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// renditions of a post never change, only their post can be deleted
+	etag := computeETag(data)
+	w.Header().Set("ETag", etag)
+	setCacheControl(w, cacheImmutable)
+	if etagMatches(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", imaging.ContentType(format))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+// renderImage returns a rendition from the disk cache, or renders and caches it
+func (s *Server) renderImage(ctx context.Context, key string, id, width, height int, format string) ([]byte, error) {
+
+	proxy := s.images
+	if proxy.cache != nil {
+		if data, ok := proxy.cache.Get(key); ok {
+			return data, nil
+		}
+	}
+
+	ch := proxy.group.DoChan(key, func() (any, error) {
+
+		// create a new context; the request that started the render
+		// may be canceled before others waiting on the rendition
+		ctx, cancel := context.WithTimeout(context.Background(), imageFetchTimeout)
+		defer cancel()
+
+		post, err := s.PostService.FindPostByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		source, ok := sourceImage(post.Images, width, height)
+		if !ok {
+			return nil, &analogdb.Error{Code: analogdb.ERRNOTFOUND, Message: "post has no images"}
+		}
+		data, err := s.Downloader.Download(ctx, source.Url)
+		if err != nil {
+			s.logger.Error().Err(err).Ctx(ctx).Int("postID", id).Msg("Failed to fetch source image")
+			return nil, &analogdb.Error{Code: analogdb.ERRUNAVAILABLE, Message: "failed to fetch source image"}
+		}
+
+		src, err := imaging.DecodeBytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode source image %s: %w", source.Url, err)
+		}
+
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, imaging.Resize(src, width, height), format); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+
+		if proxy.cache != nil {
+			if err := proxy.cache.Set(key, data); err != nil {
+				s.logger.Warn().Err(err).Ctx(ctx).Str("key", key).Msg("Failed to cache image")
+			}
+		}
+		s.logger.Debug().Ctx(ctx).Int("postID", id).Str("key", key).Int("bytes", len(data)).Msg("Rendered image")
+		return data, nil
+	})
+
+	// a canceled request stops waiting, the render carries on for the others
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	}
+}
+
+// sourceImage picks the smallest image at least as large as the requested
+// size, so scaling down keeps detail. Images are ordered smallest first.
+func sourceImage(images []analogdb.Image, width, height int) (analogdb.Image, bool) {
+	for _, img := range images {
+		if img.Width >= width && img.Height >= height {
+			return img, true
+		}
+	}
+	if raw, ok := analogdb.FindImage(images, analogdb.ImageRaw); ok {
+		return raw, true
+	}
+	if len(images) == 0 {
+		return analogdb.Image{}, false
+	}
+	return images[len(images)-1], true
+}
+
+func (s *Server) allowedImageSizes() string {
+	return strings.Join(s.config.Image.Sizes, ", ")
+}
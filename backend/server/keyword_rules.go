@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/go-chi/chi/v5"
+)
+
+const keywordRulesPath = "/rules"
+
+type KeywordSynonymsResponse struct {
+	Synonyms []analogdb.KeywordSynonym `json:"synonyms"`
+}
+
+type KeywordBlacklistResponse struct {
+	Blacklist []string `json:"blacklist"`
+}
+
+type blacklistKeywordRequest struct {
+	Word string `json:"word"`
+}
+
+type RenormalizeResponse struct {
+	Message string                     `json:"message"`
+	Result  analogdb.RenormalizeResult `json:"result"`
+}
+
+type KeywordRuleResponse struct {
+	Message string `json:"message"`
+}
+
+// mountKeywordRuleHandlers mounts admin routes for managing
+// keyword synonyms and the keyword blacklist
+func (s *Server) mountKeywordRuleHandlers(r chi.Router) {
+	r.With(s.auth).Route(keywordRulesPath, func(r chi.Router) {
+		r.Get("/synonyms", s.getKeywordSynonyms)
+		r.Put("/synonyms", s.createKeywordSynonym)
+		r.Delete("/synonyms/{synonym}", s.deleteKeywordSynonym)
+		r.Get("/blacklist", s.getBlacklistedKeywords)
+		r.Put("/blacklist", s.createBlacklistedKeyword)
+		r.Delete("/blacklist/{word}", s.deleteBlacklistedKeyword)
+		r.Put("/normalize", s.renormalizeKeywords)
+	})
+}
+
+func (s *Server) getKeywordSynonyms(w http.ResponseWriter, r *http.Request) {
+	synonyms, err := s.KeywordService.FindKeywordSynonyms(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := KeywordSynonymsResponse{Synonyms: *synonyms}
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) createKeywordSynonym(w http.ResponseWriter, r *http.Request) {
+	var synonym analogdb.KeywordSynonym
+	if err := json.NewDecoder(r.Body).Decode(&synonym); err != nil {
+		err = &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "error parsing synonym from request body"}
+		s.writeError(w, r, err)
+		return
+	}
+	if err := s.KeywordService.CreateKeywordSynonym(r.Context(), &synonym); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := KeywordRuleResponse{Message: "success, synonym created"}
+	if err := encodeResponse(w, r, http.StatusCreated, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) deleteKeywordSynonym(w http.ResponseWriter, r *http.Request) {
+	synonym, err := wordParam(r, "synonym")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if err := s.KeywordService.DeleteKeywordSynonym(r.Context(), synonym); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := KeywordRuleResponse{Message: "success, synonym deleted"}
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) getBlacklistedKeywords(w http.ResponseWriter, r *http.Request) {
+	blacklist, err := s.KeywordService.FindBlacklistedKeywords(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := KeywordBlacklistResponse{Blacklist: *blacklist}
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) createBlacklistedKeyword(w http.ResponseWriter, r *http.Request) {
+	var request blacklistKeywordRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		err = &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "error parsing word from request body"}
+		s.writeError(w, r, err)
+		return
+	}
+	if err := s.KeywordService.CreateBlacklistedKeyword(r.Context(), request.Word); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := KeywordRuleResponse{Message: "success, keyword blacklisted"}
+	if err := encodeResponse(w, r, http.StatusCreated, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) deleteBlacklistedKeyword(w http.ResponseWriter, r *http.Request) {
+	word, err := wordParam(r, "word")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if err := s.KeywordService.DeleteBlacklistedKeyword(r.Context(), word); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := KeywordRuleResponse{Message: "success, keyword removed from blacklist"}
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+// renormalizeKeywords applies the current keyword rules to all stored keywords
+func (s *Server) renormalizeKeywords(w http.ResponseWriter, r *http.Request) {
+	result, err := s.KeywordService.RenormalizeKeywords(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := RenormalizeResponse{
+		Message: "success, keywords renormalized",
+		Result:  *result,
+	}
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+// wordParam is the keyword in the URL parameter name. chi matches
+// the escaped path, so a word such as b/w arrives as b%2Fw.
+func wordParam(r *http.Request, name string) (string, error) {
+	word, err := url.PathUnescape(chi.URLParam(r, name))
+	if err != nil || word == "" {
+		return "", &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "Must provide keyword as parameter"}
+	}
+	return word, nil
+}
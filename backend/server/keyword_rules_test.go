@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/go-chi/chi/v5"
+)
+
+// deletedKeywordService records the words deleted from the keyword rules
+type deletedKeywordService struct {
+	analogdb.KeywordService
+	deleted []string
+}
+
+func (ks *deletedKeywordService) DeleteKeywordSynonym(ctx context.Context, synonym string) error {
+	ks.deleted = append(ks.deleted, synonym)
+	return nil
+}
+
+func (ks *deletedKeywordService) DeleteBlacklistedKeyword(ctx context.Context, word string) error {
+	ks.deleted = append(ks.deleted, word)
+	return nil
+}
+
+func TestDeleteKeywordRules(t *testing.T) {
+
+	ks := &deletedKeywordService{}
+	s := &Server{KeywordService: ks}
+	router := chi.NewRouter()
+	router.Delete("/synonyms/{synonym}", s.deleteKeywordSynonym)
+	router.Delete("/blacklist/{word}", s.deleteBlacklistedKeyword)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/synonyms/b%2Fw", want: "b/w"},
+		{path: "/synonyms/b%26w", want: "b&w"},
+		{path: "/blacklist/35mm%20film", want: "35mm film"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodDelete, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if want, got := http.StatusOK, w.Code; got != want {
+			t.Fatalf("%s: want status %d, got %d", tt.path, want, got)
+		}
+		if got := ks.deleted[len(ks.deleted)-1]; got != tt.want {
+			t.Errorf("%s: want %q deleted, got %q", tt.path, tt.want, got)
+		}
+	}
+}
@@ -10,15 +10,30 @@ import (
 const (
 	keywordsPath        = "/keywords"
 	defaultKeywordLimit = 50
+	defaultRelatedLimit = 20
+	maxKeywordLimit     = 200
 )
 
 type KeywordsResponse struct {
 	Keywords []analogdb.KeywordSummary `json:"keywords"`
 }
 
+type KeywordResponse struct {
+	Keyword analogdb.KeywordDetail `json:"keyword"`
+}
+
+type RelatedKeywordsResponse struct {
+	Word    string                    `json:"word"`
+	Related []analogdb.RelatedKeyword `json:"related"`
+}
+
 func (s *Server) mountKeywordHandlers() {
 	s.router.Route(keywordsPath, func(r chi.Router) {
+		r.Get("/", s.getKeywords)
 		r.Get("/summary", s.getSummary)
+		r.Get("/{word}", s.getKeyword)
+		r.Get("/{word}/related", s.getRelatedKeywords)
+		s.mountKeywordRuleHandlers(r)
 	})
 }
 
@@ -41,7 +56,103 @@ func (s *Server) getSummary(w http.ResponseWriter, r *http.Request) {
 	response := KeywordsResponse{
 		Keywords: *keywords,
 	}
+	setCacheControl(w, cacheList)
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) getKeywords(w http.ResponseWriter, r *http.Request) {
+
+	limit, err := parseKeywordLimit(r, defaultKeywordLimit)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	filter := &analogdb.KeywordFilter{Limit: &limit}
+	if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+		filter.Prefix = &prefix
+	}
+
+	keywords, err := s.KeywordService.FindKeywords(r.Context(), filter)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := KeywordsResponse{
+		Keywords: *keywords,
+	}
+	setCacheControl(w, cacheList)
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) getKeyword(w http.ResponseWriter, r *http.Request) {
+
+	word, err := wordParam(r, "word")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	keyword, err := s.KeywordService.FindKeywordByWord(r.Context(), word)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := KeywordResponse{
+		Keyword: *keyword,
+	}
+	setCacheControl(w, cacheList)
+	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) getRelatedKeywords(w http.ResponseWriter, r *http.Request) {
+
+	word, err := wordParam(r, "word")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	limit, err := parseKeywordLimit(r, defaultRelatedLimit)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	related, err := s.KeywordService.FindRelatedKeywords(r.Context(), word, limit)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	response := RelatedKeywordsResponse{
+		Word:    word,
+		Related: *related,
+	}
+	setCacheControl(w, cacheList)
 	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
 		s.writeError(w, r, err)
 	}
 }
+
+// parseKeywordLimit gets the page size from the query,
+// ensuring it is less than the configured max
+func parseKeywordLimit(r *http.Request, defaultLimit int) (int, error) {
+	strLimit := r.URL.Query().Get("page_size")
+	if strLimit == "" {
+		return defaultLimit, nil
+	}
+	limit, err := stringToInt(strLimit)
+	if err != nil {
+		return 0, err
+	}
+	if limit > maxKeywordLimit {
+		limit = maxKeywordLimit
+	}
+	return limit, nil
+}
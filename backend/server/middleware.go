@@ -37,12 +37,15 @@ func (s *Server) mountMiddleware() {
 	corsHandler := cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*", "http://localhost"},
 		AllowedMethods:   []string{"GET", "DELETE", "PUT", "POST", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           500,
 	})
 
 	// CORS
 	s.router.Use(corsHandler)
+
+	// compress responses
+	s.router.Use(s.compress)
 }
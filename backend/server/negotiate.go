@@ -0,0 +1,327 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	mediaJSON    = "application/json"
+	mediaNDJSON  = "application/x-ndjson"
+	mediaMsgpack = "application/msgpack"
+	mediaCSV     = "text/csv"
+)
+
+// responseFormat is a media type a response can be encoded as
+type responseFormat struct {
+	contentType string
+	encode      func(v any) ([]byte, error)
+}
+
+var responseFormats = map[string]responseFormat{
+	mediaJSON:    {contentType: "application/json; charset=UTF-8", encode: encodeJSON},
+	mediaNDJSON:  {contentType: "application/x-ndjson; charset=UTF-8", encode: encodeNDJSON},
+	mediaMsgpack: {contentType: "application/msgpack", encode: encodeMsgpack},
+	mediaCSV:     {contentType: "text/csv; charset=UTF-8", encode: encodeCSV},
+}
+
+// media types offered for every response and for lists, in order of preference
+var (
+	offerAny  = []string{mediaJSON, mediaMsgpack}
+	offerList = []string{mediaJSON, mediaNDJSON, mediaMsgpack, mediaCSV}
+)
+
+// listResponse is implemented by responses holding a list of items,
+// which can also be encoded one item per line as ndjson or csv
+type listResponse interface {
+	items() []any
+	csvHeader() []string
+	csvRecords() [][]string
+}
+
+// pagedResponse is implemented by responses with a next page,
+// linked in a header since ndjson and csv have nowhere else to put it
+type pagedResponse interface {
+	nextPageURL() string
+}
+
+// negotiateFormat picks the format of a response from the Accept header,
+// defaulting to json if the client accepts none of the offered formats
+func negotiateFormat(accept string, v any) responseFormat {
+	offers := offerAny
+	if _, ok := v.(listResponse); ok {
+		offers = offerList
+	}
+	if media := negotiate(accept, offers); media != "" {
+		return responseFormats[media]
+	}
+	return responseFormats[mediaJSON]
+}
+
+// negotiate picks the offer most preferred by an Accept style header.
+// Ties are broken by the order of offers. Returns "" if none are acceptable.
+func negotiate(header string, offers []string) string {
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	ranges := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, rng := range ranges {
+			if s := matchRange(rng.value, offer); s > specificity {
+				q, specificity = rng.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+type acceptRange struct {
+	value string
+	q     float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		ranges = append(ranges, acceptRange{value: value, q: q})
+	}
+	return ranges
+}
+
+// matchRange returns how specifically a range matches an offer, or -1 if it doesn't
+func matchRange(rng, offer string) int {
+	switch {
+	case rng == offer:
+		return 2
+	case rng == "*" || rng == "*/*":
+		return 0
+	case strings.HasSuffix(rng, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(rng, "*")):
+		return 1
+	default:
+		return -1
+	}
+}
+
+func encodeJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeNDJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, item := range v.(listResponse).items() {
+		if err := enc.Encode(item); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeMsgpack(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	// keep the same field names as json
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeCSV(v any) ([]byte, error) {
+	list := v.(listResponse)
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(list.csvHeader()); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(list.csvRecords()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var postCSVHeader = []string{"id", "title", "author", "permalink", "score", "nsfw", "grayscale", "timestamp", "sprocket", "url", "width", "height", "colors", "keywords"}
+
+func postItems(posts []analogdb.Post) []any {
+	items := make([]any, len(posts))
+	for i := range posts {
+		items[i] = posts[i]
+	}
+	return items
+}
+
+func postRecords(posts []analogdb.Post) [][]string {
+	records := make([][]string, 0, len(posts))
+	for i := range posts {
+		records = append(records, postRecord(&posts[i]))
+	}
+	return records
+}
+
+// postRecord flattens a post into a csv record. Lists of colors
+// and keywords are joined into a single column each.
+func postRecord(p *analogdb.Post) []string {
+	// the original image
+	var url, width, height string
+	if image, ok := analogdb.FindImage(p.Images, analogdb.ImageRaw); ok {
+		url, width, height = image.Url, strconv.Itoa(image.Width), strconv.Itoa(image.Height)
+	}
+	colors := make([]string, 0, len(p.Colors))
+	for _, c := range p.Colors {
+		colors = append(colors, c.Hex)
+	}
+	keywords := make([]string, 0, len(p.Keywords))
+	for _, k := range p.Keywords {
+		keywords = append(keywords, k.Word)
+	}
+	return []string{
+		strconv.Itoa(p.Id),
+		p.Title,
+		p.Author,
+		p.Permalink,
+		strconv.Itoa(p.Score),
+		strconv.FormatBool(p.Nsfw),
+		strconv.FormatBool(p.Grayscale),
+		strconv.Itoa(p.Time),
+		strconv.FormatBool(p.Sprocket),
+		url,
+		width,
+		height,
+		strings.Join(colors, ";"),
+		strings.Join(keywords, ";"),
+	}
+}
+
+func gearItems(gear []analogdb.GearSummary) []any {
+	items := make([]any, len(gear))
+	for i := range gear {
+		items[i] = gear[i]
+	}
+	return items
+}
+
+func gearRecords(gear []analogdb.GearSummary) [][]string {
+	records := make([][]string, 0, len(gear))
+	for _, g := range gear {
+		records = append(records, []string{g.Name, strconv.Itoa(g.Count)})
+	}
+	return records
+}
+
+func (resp PostResponse) items() []any           { return postItems(resp.Posts) }
+func (resp PostResponse) csvHeader() []string    { return postCSVHeader }
+func (resp PostResponse) csvRecords() [][]string { return postRecords(resp.Posts) }
+func (resp PostResponse) nextPageURL() string    { return resp.Meta.PageURL }
+
+func (resp SimilarPostsResponse) items() []any           { return postItems(resp.Posts) }
+func (resp SimilarPostsResponse) csvHeader() []string    { return postCSVHeader }
+func (resp SimilarPostsResponse) csvRecords() [][]string { return postRecords(resp.Posts) }
+
+func (resp IDsResponse) items() []any {
+	items := make([]any, len(resp.Ids))
+	for i, id := range resp.Ids {
+		items[i] = id
+	}
+	return items
+}
+func (resp IDsResponse) csvHeader() []string { return []string{"id"} }
+func (resp IDsResponse) csvRecords() [][]string {
+	records := make([][]string, 0, len(resp.Ids))
+	for _, id := range resp.Ids {
+		records = append(records, []string{strconv.Itoa(id)})
+	}
+	return records
+}
+
+func (resp AuthorsResponse) items() []any {
+	items := make([]any, len(resp.Authors))
+	for i, author := range resp.Authors {
+		items[i] = author
+	}
+	return items
+}
+func (resp AuthorsResponse) csvHeader() []string { return []string{"name"} }
+func (resp AuthorsResponse) csvRecords() [][]string {
+	records := make([][]string, 0, len(resp.Authors))
+	for _, author := range resp.Authors {
+		records = append(records, []string{author})
+	}
+	return records
+}
+
+func (resp AuthorSummariesResponse) items() []any {
+	items := make([]any, len(resp.Authors))
+	for i := range resp.Authors {
+		items[i] = resp.Authors[i]
+	}
+	return items
+}
+func (resp AuthorSummariesResponse) csvHeader() []string {
+	return []string{"name", "post_count", "total_score"}
+}
+func (resp AuthorSummariesResponse) csvRecords() [][]string {
+	records := make([][]string, 0, len(resp.Authors))
+	for _, a := range resp.Authors {
+		records = append(records, []string{a.Name, strconv.Itoa(a.PostCount), strconv.Itoa(a.TotalScore)})
+	}
+	return records
+}
+func (resp AuthorSummariesResponse) nextPageURL() string { return resp.Meta.PageURL }
+
+func (resp KeywordsResponse) items() []any {
+	items := make([]any, len(resp.Keywords))
+	for i := range resp.Keywords {
+		items[i] = resp.Keywords[i]
+	}
+	return items
+}
+func (resp KeywordsResponse) csvHeader() []string { return []string{"word", "count"} }
+func (resp KeywordsResponse) csvRecords() [][]string {
+	records := make([][]string, 0, len(resp.Keywords))
+	for _, k := range resp.Keywords {
+		records = append(records, []string{k.Word, strconv.Itoa(k.Count)})
+	}
+	return records
+}
+
+func (resp FilmsResponse) items() []any           { return gearItems(resp.Films) }
+func (resp FilmsResponse) csvHeader() []string    { return []string{"film", "count"} }
+func (resp FilmsResponse) csvRecords() [][]string { return gearRecords(resp.Films) }
+
+func (resp CamerasResponse) items() []any           { return gearItems(resp.Cameras) }
+func (resp CamerasResponse) csvHeader() []string    { return []string{"camera", "count"} }
+func (resp CamerasResponse) csvRecords() [][]string { return gearRecords(resp.Cameras) }
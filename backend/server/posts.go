@@ -85,11 +85,15 @@ func (s *Server) getPosts(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, r, err)
 		return
 	}
+	// finding random posts sets a seed if one wasn't requested
+	seeded := filter.Seed != nil
 	resp, err := s.makePostResponse(r, filter)
 	if err != nil {
 		s.writeError(w, r, err)
 		return
 	}
+	setCacheControl(w, postsCachePolicy(filter, seeded))
+	setLastModified(w, postsLastModified(resp.Posts))
 	err = encodeResponse(w, r, http.StatusOK, resp)
 	if err != nil {
 		s.writeError(w, r, err)
@@ -110,6 +114,7 @@ func (s *Server) getSimilarPosts(w http.ResponseWriter, r *http.Request) {
 		for _, p := range posts {
 			resp.Posts = append(resp.Posts, *p)
 		}
+		setCacheControl(w, cacheList)
 		if err := encodeResponse(w, r, http.StatusOK, resp); err != nil {
 			s.writeError(w, r, err)
 		}
@@ -122,6 +127,7 @@ func (s *Server) findPost(w http.ResponseWriter, r *http.Request) {
 	if id := chi.URLParam(r, "id"); id != "" {
 		if identify, err := strconv.Atoi(id); err == nil {
 			if post, err := s.PostService.FindPostByID(r.Context(), identify); err == nil {
+				setCacheControl(w, cacheResource)
 				if err := encodeResponse(w, r, http.StatusOK, post); err != nil {
 					s.writeError(w, r, err)
 				}
@@ -177,6 +183,12 @@ func (s *Server) createPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// fill in attributes the client didn't compute
+	if err := s.analyzePost(r.Context(), &createPost); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
 	// create the post in db
 	created, err := s.PostService.CreatePost(r.Context(), &createPost)
 	if err != nil || created == nil {
@@ -191,11 +203,15 @@ func (s *Server) createPost(w http.ResponseWriter, r *http.Request) {
 	// if there is no context value or context value is true, do encode
 	if encode == nil || doEncode {
 		toEncode := []int{created.Id}
-		err = s.SimilarityService.BatchEncodePosts(r.Context(), toEncode, 1)
+		result, err := s.SimilarityService.BatchEncodePosts(r.Context(), toEncode, 1, nil)
 		if err != nil {
 			s.writeError(w, r, err)
 			return
 		}
+		// the post is created either way, a reconcile encodes it later
+		for _, failure := range result.Failed {
+			s.logger.Warn().Ctx(r.Context()).Int("postID", failure.PostID).Str("reason", failure.Reason).Msg("Created post without encoding it")
+		}
 	}
 
 	createdResponse := CreateResponse{
@@ -241,6 +257,7 @@ func (s *Server) allPostIDs(w http.ResponseWriter, r *http.Request) {
 	idsResponse := IDsResponse{
 		Ids: ids,
 	}
+	setCacheControl(w, cacheList)
 	if err := encodeResponse(w, r, http.StatusOK, idsResponse); err != nil {
 		s.writeError(w, r, err)
 	}
@@ -288,66 +305,96 @@ func setMeta(filter *analogdb.PostFilter, posts []*analogdb.Post, count int) (Me
 
 	//pageID
 	if sort := filter.Sort; sort != nil {
-		sortVal := *sort
-		if sortVal == analogdb.SortTime || sortVal == analogdb.SortRandom {
+		switch sortVal := *sort; sortVal {
+		case analogdb.SortTime, analogdb.SortOldest, analogdb.SortRandom:
 			meta.PageID = posts[len(posts)-1].Time
-		} else if sortVal == analogdb.SortScore {
+		case analogdb.SortScore:
 			meta.PageID = posts[len(posts)-1].Score
-		} else {
+		case analogdb.SortTrending, analogdb.SortResolution, analogdb.SortColor:
+			// sort keys are not unique, paginate from the last post
+			meta.PageID = posts[len(posts)-1].Id
+		default:
 			return Meta{}, fmt.Errorf("invalid sort parameter: %s", sortVal.String())
 		}
 	}
 
 	//pageUrl
-	if sort := filter.Sort; sort != nil {
-		path := postsPath
-		numParams := 0
-		switch *sort {
-		case analogdb.SortTime:
-			path += fmt.Sprintf("%ssort=latest", paramJoiner(&numParams))
-		case analogdb.SortScore:
-			path += fmt.Sprintf("%ssort=top", paramJoiner(&numParams))
-		case analogdb.SortRandom:
-			path += fmt.Sprintf("%ssort=random", paramJoiner(&numParams))
-		}
-		if limit := filter.Limit; limit != nil {
-			path += fmt.Sprintf("%spage_size=%d", paramJoiner(&numParams), *limit)
-		}
-		path += fmt.Sprintf("%spage_id=%d", paramJoiner(&numParams), meta.PageID)
-		if nsfw := filter.Nsfw; nsfw != nil {
-			path += fmt.Sprintf("%snsfw=%t", paramJoiner(&numParams), *nsfw)
-		}
-		if grayscale := filter.Grayscale; grayscale != nil {
-			path += fmt.Sprintf("%sgrayscale=%t", paramJoiner(&numParams), *grayscale)
-		}
-		if sprock := filter.Sprocket; sprock != nil {
-			path += fmt.Sprintf("%ssprocket=%t", paramJoiner(&numParams), *sprock)
-		}
-		if title := filter.Title; title != nil {
-			path += fmt.Sprintf("%stitle=%s", paramJoiner(&numParams), *title)
-		}
-		if author := filter.Author; author != nil {
-			path += fmt.Sprintf("%sauthor=%s", paramJoiner(&numParams), *author)
-		}
-		if colors := filter.Colors; colors != nil {
-			for _, color := range *colors {
-				path += fmt.Sprintf("%scolor=%s", paramJoiner(&numParams), color)
-			}
+	if filter.Sort != nil {
+		meta.PageURL = pageURL(postsPath, filter, meta.PageID)
+	}
+
+	return meta, nil
+}
+
+// pageURL links to the page of posts after pageID, at path with the query of filter
+func pageURL(path string, filter *analogdb.PostFilter, pageID int) string {
+	numParams := 0
+	switch *filter.Sort {
+	case analogdb.SortTime:
+		path += fmt.Sprintf("%ssort=latest", paramJoiner(&numParams))
+	case analogdb.SortScore:
+		path += fmt.Sprintf("%ssort=top", paramJoiner(&numParams))
+	case analogdb.SortRandom:
+		path += fmt.Sprintf("%ssort=random", paramJoiner(&numParams))
+	case analogdb.SortOldest:
+		path += fmt.Sprintf("%ssort=oldest", paramJoiner(&numParams))
+	case analogdb.SortTrending:
+		path += fmt.Sprintf("%ssort=trending", paramJoiner(&numParams))
+	case analogdb.SortResolution:
+		path += fmt.Sprintf("%ssort=resolution", paramJoiner(&numParams))
+	case analogdb.SortColor:
+		path += fmt.Sprintf("%ssort=color", paramJoiner(&numParams))
+	}
+	if limit := filter.Limit; limit != nil {
+		path += fmt.Sprintf("%spage_size=%d", paramJoiner(&numParams), *limit)
+	}
+	path += fmt.Sprintf("%spage_id=%d", paramJoiner(&numParams), pageID)
+	if nsfw := filter.Nsfw; nsfw != nil {
+		path += fmt.Sprintf("%snsfw=%t", paramJoiner(&numParams), *nsfw)
+	}
+	if grayscale := filter.Grayscale; grayscale != nil {
+		path += fmt.Sprintf("%sgrayscale=%t", paramJoiner(&numParams), *grayscale)
+	}
+	if sprock := filter.Sprocket; sprock != nil {
+		path += fmt.Sprintf("%ssprocket=%t", paramJoiner(&numParams), *sprock)
+	}
+	if title := filter.Title; title != nil {
+		path += fmt.Sprintf("%stitle=%s", paramJoiner(&numParams), *title)
+	}
+	if author := filter.Author; author != nil {
+		path += fmt.Sprintf("%sauthor=%s", paramJoiner(&numParams), *author)
+	}
+	if film := filter.Film; film != nil {
+		path += fmt.Sprintf("%sfilm=%s", paramJoiner(&numParams), *film)
+	}
+	if camera := filter.Camera; camera != nil {
+		path += fmt.Sprintf("%scamera=%s", paramJoiner(&numParams), *camera)
+	}
+	if cameraMake := filter.CameraMake; cameraMake != nil {
+		path += fmt.Sprintf("%scamera_make=%s", paramJoiner(&numParams), *cameraMake)
+	}
+	if hasExif := filter.HasExif; hasExif != nil {
+		path += fmt.Sprintf("%shas_exif=%t", paramJoiner(&numParams), *hasExif)
+	}
+	if encoded := filter.Encoded; encoded != nil {
+		path += fmt.Sprintf("%sencoded=%t", paramJoiner(&numParams), *encoded)
+	}
+	if colors := filter.Colors; colors != nil {
+		for _, color := range *colors {
+			path += fmt.Sprintf("%scolor=%s", paramJoiner(&numParams), color)
 		}
-		if colorPercents := filter.ColorPercents; colorPercents != nil {
-			for _, percent := range *colorPercents {
-				path += fmt.Sprintf("%smin_color=%.2f", paramJoiner(&numParams), percent)
-			}
+	}
+	if colorPercents := filter.ColorPercents; colorPercents != nil {
+		for _, percent := range *colorPercents {
+			path += fmt.Sprintf("%smin_color=%.2f", paramJoiner(&numParams), percent)
 		}
-		if keywords := filter.Keywords; keywords != nil {
-			for _, keyword := range *keywords {
-				path += fmt.Sprintf("%skeyword=%s", paramJoiner(&numParams), keyword)
-			}
+	}
+	if keywords := filter.Keywords; keywords != nil {
+		for _, keyword := range *keywords {
+			path += fmt.Sprintf("%skeyword=%s", paramJoiner(&numParams), keyword)
 		}
-		meta.PageURL = path
 	}
-
-	return meta, nil
+	return path
 }
 
 func paramJoiner(numParams *int) string {
@@ -384,20 +431,30 @@ func parseToFilter(r *http.Request) (*analogdb.PostFilter, error) {
 	values := r.URL.Query()
 
 	if sort := values.Get("sort"); sort != "" {
-		if sort == "latest" || sort == "top" || sort == "random" {
-			switch sort {
-			case "latest":
-				time := analogdb.SortTime
-				filter.Sort = &time
-			case "top":
-				top := analogdb.SortScore
-				filter.Sort = &top
-			case "random":
-				random := analogdb.SortRandom
-				filter.Sort = &random
-			}
-		} else {
-			return nil, fmt.Errorf("invalid sort parameter %s, valid options are 'latest', 'top', 'random'", sort)
+		switch sort {
+		case "latest":
+			time := analogdb.SortTime
+			filter.Sort = &time
+		case "top":
+			top := analogdb.SortScore
+			filter.Sort = &top
+		case "random":
+			random := analogdb.SortRandom
+			filter.Sort = &random
+		case "oldest":
+			oldest := analogdb.SortOldest
+			filter.Sort = &oldest
+		case "trending":
+			trending := analogdb.SortTrending
+			filter.Sort = &trending
+		case "resolution":
+			resolution := analogdb.SortResolution
+			filter.Sort = &resolution
+		case "color":
+			color := analogdb.SortColor
+			filter.Sort = &color
+		default:
+			return nil, fmt.Errorf("invalid sort parameter %s, valid options are 'latest', 'top', 'random', 'oldest', 'trending', 'resolution', 'color'", sort)
 		}
 	}
 
@@ -471,6 +528,34 @@ func parseToFilter(r *http.Request) (*analogdb.PostFilter, error) {
 		filter.Author = &author
 	}
 
+	if film := values.Get("film"); film != "" {
+		filter.Film = &film
+	}
+
+	if camera := values.Get("camera"); camera != "" {
+		filter.Camera = &camera
+	}
+
+	if cameraMake := values.Get("camera_make"); cameraMake != "" {
+		filter.CameraMake = &cameraMake
+	}
+
+	if hasExif := values.Get("has_exif"); hasExif != "" {
+		if val, err := stringToBool(hasExif); err != nil {
+			return nil, err
+		} else {
+			filter.HasExif = &val
+		}
+	}
+
+	if encoded := values.Get("encoded"); encoded != "" {
+		if val, err := stringToBool(encoded); err != nil {
+			return nil, err
+		} else {
+			filter.Encoded = &val
+		}
+	}
+
 	if colorPercent, ok := values["min_color"]; ok {
 		percents := []float64{}
 		for _, p := range colorPercent {
@@ -550,6 +635,12 @@ func parseToFilter(r *http.Request) (*analogdb.PostFilter, error) {
 			filter.AspectRatio.Max = &ratio
 		}
 	}
+
+	// sorting by color orders by the first filtered color
+	if sort := filter.Sort; *sort == analogdb.SortColor && filter.Colors == nil {
+		return nil, fmt.Errorf("sort parameter 'color' requires a color parameter")
+	}
+
 	return filter, nil
 }
 
@@ -512,6 +512,56 @@ func TestPatchPost(t *testing.T) {
 	})
 }
 
+func TestConditionalGetPosts(t *testing.T) {
+	t.Run("latest not modified", func(t *testing.T) {
+		s, db := mustOpen(t)
+		defer mustClose(t, s, db)
+
+		r := httptest.NewRequest(http.MethodGet, "/posts?sort=latest&page_size=10", nil)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+
+		if want, got := http.StatusOK, w.Code; got != want {
+			t.Fatalf("want status %d, got %d", want, got)
+		}
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("want etag, got none")
+		}
+		if want, got := cacheLatest, w.Header().Get("Cache-Control"); got != want {
+			t.Errorf("want cache control %s, got %s", want, got)
+		}
+		if w.Header().Get("Last-Modified") == "" {
+			t.Error("want last modified, got none")
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/posts?sort=latest&page_size=10", nil)
+		r.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+
+		if want, got := http.StatusNotModified, w.Code; got != want {
+			t.Errorf("want status %d, got %d", want, got)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("want empty body, got %d bytes", w.Body.Len())
+		}
+	})
+
+	t.Run("random not stored", func(t *testing.T) {
+		s, db := mustOpen(t)
+		defer mustClose(t, s, db)
+
+		r := httptest.NewRequest(http.MethodGet, "/posts?sort=random&page_size=10", nil)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+
+		if want, got := cacheNever, w.Header().Get("Cache-Control"); got != want {
+			t.Errorf("want cache control %s, got %s", want, got)
+		}
+	})
+}
+
 func TestAllPostIDs(t *testing.T) {
 	t.Run("valid IDs", func(t *testing.T) {
 		s, db := mustOpen(t)
@@ -558,10 +608,14 @@ func makeTestCreatePost(valid bool) analogdb.CreatePost {
 	}
 	var testImages []analogdb.Image
 	if valid {
-		// valid post has 4 images
-		testImages = append(testImages, testImage, testImage, testImage, testImage)
+		// valid post has an image of every required resolution
+		for _, label := range []string{analogdb.ImageLow, analogdb.ImageMedium, analogdb.ImageHigh, analogdb.ImageRaw} {
+			image := testImage
+			image.Label = label
+			testImages = append(testImages, image)
+		}
 	} else {
-		// invalid post has anything other than 4 images
+		// invalid post is missing resolutions
 		testImages = append(testImages, testImage, testImage)
 	}
 
@@ -580,9 +634,9 @@ func makeTestCreatePost(valid bool) analogdb.CreatePost {
 		Permalink: "test.permalink.com",
 		Score:     0,
 		Nsfw:      false,
-		Grayscale: false,
+		Grayscale: new(bool),
 		Time:      0,
-		Sprocket:  false,
+		Sprocket:  new(bool),
 		Images:    testImages,
 		Colors:    fiveColors,
 	}
@@ -2,13 +2,14 @@ package server
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/evanofslack/analogdb"
 	"github.com/evanofslack/analogdb/config"
+	"github.com/evanofslack/analogdb/imaging"
 	"github.com/evanofslack/analogdb/logger"
 	"github.com/evanofslack/analogdb/metrics"
 	"github.com/go-chi/chi/v5"
@@ -24,13 +25,27 @@ type Server struct {
 	metrics *metrics.Metrics
 	config  *config.Config
 	stats   *httpStats
+	images  *imageProxy
+
+	// background jobs stop once the server closes
+	ctx    context.Context
+	cancel func()
+	// whether outdated posts are being encoded
+	encoding atomic.Bool
 
 	PostService       analogdb.PostService
 	ReadyService      analogdb.ReadyService
 	AuthorService     analogdb.AuthorService
 	ScrapeService     analogdb.ScrapeService
 	KeywordService    analogdb.KeywordService
+	GearService       analogdb.GearService
 	SimilarityService analogdb.SimilarityService
+	DuplicateService  analogdb.DuplicateService
+	EncodingService   analogdb.EncodingService
+
+	// downloads post images, shared with the encoder
+	// so both stay within the same download limits
+	Downloader *imaging.Downloader
 }
 
 func New(port string, logger *logger.Logger, metrics *metrics.Metrics, config *config.Config) *Server {
@@ -40,8 +55,11 @@ func New(port string, logger *logger.Logger, metrics *metrics.Metrics, config *c
 		logger:  logger,
 		metrics: metrics,
 		config:  config,
+
+		Downloader: imaging.NewDownloader(),
 	}
 
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 	s.server.Handler = s.router
 	s.server.Addr = ":" + port
 
@@ -54,6 +72,10 @@ func New(port string, logger *logger.Logger, metrics *metrics.Metrics, config *c
 	s.mountSimilarityHandlers()
 	s.mountScrapeHandlers()
 	s.mountKeywordHandlers()
+	s.mountGearHandlers()
+	s.mountExportHandlers()
+	s.mountImageHandlers()
+	s.mountDuplicateHandlers()
 	s.mountStaticHandlers()
 	s.mountStatusHandlers()
 	s.mountStatsHandlers()
@@ -78,15 +100,37 @@ func (s *Server) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	s.healthy = false
+	s.cancel()
 	return s.server.Shutdown(ctx)
 }
 
 func encodeResponse(w http.ResponseWriter, r *http.Request, status int, v any) error {
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	format := negotiateFormat(r.Header.Get("Accept"), v)
+	body, err := format.encode(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Add("Vary", "Accept")
+
+	if paged, ok := v.(pagedResponse); ok {
+		if next := paged.nextPageURL(); next != "" {
+			w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next))
+		}
+	}
+
+	// let clients skip downloading a response they already have
+	if cacheable(r, status) {
+		etag := computeETag(body)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
 	w.WriteHeader(status)
-	enc := json.NewEncoder(w)
-	enc.SetEscapeHTML(false)
-	if err := enc.Encode(v); err != nil {
+	if _, err := w.Write(body); err != nil {
 		return err
 	}
 	return nil
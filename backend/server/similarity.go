@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/evanofslack/analogdb"
 	"github.com/go-chi/chi/v5"
@@ -16,6 +17,8 @@ const (
 func (s *Server) mountSimilarityHandlers() {
 	s.router.Route(encodePath, func(r chi.Router) {
 		r.With(s.auth).Put("/", s.encodePosts)
+		r.With(s.auth).Put("/outdated", s.encodeOutdatedPosts)
+		r.With(s.auth).Get("/{id}", s.getEncoding)
 	})
 }
 
@@ -25,7 +28,8 @@ type encodePostsRequest struct {
 }
 
 type encodePostsResponse struct {
-	Message string `json:"message"`
+	Message string                   `json:"message"`
+	Failed  []analogdb.EncodeFailure `json:"failed,omitempty"`
 }
 
 func (s *Server) encodePosts(w http.ResponseWriter, r *http.Request) {
@@ -34,31 +38,92 @@ func (s *Server) encodePosts(w http.ResponseWriter, r *http.Request) {
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		err = &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "error parsing ids or batch_size from request body"}
 		s.writeError(w, r, err)
+		return
 	}
 
-	var message string
+	response := encodePostsResponse{}
 
 	// encode single post
 	if len(request.Ids) == 1 {
 		err := s.SimilarityService.EncodePost(r.Context(), request.Ids[0])
 		if err != nil {
 			s.writeError(w, r, err)
+			return
 		}
-		message = "successfully encoded post"
+		response.Message = "successfully encoded post"
 
 	} else {
-		// encode batch of posts
-		err := s.SimilarityService.BatchEncodePosts(r.Context(), request.Ids, request.BatchSize)
+		// encode batch of posts, reporting the posts that failed
+		result, err := s.SimilarityService.BatchEncodePosts(r.Context(), request.Ids, request.BatchSize, nil)
 		if err != nil {
 			s.writeError(w, r, err)
+			return
 		}
-		message = fmt.Sprintf("successfully encoded %d posts", len(request.Ids))
+		response.Failed = result.Failed
+		response.Message = fmt.Sprintf("successfully encoded %d posts", len(result.Encoded))
 	}
 
-	response := encodePostsResponse{
-		Message: message,
-	}
 	if err := encodeResponse(w, r, http.StatusOK, response); err != nil {
 		s.writeError(w, r, err)
 	}
 }
+
+// default number of posts uploaded to the vector DB at a time
+const defaultEncodeBatchSize = 20
+
+// encodeOutdatedPosts starts encoding every post that failed to encode,
+// was never encoded, or was encoded with an older schema. Encoding can take
+// hours, so it runs in the background and progress is read per post from
+// GET /encode/{id}. Only one encode of outdated posts runs at a time.
+func (s *Server) encodeOutdatedPosts(w http.ResponseWriter, r *http.Request) {
+
+	batchSize := defaultEncodeBatchSize
+	if query := r.URL.Query().Get("batch_size"); query != "" {
+		size, err := strconv.Atoi(query)
+		if err != nil || size <= 0 {
+			err = &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "batch_size must be a positive number"}
+			s.writeError(w, r, err)
+			return
+		}
+		batchSize = size
+	}
+
+	if !s.encoding.CompareAndSwap(false, true) {
+		err := &analogdb.Error{Code: analogdb.ERRCONFLICT, Message: "outdated posts are already being encoded"}
+		s.writeError(w, r, err)
+		return
+	}
+	go func() {
+		defer s.encoding.Store(false)
+		result, err := s.SimilarityService.EncodeOutdatedPosts(s.ctx, batchSize, nil)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("Failed to encode outdated posts")
+			return
+		}
+		s.logger.Info().Int("encoded", len(result.Encoded)).Int("failed", len(result.Failed)).Msg("Encoded outdated posts")
+	}()
+
+	response := encodePostsResponse{Message: "started encoding outdated posts"}
+	if err := encodeResponse(w, r, http.StatusAccepted, response); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *Server) getEncoding(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		err = &analogdb.Error{Code: analogdb.ERRUNPROCESSABLE, Message: "invalid post id"}
+		s.writeError(w, r, err)
+		return
+	}
+
+	encoding, err := s.EncodingService.FindEncodingByID(r.Context(), id)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if err := encodeResponse(w, r, http.StatusOK, encoding); err != nil {
+		s.writeError(w, r, err)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/logger"
+)
+
+// blockingSimilarityService encodes outdated posts once release is closed
+type blockingSimilarityService struct {
+	analogdb.SimilarityService
+	started chan struct{}
+	release chan struct{}
+}
+
+func (ss *blockingSimilarityService) EncodeOutdatedPosts(ctx context.Context, batchSize int, progress analogdb.EncodeProgressFunc) (*analogdb.EncodeResult, error) {
+	close(ss.started)
+	select {
+	case <-ss.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &analogdb.EncodeResult{Encoded: []int{1}}, nil
+}
+
+func TestEncodeOutdatedPosts(t *testing.T) {
+
+	logger, err := logger.New("debug", "debug", "analogdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss := &blockingSimilarityService{started: make(chan struct{}), release: make(chan struct{})}
+	s := &Server{logger: logger, SimilarityService: ss}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	defer s.cancel()
+
+	encode := func() int {
+		r := httptest.NewRequest(http.MethodPut, "/encode/outdated", nil)
+		w := httptest.NewRecorder()
+		s.encodeOutdatedPosts(w, r)
+		return w.Code
+	}
+
+	// the request returns before the posts are encoded
+	if want, got := http.StatusAccepted, encode(); got != want {
+		t.Fatalf("want status %d, got %d", want, got)
+	}
+	<-ss.started
+	if want, got := http.StatusConflict, encode(); got != want {
+		t.Fatalf("want status %d while encoding, got %d", want, got)
+	}
+
+	close(ss.release)
+	deadline := time.Now().Add(time.Second)
+	for s.encoding.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("encode of outdated posts should finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -40,6 +40,7 @@ func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
 	err := s.ReadyService.Readyz(r.Context())
 	if err != nil {
 		s.writeError(w, r, err)
+		return
 	}
 	if err := encodeResponse(w, r, http.StatusOK, "message: ready"); err != nil {
 		s.writeError(w, r, err)
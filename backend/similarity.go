@@ -10,11 +10,36 @@ type PostSimilarity struct {
 type SimilarityService interface {
 	CreateSchemas(ctx context.Context) error
 	EncodePost(ctx context.Context, id int) error
-	BatchEncodePosts(ctx context.Context, ids []int, batchSize int) error
+	BatchEncodePosts(ctx context.Context, ids []int, batchSize int, progress EncodeProgressFunc) (*EncodeResult, error)
+	EncodeOutdatedPosts(ctx context.Context, batchSize int, progress EncodeProgressFunc) (*EncodeResult, error)
 	FindSimilarPosts(ctx context.Context, filter *PostSimilarityFilter) ([]*Post, error)
 	DeletePost(ctx context.Context, id int) error
 }
 
+// EncodeFailure is a post that could not be encoded, usually because
+// none of its images could be downloaded
+type EncodeFailure struct {
+	PostID int    `json:"post_id"`
+	Reason string `json:"reason"`
+}
+
+// EncodeResult lists the posts encoded by BatchEncodePosts, and why every other post failed.
+// Posts neither encoded nor failed weren't reached before the encoding stopped.
+type EncodeResult struct {
+	Encoded []int           `json:"encoded"`
+	Failed  []EncodeFailure `json:"failed,omitempty"`
+}
+
+// EncodeProgress is how many posts of a batch encode are done
+type EncodeProgress struct {
+	Total   int
+	Encoded int
+	Failed  int
+}
+
+// EncodeProgressFunc is called by BatchEncodePosts every time a post is done
+type EncodeProgressFunc func(EncodeProgress)
+
 // used to enable encoding in http request
 // only used to bypass encoding when running tests
 type ContextKey string
@@ -2,162 +2,274 @@ package weaviate
 
 import (
 	"context"
-	"encoding/base64"
-	"fmt"
-	"io/ioutil"
-	"net/http"
+	"errors"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/evanofslack/analogdb"
+	"github.com/go-openapi/strfmt"
+	"github.com/google/uuid"
 	"github.com/weaviate/weaviate/entities/models"
+	"golang.org/x/sync/errgroup"
 )
 
-func (ss SimilarityService) BatchEncodePosts(ctx context.Context, ids []int, batchSize int) error {
+var errPostNotFound = errors.New("post not found")
 
-	batches := batchBy(ids, batchSize)
-	for _, batch := range batches {
-		filter := analogdb.PostFilter{IDs: &batch}
-		posts, _, err := ss.postService.FindPosts(ctx, &filter)
-		if err != nil {
-			return err
-		}
-		pictureObjects := postsToPictureObjects(posts)
-		err = ss.db.batchUploadObjects(ctx, pictureObjects)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+func (ss SimilarityService) BatchEncodePosts(ctx context.Context, ids []int, batchSize int, progress analogdb.EncodeProgressFunc) (*analogdb.EncodeResult, error) {
+	return ss.batchEncodePostsInto(ctx, ss.db.pictureClass(), ids, batchSize, progress)
 }
 
-func (db *DB) batchUploadObjects(ctx context.Context, objects []*models.Object) error {
-
-	db.logger.Debug().Ctx(ctx).Msg("Starting batch upload to vector DB")
+// EncodeOutdatedPosts encodes every post not yet encoded with the latest
+// schema, including posts that failed to encode and posts never encoded
+func (ss SimilarityService) EncodeOutdatedPosts(ctx context.Context, batchSize int, progress analogdb.EncodeProgressFunc) (*analogdb.EncodeResult, error) {
+	ids, err := ss.encodingService.OutdatedPostIDs(ctx, latestSchemaVersion())
+	if err != nil {
+		return nil, err
+	}
+	ss.db.logger.Info().Ctx(ctx).Int("posts", len(ids)).Msg("Encoding outdated posts")
+	return ss.BatchEncodePosts(ctx, ids, batchSize, progress)
+}
 
-	batcher := db.db.Batch().ObjectsBatcher()
-	for _, obj := range objects {
-		batcher.WithObject(obj)
+// markEncodings records which posts were encoded and which failed. Posts
+// are encoded either way, so a failure to record is only logged, leaving
+// the posts to be encoded again.
+func (ss SimilarityService) markEncodings(ctx context.Context, result *analogdb.EncodeResult) {
+	version := latestSchemaVersion()
+	if err := ss.encodingService.MarkEncoded(ctx, result.Encoded, version); err != nil {
+		ss.db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to record encoded posts")
 	}
-	_, err := batcher.Do(ctx)
-	if err != nil {
-		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to do batch upload to vector DB")
-		return err
+	if err := ss.encodingService.MarkFailed(ctx, result.Failed, version); err != nil {
+		ss.db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to record posts that failed to encode")
 	}
-	return nil
 }
 
-func maxThreadsDownload(maxGoroutines int, posts []*analogdb.Post, wg *sync.WaitGroup, encodesChan chan string, postsChan chan *analogdb.Post, failedChan chan int) {
-	// limit max concurrent goroutines
-	guard := make(chan int, maxGoroutines)
+// encodeJob is a post to encode, nil if the post wasn't found
+type encodeJob struct {
+	id   int
+	post *analogdb.Post
+}
 
-	for _, post := range posts {
-		wg.Add(1)
-		guard <- 1
-		go func(post *analogdb.Post, wg *sync.WaitGroup, encodesChan chan string, postsChan chan *analogdb.Post, failedChan chan int) {
-			downloadAndEncodePost(post, wg, encodesChan, postsChan, failedChan)
-			<-guard
-		}(post, wg, encodesChan, postsChan, failedChan)
-	}
+// encodedPost is the picture object of a post, or why it couldn't be made
+type encodedPost struct {
+	id     int
+	object *models.Object
+	err    error
 }
 
-func downloadAndEncodePosts(posts []*analogdb.Post) ([]string, []*analogdb.Post, []int) {
-	var wg sync.WaitGroup
+// batchEncodePostsInto encodes posts into class, which may not be the current picture class.
+//
+// Posts are encoded by a pipeline: batches of posts are read from the DB,
+// their images are downloaded by a pool of workers as large as the download
+// concurrency, and the resulting objects are uploaded in batches. Posts that
+// fail are listed in the result while the rest carry on. The pipeline stops
+// when ctx is cancelled or the DB or vector DB fail, returning the result so far.
+func (ss SimilarityService) batchEncodePostsInto(ctx context.Context, class string, ids []int, batchSize int, progress analogdb.EncodeProgressFunc) (*analogdb.EncodeResult, error) {
+
+	db := ss.db
+	db.logger.Debug().Ctx(ctx).Str("class", class).Int("posts", len(ids)).Msg("Starting batch encode posts")
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	start := time.Now()
+	result := &analogdb.EncodeResult{}
+	status := analogdb.EncodeProgress{Total: len(ids)}
 
-	encodesChan := make(chan string)
-	postsChan := make(chan *analogdb.Post)
-	failedChan := make(chan int)
+	// status of posts is still recorded once the pipeline is cancelled
+	pipelineCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, gctx := errgroup.WithContext(pipelineCtx)
 
-	maxGoroutines := 10
-	go maxThreadsDownload(maxGoroutines, posts, &wg, encodesChan, postsChan, failedChan)
+	// read posts a batch at a time, so only a few batches are held in memory
+	jobs := make(chan encodeJob, batchSize)
+	g.Go(func() error {
+		defer close(jobs)
+		for _, batch := range batchBy(ids, batchSize) {
+			posts, _, err := ss.postService.FindPosts(gctx, analogdb.NewPostFilterWithIDs(batch))
+			if err != nil {
+				return err
+			}
+			found := make(map[int]*analogdb.Post, len(posts))
+			for _, post := range posts {
+				found[post.Id] = post
+			}
+			for _, id := range batch {
+				select {
+				case jobs <- encodeJob{id: id, post: found[id]}:
+				case <-gctx.Done():
+					return gctx.Err()
+				}
+			}
+		}
+		return nil
+	})
 
+	// download images of posts with a bounded pool of workers
+	encoded := make(chan encodedPost, batchSize)
+	var workers sync.WaitGroup
+	for i := 0; i < db.downloader.Concurrency(); i++ {
+		workers.Add(1)
+		g.Go(func() error {
+			defer workers.Done()
+			for job := range jobs {
+				out := encodedPost{id: job.id, err: errPostNotFound}
+				if job.post != nil {
+					var image string
+					if image, out.err = db.downloadPostImage(gctx, job.post); out.err == nil {
+						out.object = newPictureObject(class, image, job.post)
+					}
+				}
+				// a download cut short by cancellation isn't the post's fault
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+				select {
+				case encoded <- out:
+				case <-gctx.Done():
+					return gctx.Err()
+				}
+			}
+			return nil
+		})
+	}
 	go func() {
-		time.Sleep(time.Second * 2)
-		wg.Wait()
-		close(encodesChan)
-		close(postsChan)
-		close(failedChan)
+		workers.Wait()
+		close(encoded)
 	}()
 
-	var encodedImages []string
-	var successPosts []*analogdb.Post
-	var failedIDs []int
+	// progress is only reported from this goroutine, so callbacks never overlap
+	fail := func(id int, err error) {
+		result.Failed = append(result.Failed, analogdb.EncodeFailure{PostID: id, Reason: err.Error()})
+		status.Failed++
+		db.stats.posts.WithLabelValues("failed").Inc()
+		if progress != nil {
+			progress(status)
+		}
+	}
+	succeed := func(id int) {
+		result.Encoded = append(result.Encoded, id)
+		status.Encoded++
+		db.stats.posts.WithLabelValues("encoded").Inc()
+		if progress != nil {
+			progress(status)
+		}
+	}
 
-	for {
-		select {
-		case encoded, ok := <-encodesChan:
-			if ok {
-				encodedImages = append(encodedImages, encoded)
-			} else {
-				return encodedImages, successPosts, failedIDs
-			}
-		case post, ok := <-postsChan:
-			if ok {
-				successPosts = append(successPosts, post)
-			} else {
-				return encodedImages, successPosts, failedIDs
-			}
-		case id, ok := <-failedChan:
-			if ok {
-				failedIDs = append(failedIDs, id)
+	// upload objects in batches, reading every result so the workers never block
+	var uploadErr error
+	pending := make([]encodedPost, 0, batchSize)
+	upload := func() {
+		if len(pending) == 0 || uploadErr != nil {
+			return
+		}
+		objects := make([]*models.Object, len(pending))
+		for i, p := range pending {
+			objects[i] = p.object
+		}
+		errs, err := db.batchUploadObjects(gctx, objects)
+		if err != nil {
+			uploadErr = err
+			cancel()
+			return
+		}
+		for i, p := range pending {
+			if errs[i] != nil {
+				fail(p.id, errs[i])
 			} else {
-				return encodedImages, successPosts, failedIDs
+				succeed(p.id)
 			}
 		}
+		pending = pending[:0]
+	}
+	for out := range encoded {
+		if uploadErr != nil {
+			continue
+		}
+		if out.err != nil {
+			fail(out.id, out.err)
+			continue
+		}
+		pending = append(pending, out)
+		if len(pending) == batchSize {
+			upload()
+		}
 	}
-}
-
-func downloadAndEncodePost(post *analogdb.Post, wg *sync.WaitGroup, encodes chan string, posts chan *analogdb.Post, failed chan int) {
-	defer wg.Done()
 
-	url := post.Images[1].Url
-	id := post.Id
-	resp, err := http.Get(url)
-	if err != nil {
-		fmt.Println("image download request errored with resp:")
-		fmt.Println(resp)
-		failed <- id
+	err := g.Wait()
+	if err == nil {
+		upload()
+	}
+	if uploadErr != nil {
+		err = uploadErr
 	}
-	defer resp.Body.Close()
 
-	data, err := ioutil.ReadAll(resp.Body)
+	sort.Ints(result.Encoded)
+	sort.Slice(result.Failed, func(i, j int) bool { return result.Failed[i].PostID < result.Failed[j].PostID })
+	db.stats.duration.Observe(time.Since(start).Seconds())
+	ss.markEncodings(ctx, result)
+
 	if err != nil {
-		failed <- id
+		db.logger.Error().Err(err).Ctx(ctx).Int("encoded", len(result.Encoded)).Int("failed", len(result.Failed)).Int("posts", len(ids)).Msg("Stopped batch encode posts")
+		return result, err
+	}
+	if len(result.Failed) != 0 {
+		db.logger.Warn().Ctx(ctx).Int("failed", len(result.Failed)).Int("posts", len(ids)).Msg("Failed to encode some posts")
 	}
-	encoded := base64.StdEncoding.EncodeToString(data)
-	encodes <- encoded
-	posts <- post
-	return
+	db.logger.Info().Ctx(ctx).Int("encoded", len(result.Encoded)).Int("failed", len(result.Failed)).Dur("duration", time.Since(start)).Msg("Finished batch encode posts")
+	return result, nil
 }
 
-func postsToPictureObjects(posts []*analogdb.Post) []*models.Object {
-	encodedImages, successPosts, failedIDs := downloadAndEncodePosts(posts)
-	var pictureObjects []*models.Object
+// batchUploadObjects uploads objects at once, returning the error of every
+// object the vector DB rejected, in the order of objects
+func (db *DB) batchUploadObjects(ctx context.Context, objects []*models.Object) ([]error, error) {
 
-	for i := range encodedImages {
-		image := encodedImages[i]
-		post := successPosts[i]
-		pictureObject := newPictureObject(image, post.Id, post.Grayscale, post.Nsfw, post.Sprocket)
-		pictureObjects = append(pictureObjects, pictureObject)
+	db.logger.Debug().Ctx(ctx).Int("objects", len(objects)).Msg("Starting batch upload to vector DB")
 
+	batcher := db.db.Batch().ObjectsBatcher()
+	for _, obj := range objects {
+		batcher.WithObject(obj)
+	}
+	resp, err := batcher.Do(ctx)
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to do batch upload to vector DB")
+		return nil, err
 	}
 
-	if len(failedIDs) != 0 {
-		fmt.Println(fmt.Sprintf("failed to download/encode post ids: %v", failedIDs))
+	errs := make([]error, len(objects))
+	for i := range resp {
+		if i >= len(errs) {
+			break
+		}
+		if r := resp[i].Result; r != nil && r.Errors != nil && len(r.Errors.Error) > 0 {
+			errs[i] = errors.New(r.Errors.Error[0].Message)
+		}
 	}
+	return errs, nil
+}
+
+// namespace of the uuids of pictures
+var pictureNamespace = uuid.MustParse("0b8f8f4e-6d1a-4c55-9a3e-2f7d4c1b5e90")
 
-	return pictureObjects
+// pictureID is the uuid of the picture of a post, the same every time the
+// post is encoded, so encoding a post again replaces its picture
+func pictureID(postID int) strfmt.UUID {
+	return strfmt.UUID(uuid.NewSHA1(pictureNamespace, []byte(strconv.Itoa(postID))).String())
 }
 
-func newPictureObject(image string, postID int, grayscale bool, nsfw bool, sprocket bool) *models.Object {
+func newPictureObject(class string, image string, post *analogdb.Post) *models.Object {
 	object := models.Object{
-		Class: "Picture",
+		Class: class,
+		ID:    pictureID(post.Id),
 		Properties: map[string]interface{}{
 			"image":     image,
-			"post_id":   postID,
-			"grayscale": grayscale,
-			"nsfw":      nsfw,
-			"sprocket":  sprocket,
+			"post_id":   post.Id,
+			"grayscale": post.Grayscale,
+			"nsfw":      post.Nsfw,
+			"sprocket":  post.Sprocket,
+			"score":     post.Score,
+			"time":      post.Time,
 		},
 	}
 	return &object
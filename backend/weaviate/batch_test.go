@@ -0,0 +1,153 @@
+package weaviate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/evanofslack/analogdb/imaging"
+	"github.com/evanofslack/analogdb/logger"
+	"github.com/evanofslack/analogdb/postgres"
+	"github.com/evanofslack/analogdb/tracer"
+	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newVectorServer fakes the vector DB, accepting every object of a batch upload
+func newVectorServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/batch/objects" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		var body struct {
+			Objects []json.RawMessage `json:"objects"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := make([]map[string]interface{}, len(body.Objects))
+		for i := range resp {
+			resp[i] = map[string]interface{}{"result": map[string]interface{}{}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+// newImageServer serves a small png at every path
+func newImageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+}
+
+func TestPictureID(t *testing.T) {
+	if pictureID(1) != pictureID(1) {
+		t.Fatal("a post should have the same picture uuid every time")
+	}
+	if pictureID(1) == pictureID(2) {
+		t.Fatal("posts should have different picture uuids")
+	}
+	if object := newPictureObject(PictureClass, "", &analogdb.Post{Id: 1}); object.ID != pictureID(1) {
+		t.Fatalf("want picture uuid %s, got %s", pictureID(1), object.ID)
+	}
+}
+
+func TestBatchEncodePosts(t *testing.T) {
+
+	if err := godotenv.Load("../.env"); err != nil {
+		t.Error("Error loading .env file")
+	}
+	logger, err := logger.New("debug", "debug", "analogdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// connect to local db for testing
+	pg := postgres.NewDB(os.Getenv("POSTGRES_DATABASE_URL"), logger, false)
+	if err := pg.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer pg.Close()
+	ps := postgres.NewPostService(pg)
+	es := postgres.NewEncodingService(pg)
+
+	vectors := newVectorServer(t)
+	defer vectors.Close()
+	images := newImageServer(t)
+	defer images.Close()
+
+	host, err := url.Parse(vectors.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &tracer.Tracer{Tracer: trace.NewNoopTracerProvider().Tracer("test")}
+	downloader := imaging.NewDownloader(imaging.WithDownloadRetries(0, 0))
+	db := NewDB(host.Host, host.Scheme, logger, tr, WithDownloader(downloader))
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ss := NewSimilarityService(db, ps, es)
+
+	ctx := context.Background()
+
+	var postImages []analogdb.Image
+	for _, label := range []string{analogdb.ImageLow, analogdb.ImageMedium, analogdb.ImageHigh, analogdb.ImageRaw} {
+		postImages = append(postImages, analogdb.Image{Label: label, Url: fmt.Sprintf("%s/%s.png", images.URL, label), Width: 8, Height: 8})
+	}
+	color := analogdb.Color{Hex: "#000000", Css: "Black", Percent: 0.2}
+	created, err := ps.CreatePost(ctx, &analogdb.CreatePost{
+		Title:     "test batch encode",
+		Author:    "test author",
+		Permalink: fmt.Sprintf("test.batch.encode.%d", time.Now().UnixNano()),
+		Grayscale: new(bool),
+		Sprocket:  new(bool),
+		Images:    postImages,
+		Colors:    []analogdb.Color{color, color, color, color, color},
+	})
+	if err != nil {
+		t.Fatalf("valid post should be created, error: %s", err)
+	}
+	defer ps.DeletePost(ctx, created.Id)
+
+	// the pipeline reads posts by id only, and a missing post fails alone
+	missing := -1
+	result, err := ss.BatchEncodePosts(ctx, []int{created.Id, missing}, 2, nil)
+	if err != nil {
+		t.Fatalf("posts should be encoded, error: %s", err)
+	}
+	if len(result.Encoded) != 1 || result.Encoded[0] != created.Id {
+		t.Fatalf("invalid encoded posts, got %v, want %v", result.Encoded, []int{created.Id})
+	}
+	if len(result.Failed) != 1 || result.Failed[0].PostID != missing {
+		t.Fatalf("invalid failed posts, got %v, want post %d", result.Failed, missing)
+	}
+
+	encoding, err := es.FindEncodingByID(ctx, created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoding.Status != analogdb.EncodingEncoded || encoding.SchemaVersion != latestSchemaVersion() {
+		t.Fatalf("invalid encoding, got %s at version %d, want %s at version %d", encoding.Status, encoding.SchemaVersion, analogdb.EncodingEncoded, latestSchemaVersion())
+	}
+}
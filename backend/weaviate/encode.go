@@ -4,12 +4,12 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 
 	"github.com/evanofslack/analogdb"
 	"github.com/weaviate/weaviate/entities/models"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (ss SimilarityService) EncodePost(ctx context.Context, id int) error {
@@ -24,6 +24,19 @@ func (ss SimilarityService) EncodePost(ctx context.Context, id int) error {
 		err = fmt.Errorf("failed to find post by ID: %w", err)
 		return err
 	}
+
+	err = ss.encodePost(ctx, post)
+	result := &analogdb.EncodeResult{}
+	if err != nil {
+		result.Failed = []analogdb.EncodeFailure{{PostID: id, Reason: err.Error()}}
+	} else {
+		result.Encoded = []int{id}
+	}
+	ss.markEncodings(ctx, result)
+	return err
+}
+
+func (ss SimilarityService) encodePost(ctx context.Context, post *analogdb.Post) error {
 	obj, err := ss.db.postToPictureObject(ctx, post)
 	if err != nil {
 		err = fmt.Errorf("failed to convert post to picture object: %w", err)
@@ -37,43 +50,27 @@ func (ss SimilarityService) EncodePost(ctx context.Context, id int) error {
 	return nil
 }
 
+// image labels to encode a post from, in order of preference. Images are
+// scaled down before encoding, so medium has all the detail needed.
+var encodeImageLabels = []string{analogdb.ImageMedium, analogdb.ImageLow, analogdb.ImageHigh}
+
 func (db *DB) downloadPostImage(ctx context.Context, post *analogdb.Post) (string, error) {
 
-	db.logger.Debug().Ctx(ctx).Msg("Starting download post")
+	db.logger.Debug().Ctx(ctx).Int("postID", post.Id).Msg("Starting download post")
 
 	ctx, span := db.tracer.Tracer.Start(ctx, "vector:download_post_image")
 	defer span.End()
 
-	var encode string
-	url := post.Images[1].Url
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		err = fmt.Errorf("failed to create request: %w", err)
-		span.SetStatus(codes.Error, "Create request failed")
-		span.RecordError(err)
-		return encode, err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
+	data, image, err := db.downloader.DownloadImage(ctx, post.Images, encodeImageLabels...)
 	if err != nil {
-		resp.Body.Close()
-		err = fmt.Errorf("failed to request post image: %w", err)
-		span.SetStatus(codes.Error, "Request for post image failed")
+		err = fmt.Errorf("failed to download post image: %w", err)
+		span.SetStatus(codes.Error, "Download of post image failed")
 		span.RecordError(err)
-		return encode, err
+		return "", err
 	}
-	defer resp.Body.Close()
-	span.AddEvent("Downloaded post image")
+	span.AddEvent("Downloaded post image", trace.WithAttributes(attribute.String("label", image.Label)))
 
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		err = fmt.Errorf("failed to read post image: %w", err)
-		span.SetStatus(codes.Error, "Reading of post image bytes failed")
-		span.RecordError(err)
-		return encode, err
-	}
-	encode = base64.StdEncoding.EncodeToString(data)
+	encode := base64.StdEncoding.EncodeToString(data)
 	span.AddEvent("Encoded to base64")
 	return encode, nil
 }
@@ -84,10 +81,9 @@ func (db *DB) postToPictureObject(ctx context.Context, post *analogdb.Post) (*mo
 
 	image, err := db.downloadPostImage(ctx, post)
 	if err != nil {
-		err = fmt.Errorf("failed to download post image: %w", err)
 		return nil, err
 	}
-	pictureObject := newPictureObject(image, post.Id, post.Grayscale, post.Nsfw, post.Sprocket)
+	pictureObject := newPictureObject(db.pictureClass(), image, post)
 	return pictureObject, nil
 }
 
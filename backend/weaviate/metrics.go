@@ -0,0 +1,33 @@
+package weaviate
+
+import (
+	"github.com/evanofslack/analogdb/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type encodeStats struct {
+	posts    *prometheus.CounterVec
+	duration prometheus.Histogram
+}
+
+func newEncodeStats() *encodeStats {
+	return &encodeStats{
+		posts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.AnalogdbNamespace,
+			Subsystem: metrics.VectorSubsystem,
+			Name:      "encoded_posts_total",
+			Help:      "Number of posts batch encoded into the vector DB, by result",
+		}, []string{"result"}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metrics.AnalogdbNamespace,
+			Subsystem: metrics.VectorSubsystem,
+			Name:      "batch_encode_duration_seconds",
+			Help:      "Time taken to batch encode posts into the vector DB",
+			Buckets:   []float64{1, 5, 10, 30, 60, 300, 900, 3600},
+		}),
+	}
+}
+
+func (stats *encodeStats) register(registry *prometheus.Registry) {
+	registry.MustRegister(stats.posts, stats.duration)
+}
@@ -0,0 +1,335 @@
+package weaviate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/fault"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+const (
+	// class holding the single record of the schema version
+	schemaVersionClass = "SchemaVersion"
+	schemaVersionID    = "6f2b0c1e-3a4d-4e5f-9a7b-8c9d0e1f2a3b"
+
+	// how often replicas check if the picture class was swapped by a reindex
+	schemaRefreshInterval = time.Minute
+)
+
+// schemaMigration is a versioned change to the picture class. New properties
+// are added to pictureSchema and reach existing classes by Migrate, but
+// existing pictures only get values for them by reindexing.
+type schemaMigration struct {
+	version     int
+	description string
+	reindex     bool
+}
+
+var schemaMigrations = []schemaMigration{
+	{version: 1, description: "create picture class"},
+	{version: 2, description: "add score and time of posts to pictures", reindex: true},
+}
+
+func latestSchemaVersion() int {
+	return schemaMigrations[len(schemaMigrations)-1].version
+}
+
+// SchemaStatus is the state of the vector DB compared to the migrations
+type SchemaStatus struct {
+	Version int
+	// class currently holding pictures
+	Class string
+	// migrations not yet applied, some need a reindex
+	Pending []SchemaMigration
+}
+
+// SchemaMigration describes a migration of the picture class
+type SchemaMigration struct {
+	Version     int
+	Description string
+	Reindex     bool
+}
+
+// schemaState is the record kept in the vector DB of its schema
+type schemaState struct {
+	version int
+	class   string
+}
+
+// Migrate brings the picture class up to date with pictureSchema. The class is
+// created if missing, otherwise missing properties are added. Migrations that
+// need existing pictures reindexed are left pending until Reindex runs.
+func (db *DB) Migrate(ctx context.Context) error {
+
+	db.logger.Debug().Msg("Starting vector DB migration")
+
+	state, err := db.readSchemaState(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to read vector DB schema version: %w", err)
+	}
+	db.setPictureClass(state.class)
+
+	existing, err := db.findClass(ctx, state.class)
+	if err != nil {
+		return fmt.Errorf("Failed to get weaviate schema: %w", err)
+	}
+
+	version := state.version
+	if existing == nil {
+		// a new class has nothing to reindex
+		if err := db.createPictureSchema(ctx, state.class); err != nil {
+			return fmt.Errorf("Failed to create schema: %w", err)
+		}
+		version = latestSchemaVersion()
+	} else {
+		if err := db.addMissingProperties(ctx, existing, pictureSchema(state.class)); err != nil {
+			return fmt.Errorf("Failed to migrate schema: %w", err)
+		}
+		// classes created before versioning are at the first version
+		if version == 0 {
+			version = 1
+		}
+		for _, m := range schemaMigrations {
+			if m.version <= version {
+				continue
+			}
+			if m.reindex {
+				db.logger.Warn().Int("version", m.version).Str("migration", m.description).Msg("Vector DB migration needs a reindex")
+				break
+			}
+			version = m.version
+		}
+	}
+
+	if version != state.version {
+		if err := db.writeSchemaState(ctx, schemaState{version: version, class: state.class}); err != nil {
+			return fmt.Errorf("Failed to record vector DB schema version: %w", err)
+		}
+	}
+
+	db.logger.Info().Int("version", version).Str("class", state.class).Msg("Completed vector DB migration")
+	return nil
+}
+
+// SchemaStatus reads the schema version and the migrations still pending
+func (db *DB) SchemaStatus(ctx context.Context) (*SchemaStatus, error) {
+	state, err := db.readSchemaState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &SchemaStatus{Version: state.version, Class: state.class}
+	for _, m := range schemaMigrations {
+		if m.version > state.version {
+			status.Pending = append(status.Pending, SchemaMigration{Version: m.version, Description: m.description, Reindex: m.reindex})
+		}
+	}
+	return status, nil
+}
+
+// ReindexResult describes a completed reindex
+type ReindexResult struct {
+	From    string
+	To      string
+	Encoded int
+	// posts that couldn't be encoded, which are missing from the new class
+	Failed []analogdb.EncodeFailure
+}
+
+// Reindex encodes every post into a new picture class with the latest schema,
+// then swaps it in place of the current class. Posts changed while encoding
+// are reconciled after the swap, and the old class is deleted once every
+// replica has had time to switch to the new one.
+func (ss SimilarityService) Reindex(ctx context.Context, batchSize int, progress analogdb.EncodeProgressFunc) (*ReindexResult, error) {
+
+	db := ss.db
+	db.logger.Debug().Ctx(ctx).Msg("Starting vector DB reindex")
+
+	state, err := db.readSchemaState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	from := state.class
+	to := fmt.Sprintf("%sV%d", PictureClass, time.Now().Unix())
+	if err := db.createPictureSchema(ctx, to); err != nil {
+		return nil, err
+	}
+
+	ids, err := ss.postService.AllPostIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// a few posts with broken images shouldn't hold back the whole reindex
+	encoded, err := ss.batchEncodePostsInto(ctx, to, ids, batchSize, progress)
+	if err != nil {
+		db.logger.Error().Err(err).Ctx(ctx).Str("class", to).Msg("Failed to reindex vector DB")
+		return nil, err
+	}
+
+	// swap the class, replicas follow within the refresh interval
+	if err := db.writeSchemaState(ctx, schemaState{version: latestSchemaVersion(), class: to}); err != nil {
+		return nil, err
+	}
+	db.setPictureClass(to)
+	db.logger.Info().Ctx(ctx).Str("from", from).Str("to", to).Msg("Swapped vector DB picture class")
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("Reindexed into %s without deleting %s: %w", to, from, ctx.Err())
+	case <-time.After(2 * schemaRefreshInterval):
+	}
+
+	// catch up on posts created or deleted since the reindex started, once
+	// every replica encodes into the new class. Posts replicas encoded into
+	// the old class in the meantime are already marked encoded.
+	if _, err := ss.reconcileClass(ctx, to, batchSize, false); err != nil {
+		return nil, fmt.Errorf("Reindexed into %s without deleting %s: %w", to, from, err)
+	}
+	if err := db.db.Schema().ClassDeleter().WithClassName(from).Do(ctx); err != nil {
+		return nil, fmt.Errorf("Reindexed into %s without deleting %s: %w", to, from, err)
+	}
+
+	db.logger.Info().Ctx(ctx).Str("from", from).Str("to", to).Int("posts", len(ids)).Int("failed", len(encoded.Failed)).Msg("Finished vector DB reindex")
+
+	return &ReindexResult{From: from, To: to, Encoded: len(encoded.Encoded), Failed: encoded.Failed}, nil
+}
+
+// watchSchema periodically follows the picture class, which is swapped by a reindex
+func (db *DB) watchSchema() {
+	ticker := time.NewTicker(schemaRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(db.ctx, db.timeout)
+			state, err := db.readSchemaState(ctx)
+			cancel()
+			if err != nil {
+				db.logger.Warn().Err(err).Msg("Failed to refresh vector DB schema version")
+				continue
+			}
+			db.setPictureClass(state.class)
+		}
+	}
+}
+
+// findClass returns the schema of class, or nil if it doesn't exist
+func (db *DB) findClass(ctx context.Context, class string) (*models.Class, error) {
+	schema, err := db.getSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range schema.Classes {
+		if c.Class == class {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// addMissingProperties adds properties of desired that existing lacks.
+// Properties are never changed or removed, which needs a reindex.
+func (db *DB) addMissingProperties(ctx context.Context, existing *models.Class, desired *models.Class) error {
+
+	have := make(map[string]bool, len(existing.Properties))
+	for _, prop := range existing.Properties {
+		have[prop.Name] = true
+	}
+
+	for _, prop := range desired.Properties {
+		if have[prop.Name] {
+			continue
+		}
+		if err := db.db.Schema().PropertyCreator().WithClassName(existing.Class).WithProperty(prop).Do(ctx); err != nil {
+			db.logger.Error().Err(err).Ctx(ctx).Str("class", existing.Class).Str("property", prop.Name).Msg("Failed to add property in vector DB")
+			return err
+		}
+		db.logger.Info().Ctx(ctx).Str("class", existing.Class).Str("property", prop.Name).Msg("Added property in vector DB")
+	}
+	return nil
+}
+
+// readSchemaState reads the schema version record, defaulting to
+// version 0 of the original picture class if there is none
+func (db *DB) readSchemaState(ctx context.Context) (schemaState, error) {
+
+	state := schemaState{class: PictureClass}
+
+	exists, err := db.db.Schema().ClassExistenceChecker().WithClassName(schemaVersionClass).Do(ctx)
+	if err != nil || !exists {
+		return state, err
+	}
+
+	objects, err := db.db.Data().ObjectsGetter().
+		WithClassName(schemaVersionClass).
+		WithID(schemaVersionID).
+		Do(ctx)
+	if err != nil {
+		var clientErr *fault.WeaviateClientError
+		if errors.As(err, &clientErr) && clientErr.StatusCode == http.StatusNotFound {
+			return state, nil
+		}
+		return state, err
+	}
+	if len(objects) == 0 {
+		return state, nil
+	}
+
+	props, ok := objects[0].Properties.(map[string]any)
+	if !ok {
+		return state, fmt.Errorf("invalid schema version record")
+	}
+	if version, ok := props["version"].(float64); ok {
+		state.version = int(version)
+	}
+	if class, ok := props["picture_class"].(string); ok && class != "" {
+		state.class = class
+	}
+	return state, nil
+}
+
+// writeSchemaState replaces the schema version record,
+// creating its class the first time
+func (db *DB) writeSchemaState(ctx context.Context, state schemaState) error {
+
+	exists, err := db.db.Schema().ClassExistenceChecker().WithClassName(schemaVersionClass).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		class := &models.Class{
+			Class:       schemaVersionClass,
+			Description: "Version of the vector DB schema",
+			Vectorizer:  "none",
+			Properties: []*models.Property{
+				{Name: "version", DataType: []string{"int"}, Description: "latest applied migration"},
+				{Name: "picture_class", DataType: []string{"text"}, Description: "class currently holding pictures"},
+			},
+		}
+		if err := db.db.Schema().ClassCreator().WithClass(class).Do(ctx); err != nil {
+			return err
+		}
+	}
+
+	props := map[string]any{
+		"version":       state.version,
+		"picture_class": state.class,
+	}
+	found, err := db.db.Data().Checker().WithClassName(schemaVersionClass).WithID(schemaVersionID).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if found {
+		return db.db.Data().Updater().WithClassName(schemaVersionClass).WithID(schemaVersionID).WithProperties(props).Do(ctx)
+	}
+	_, err = db.db.Data().Creator().WithClassName(schemaVersionClass).WithID(schemaVersionID).WithProperties(props).Do(ctx)
+	return err
+}
@@ -0,0 +1,182 @@
+package weaviate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/evanofslack/analogdb"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/data/replication"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+)
+
+// number of pictures read from the vector DB per request
+const encodedPageSize = 500
+
+// ReconcileResult lists the posts fixed by a reconcile
+type ReconcileResult struct {
+	// posts in the DB that were missing from the vector DB
+	Encoded []int
+	// pictures in the vector DB whose post no longer exists
+	Deleted []int
+	// posts with more than one picture in the vector DB, whose extra
+	// pictures were deleted. Pictures once had random uuids, so a post
+	// encoded again could end up with a second picture.
+	Duplicated []int
+	// posts missing from the vector DB that couldn't be encoded
+	Failed []analogdb.EncodeFailure
+}
+
+// Reconcile compares the posts in the DB with the pictures in the vector DB,
+// encoding posts that are missing and deleting pictures of posts that no longer
+// exist. With dryRun set, the differences are returned without fixing them.
+func (ss SimilarityService) Reconcile(ctx context.Context, batchSize int, dryRun bool) (*ReconcileResult, error) {
+	return ss.reconcileClass(ctx, ss.db.pictureClass(), batchSize, dryRun)
+}
+
+func (ss SimilarityService) reconcileClass(ctx context.Context, class string, batchSize int, dryRun bool) (*ReconcileResult, error) {
+
+	ss.db.logger.Debug().Ctx(ctx).Str("class", class).Bool("dryRun", dryRun).Msg("Starting reconcile with vector DB")
+
+	ids, err := ss.postService.AllPostIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pictures, duplicates, err := ss.db.encodedPictures(ctx, class)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		posts[id] = true
+	}
+
+	result := &ReconcileResult{}
+	for _, id := range ids {
+		if _, ok := pictures[id]; !ok {
+			result.Encoded = append(result.Encoded, id)
+		}
+	}
+	var orphans []string
+	for id, uuid := range pictures {
+		if !posts[id] {
+			result.Deleted = append(result.Deleted, id)
+			orphans = append(orphans, uuid)
+		}
+	}
+
+	for _, dup := range duplicates {
+		result.Duplicated = append(result.Duplicated, dup.postID)
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	if len(result.Encoded) > 0 {
+		// posts that fail to encode are left for the next reconcile
+		encoded, err := ss.batchEncodePostsInto(ctx, class, result.Encoded, batchSize, nil)
+		if err != nil {
+			return nil, err
+		}
+		result.Encoded = encoded.Encoded
+		result.Failed = encoded.Failed
+	}
+	for i, uuid := range orphans {
+		if err := ss.db.deletePicture(ctx, class, uuid); err != nil {
+			return nil, fmt.Errorf("Failed to delete post %d from vector DB: %w", result.Deleted[i], err)
+		}
+	}
+	for _, dup := range duplicates {
+		if err := ss.db.deletePicture(ctx, class, dup.uuid); err != nil {
+			return nil, fmt.Errorf("Failed to delete duplicate picture of post %d from vector DB: %w", dup.postID, err)
+		}
+	}
+
+	ss.db.logger.Info().Ctx(ctx).Int("encoded", len(result.Encoded)).Int("deleted", len(result.Deleted)).Int("duplicated", len(result.Duplicated)).Msg("Finished reconcile with vector DB")
+
+	return result, nil
+}
+
+// EncodedPostIDs is the id of every post with a picture in the vector DB
+func (ss SimilarityService) EncodedPostIDs(ctx context.Context) ([]int, error) {
+	pictures, _, err := ss.db.encodedPictures(ctx, ss.db.pictureClass())
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(pictures))
+	for id := range pictures {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// encodedPictures maps the post id of every picture in class to its uuid.
+// Posts with more than one picture keep the one with the uuid of the post,
+// and the rest are returned as duplicates.
+func (db *DB) encodedPictures(ctx context.Context, class string) (map[int]string, []pictureResponse, error) {
+
+	ctx, span := db.startTrace(ctx, "vector:encoded_pictures")
+	defer span.End()
+
+	fields := []graphql.Field{
+		{Name: "post_id"},
+		{Name: "_additional", Fields: []graphql.Field{
+			{Name: "id"},
+		}},
+	}
+
+	pictures := make(map[int]string)
+	var duplicates []pictureResponse
+	after := ""
+	for {
+		get := db.db.GraphQL().Get().
+			WithClassName(class).
+			WithFields(fields...).
+			WithLimit(encodedPageSize)
+		if after != "" {
+			get = get.WithAfter(after)
+		}
+		result, err := get.Do(ctx)
+		if err != nil {
+			db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to list pictures in vector DB")
+			return nil, nil, err
+		}
+		if len(result.Errors) > 0 {
+			err := fmt.Errorf("Failed to list pictures in vector DB: %s", result.Errors[0].Message)
+			db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to list pictures in vector DB")
+			return nil, nil, err
+		}
+
+		// an empty page is the end of the class
+		pics, err := unmarshallPicturesResp(result, class)
+		if err != nil {
+			break
+		}
+		for _, pic := range pics {
+			kept, ok := pictures[pic.postID]
+			if !ok {
+				pictures[pic.postID] = pic.uuid
+				continue
+			}
+			if pic.uuid == pictureID(pic.postID).String() {
+				pictures[pic.postID] = pic.uuid
+				pic.uuid = kept
+			}
+			duplicates = append(duplicates, pic)
+		}
+		if len(pics) < encodedPageSize {
+			break
+		}
+		after = pics[len(pics)-1].uuid
+	}
+	return pictures, duplicates, nil
+}
+
+func (db *DB) deletePicture(ctx context.Context, class string, uuid string) error {
+	return db.db.Data().Deleter().
+		WithClassName(class).
+		WithID(uuid).
+		WithConsistencyLevel(replication.ConsistencyLevel.ALL).
+		Do(ctx)
+}
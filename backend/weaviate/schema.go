@@ -14,7 +14,7 @@ func (ss SimilarityService) CreateSchemas(ctx context.Context) error {
 }
 
 func (db *DB) createSchemas(ctx context.Context) error {
-	err := db.createPictureSchema(ctx)
+	err := db.createPictureSchema(ctx, db.pictureClass())
 	return err
 }
 
@@ -26,12 +26,11 @@ func (db *DB) getSchema(ctx context.Context) (*schema.Dump, error) {
 	return schema, nil
 }
 
-func (db *DB) createPictureSchema(ctx context.Context) error {
-
-	db.logger.Debug().Ctx(ctx).Msg("Starting to create picture schema in vector DB")
-
-	classObj := &models.Class{
-		Class:       "Picture",
+// pictureSchema is the desired schema of the picture class.
+// Properties added here reach existing deployments through Migrate.
+func pictureSchema(class string) *models.Class {
+	return &models.Class{
+		Class:       class,
 		Description: "Analog photographs",
 		ModuleConfig: map[string]any{
 			"img2vec-neural": map[string]any{
@@ -72,16 +71,31 @@ func (db *DB) createPictureSchema(ctx context.Context) error {
 				DataType:    []string{"boolean"},
 				Description: "is post sprocket",
 			},
+			{
+				Name:        "score",
+				DataType:    []string{"int"},
+				Description: "score of post",
+			},
+			{
+				Name:        "time",
+				DataType:    []string{"int"},
+				Description: "unix time of post",
+			},
 		},
 	}
+}
+
+func (db *DB) createPictureSchema(ctx context.Context, class string) error {
+
+	db.logger.Debug().Ctx(ctx).Str("class", class).Msg("Starting to create picture schema in vector DB")
 
-	err := db.db.Schema().ClassCreator().WithClass(classObj).Do(context.Background())
+	err := db.db.Schema().ClassCreator().WithClass(pictureSchema(class)).Do(ctx)
 	if err != nil {
 		err = fmt.Errorf("Failed to create picture schema, %w", err)
 		db.logger.Error().Err(err).Ctx(ctx).Msg("Failed to create picture schema in vector DB")
 		return err
 	}
 
-	db.logger.Info().Ctx(ctx).Msg("Created picture schema in vector DB")
+	db.logger.Info().Ctx(ctx).Str("class", class).Msg("Created picture schema in vector DB")
 	return nil
 }
@@ -15,17 +15,19 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// PictureClass is the class holding pictures until the first reindex
 const PictureClass = "Picture"
 
 var _ analogdb.SimilarityService = (*SimilarityService)(nil)
 
 type SimilarityService struct {
-	db          *DB
-	postService analogdb.PostService
+	db              *DB
+	postService     analogdb.PostService
+	encodingService analogdb.EncodingService
 }
 
-func NewSimilarityService(db *DB, ps analogdb.PostService) *SimilarityService {
-	return &SimilarityService{db: db, postService: ps}
+func NewSimilarityService(db *DB, ps analogdb.PostService, es analogdb.EncodingService) *SimilarityService {
+	return &SimilarityService{db: db, postService: ps, encodingService: es}
 }
 
 func (ss SimilarityService) DeletePost(ctx context.Context, postID int) error {
@@ -58,6 +60,8 @@ func (db *DB) deletePost(ctx context.Context, postID int) error {
 	ctx, span := db.startTrace(ctx, "vector:delete_post", trace.WithAttributes(attribute.Int("postID", postID)))
 	defer span.End()
 
+	class := db.pictureClass()
+
 	fields := []graphql.Field{
 		{Name: "post_id"},
 		{Name: "_additional", Fields: []graphql.Field{
@@ -72,7 +76,7 @@ func (db *DB) deletePost(ctx context.Context, postID int) error {
 		WithValueInt(int64(postID))
 
 	result, err := db.db.GraphQL().Get().
-		WithClassName(PictureClass).
+		WithClassName(class).
 		WithFields(fields...).
 		WithLimit(1).
 		WithWhere(where).
@@ -87,7 +91,7 @@ func (db *DB) deletePost(ctx context.Context, postID int) error {
 	}
 	span.AddEvent("Got vector embedding by postID", trace.WithAttributes(attribute.Int("postID", postID)))
 
-	pics, err := unmarshallPicturesResp(result)
+	pics, err := unmarshallPicturesResp(result, class)
 	if err != nil {
 		db.logger.Error().Err(err).Ctx(ctx).Int("postID", postID).Msg("Failed to delete post from vector DB")
 		span.SetStatus(codes.Error, "Unmarshall embedding failed")
@@ -98,7 +102,7 @@ func (db *DB) deletePost(ctx context.Context, postID int) error {
 	span.AddEvent("Unmarshalled embedding", trace.WithAttributes(attribute.Int("postID", postID), attribute.String("uuid", uuid)))
 
 	err = db.db.Data().Deleter().
-		WithClassName(PictureClass).
+		WithClassName(class).
 		WithID(pics[0].uuid).
 		WithConsistencyLevel(replication.ConsistencyLevel.ALL). // default QUORUM
 		Do(ctx)
@@ -137,6 +141,8 @@ func (db *DB) getSimilarPostIDs(ctx context.Context, filter *analogdb.PostSimila
 	ctx, span := db.startTrace(ctx, "vector:get_similar_post_ids", trace.WithAttributes(attribute.Int("postID", postID)))
 	defer span.End()
 
+	class := db.pictureClass()
+
 	// first make the query to lookup UUID associated with post's embedding
 
 	fields := []graphql.Field{
@@ -153,7 +159,7 @@ func (db *DB) getSimilarPostIDs(ctx context.Context, filter *analogdb.PostSimila
 		WithValueInt(int64(postID))
 
 	result, err := db.db.GraphQL().Get().
-		WithClassName("Picture").
+		WithClassName(class).
 		WithFields(fields...).
 		WithLimit(1).
 		WithWhere(where).
@@ -167,7 +173,7 @@ func (db *DB) getSimilarPostIDs(ctx context.Context, filter *analogdb.PostSimila
 	}
 	span.AddEvent("Got vector embedding by postID", trace.WithAttributes(attribute.Int("postID", postID)))
 
-	pics, err := unmarshallPicturesResp(result)
+	pics, err := unmarshallPicturesResp(result, class)
 	if err != nil {
 		db.logger.Error().Err(err).Ctx(ctx).Int("postID", postID).Msg("Failed to unmarshall post from vector DB")
 		span.SetStatus(codes.Error, "Unmarshall embedding failed")
@@ -196,7 +202,7 @@ func (db *DB) getSimilarPostIDs(ctx context.Context, filter *analogdb.PostSimila
 
 	nearObject := db.db.GraphQL().NearObjectArgBuilder().WithID(uuid)
 	result, err = db.db.GraphQL().Get().
-		WithClassName(PictureClass).
+		WithClassName(class).
 		WithFields(fields...).
 		WithLimit(limit).
 		WithWhere(where).
@@ -211,7 +217,7 @@ func (db *DB) getSimilarPostIDs(ctx context.Context, filter *analogdb.PostSimila
 	}
 	span.AddEvent("Found similar embeddings", trace.WithAttributes(attribute.Int("postID", postID), attribute.String("uuid", uuid)))
 
-	pics, err = unmarshallPicturesResp(result)
+	pics, err = unmarshallPicturesResp(result, class)
 	if err != nil {
 		db.logger.Error().Err(err).Ctx(ctx).Int("postID", postID).Msg("Failed to unmarshall post from vector DB")
 		span.SetStatus(codes.Error, "Unmarshall embedding failed")
@@ -280,14 +286,14 @@ func filterToWhere(filter *analogdb.PostSimilarityFilter) (*filters.WhereBuilder
 
 }
 
-func unmarshallPicturesResp(result *models.GraphQLResponse) ([]pictureResponse, error) {
+func unmarshallPicturesResp(result *models.GraphQLResponse, class string) ([]pictureResponse, error) {
 
 	var picturesResponse []pictureResponse
 
 	data := result.Data["Get"].(map[string]interface{})
 
 	// dear god i hate this
-	if pictures, ok := data["Picture"].([]interface{}); ok {
+	if pictures, ok := data[class].([]interface{}); ok {
 		for _, picture := range pictures {
 
 			var pic pictureResponse
@@ -3,9 +3,12 @@ package weaviate
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/evanofslack/analogdb/imaging"
 	"github.com/evanofslack/analogdb/logger"
+	"github.com/evanofslack/analogdb/metrics"
 	"github.com/evanofslack/analogdb/tracer"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 	"go.opentelemetry.io/otel/attribute"
@@ -23,15 +26,51 @@ type DB struct {
 	cancel  func()
 	logger  *logger.Logger
 	tracer  *tracer.Tracer
+
+	// downloads the images of posts to encode
+	downloader *imaging.Downloader
+	stats      *encodeStats
+	metrics    *metrics.Metrics
+
+	// class currently holding pictures, which changes after a reindex
+	mu      sync.RWMutex
+	picture string
 }
 
-func NewDB(host string, scheme string, logger *logger.Logger, tracer *tracer.Tracer) *DB {
+// Option configures a DB instance
+type Option func(*DB)
+
+// WithDownloader downloads the images of posts with d,
+// instead of a downloader with the default limits
+func WithDownloader(d *imaging.Downloader) Option {
+	return func(db *DB) {
+		db.downloader = d
+	}
+}
+
+// WithMetrics exports stats of encoding posts to the metrics registry
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(db *DB) {
+		db.metrics = m
+	}
+}
+
+func NewDB(host string, scheme string, logger *logger.Logger, tracer *tracer.Tracer, opts ...Option) *DB {
 	db := &DB{
-		host:    host,
-		scheme:  scheme,
-		timeout: weaviateClientTimeout,
-		logger:  logger,
-		tracer:  tracer,
+		host:       host,
+		scheme:     scheme,
+		timeout:    weaviateClientTimeout,
+		logger:     logger,
+		tracer:     tracer,
+		downloader: imaging.NewDownloader(),
+		stats:      newEncodeStats(),
+		picture:    PictureClass,
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	if db.metrics != nil {
+		db.stats.register(db.metrics.Registry)
 	}
 	db.ctx, db.cancel = context.WithCancel(context.Background())
 	db.logger.Info().Msg("Initialized vector DB instance")
@@ -65,27 +104,11 @@ func (db *DB) Open() error {
 	}
 
 	db.logger.Info().Msg("Opened new vector DB connection")
-	return err
-}
 
-func (db *DB) Migrate(ctx context.Context) error {
+	// follow the picture class as other replicas reindex
+	go db.watchSchema()
 
-	db.logger.Debug().Msg("Starting vector DB migration")
-
-	schema, err := db.getSchema(ctx)
-	if err != nil {
-		err = fmt.Errorf("Failed to get weaviate schema: %w", err)
-		return err
-	}
-	// if no classes, create schemas
-	if len(schema.Classes) == 0 {
-		if err := db.createSchemas(ctx); err != nil {
-			err = fmt.Errorf("Failed to get create schema: %w", err)
-			return err
-		}
-	}
-	db.logger.Info().Msg("Completed vector DB migration")
-	return nil
+	return err
 }
 
 func (db *DB) Close() error {
@@ -95,6 +118,22 @@ func (db *DB) Close() error {
 	return nil
 }
 
+// pictureClass is the name of the class currently holding pictures
+func (db *DB) pictureClass() string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.picture
+}
+
+func (db *DB) setPictureClass(class string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.picture != class {
+		db.logger.Info().Str("class", class).Msg("Switched vector DB picture class")
+	}
+	db.picture = class
+}
+
 // start a trace targeting the weaviate server
 func (db *DB) startTrace(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
 
@@ -163,7 +163,7 @@ export default function Documentation() {
       param: "sort",
       description: "how to order the posts",
       default: "latest",
-      options: "latest, top, random",
+      options: "latest, top, random, oldest, trending, resolution, color",
     },
     {
       param: "page_size",
@@ -351,7 +351,8 @@ export default function Documentation() {
           </p>
           <h3 className={styles.h3}>Query Parameters</h3>
           <p>
-            Posts can be sorted by time, score, or pseudo-randomly. Limits can
+            Posts can be sorted by time, score, trending, resolution, color
+            dominance, or pseudo-randomly. Limits can
             be placed for maximum number of returned posts. If total number of
             posts exceeds the limit, results will be paginated.
           </p>